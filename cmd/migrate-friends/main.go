@@ -0,0 +1,317 @@
+// Command migrate-friends converts the legacy friendship arrays embedded in users
+// (friends, sent_requests, pending_requests) into records of the friends collection.
+//
+// It is safe to run more than once: pairs that already have a record are left untouched.
+// Every inconsistency found in the arrays is reported, together with how it was resolved.
+//
+//	go run cmd/migrate-friends/main.go [-dry-run] [-unset]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/TomTom2k/chat-app/server/internal/config"
+	"github.com/TomTom2k/chat-app/server/internal/domain"
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+	"github.com/TomTom2k/chat-app/server/internal/infrastructure/mongodb"
+	"github.com/TomTom2k/chat-app/server/internal/infrastructure/repository"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// pairState collects what both users' arrays say about one pair
+type pairState struct {
+	userIDs  [2]string
+	friendOf map[string]bool // user ID -> lists the other one in friends
+	sentBy   map[string]bool // user ID -> lists the other one in sent_requests
+	pendedBy map[string]bool // user ID -> lists the other one in pending_requests
+}
+
+type report struct {
+	users           int
+	pairs           int
+	created         map[string]int
+	alreadyMigrated int
+	skipped         int
+	failed          int
+	issues          []string
+}
+
+func (r *report) issue(format string, args ...interface{}) {
+	r.issues = append(r.issues, fmt.Sprintf(format, args...))
+}
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "only report, do not write anything")
+	unset := flag.Bool("unset", false, "remove the legacy arrays from users after a run without failures")
+	flag.Parse()
+
+	cfg := config.Load()
+	if err := mongodb.Initialize(cfg); err != nil {
+		log.Fatal("Failed to connect to MongoDB:", err)
+	}
+	defer mongodb.Close()
+
+	users := mongodb.OpenCollection("users")
+	friendRepo := repository.NewFriendRepository()
+
+	rep := &report{created: make(map[string]int)}
+	usersByID, err := loadUsers(users)
+	if err != nil {
+		log.Fatal("Failed to load users:", err)
+	}
+	rep.users = len(usersByID)
+
+	pairs := collectPairs(usersByID, rep)
+	rep.pairs = len(pairs)
+
+	keys := make([]string, 0, len(pairs))
+	for key := range pairs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		migratePair(pairs[key], usersByID, friendRepo, *dryRun, rep)
+	}
+
+	printReport(rep, *dryRun)
+
+	if rep.failed > 0 {
+		os.Exit(1)
+	}
+
+	if *unset && !*dryRun {
+		if err := unsetLegacyArrays(users); err != nil {
+			log.Fatal("Failed to remove legacy arrays:", err)
+		}
+		fmt.Println("Legacy arrays removed from users")
+	}
+}
+
+func loadUsers(users *mongo.Collection) (map[string]entity.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	cursor, err := users.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	usersByID := make(map[string]entity.User)
+	for cursor.Next(ctx) {
+		var user entity.User
+		if err := cursor.Decode(&user); err != nil {
+			return nil, err
+		}
+		usersByID[user.ID] = user
+	}
+	return usersByID, cursor.Err()
+}
+
+func collectPairs(usersByID map[string]entity.User, rep *report) map[string]*pairState {
+	pairs := make(map[string]*pairState)
+	claim := func(userID, otherID string, field string) *pairState {
+		if userID == otherID {
+			rep.issue("user %s lists itself in %s: ignored", userID, field)
+			return nil
+		}
+		key := entity.FriendPairKey(userID, otherID)
+		pair, ok := pairs[key]
+		if !ok {
+			pair = &pairState{
+				userIDs:  [2]string{userID, otherID},
+				friendOf: make(map[string]bool),
+				sentBy:   make(map[string]bool),
+				pendedBy: make(map[string]bool),
+			}
+			sort.Strings(pair.userIDs[:])
+			pairs[key] = pair
+		}
+		return pair
+	}
+
+	for _, user := range usersByID {
+		for _, otherID := range user.Friends {
+			if pair := claim(user.ID, otherID, "friends"); pair != nil {
+				pair.friendOf[user.ID] = true
+			}
+		}
+		for _, otherID := range user.SentRequests {
+			if pair := claim(user.ID, otherID, "sent_requests"); pair != nil {
+				pair.sentBy[user.ID] = true
+			}
+		}
+		for _, otherID := range user.PendingRequests {
+			if pair := claim(user.ID, otherID, "pending_requests"); pair != nil {
+				pair.pendedBy[user.ID] = true
+			}
+		}
+	}
+	return pairs
+}
+
+// resolve turns the array claims of a pair into a single record, reporting whatever does not add up
+func resolve(pair *pairState, usersByID map[string]entity.User, rep *report) (entity.Friend, bool) {
+	a, b := pair.userIDs[0], pair.userIDs[1]
+	label := a + " <-> " + b
+
+	for _, id := range pair.userIDs {
+		if _, ok := usersByID[id]; !ok {
+			rep.issue("%s: user %s does not exist: skipped", label, id)
+			return entity.Friend{}, false
+		}
+	}
+
+	// requestedBy[x] means x asked the other one, as seen from either side's arrays
+	requestedBy := map[string]bool{}
+	for _, sender := range pair.userIDs {
+		receiver := a
+		if sender == a {
+			receiver = b
+		}
+		sent, pended := pair.sentBy[sender], pair.pendedBy[receiver]
+		if sent && !pended {
+			rep.issue("%s: %s has a sent request that %s never received: kept as pending", label, sender, receiver)
+		}
+		if pended && !sent {
+			rep.issue("%s: %s has a pending request that %s never sent: kept as pending", label, receiver, sender)
+		}
+		if sent || pended {
+			requestedBy[sender] = true
+		}
+	}
+
+	if pair.friendOf[a] || pair.friendOf[b] {
+		if pair.friendOf[a] != pair.friendOf[b] {
+			rep.issue("%s: friendship listed on one side only: kept as accepted", label)
+		}
+		if len(requestedBy) > 0 {
+			rep.issue("%s: already friends but requests left over: requests dropped", label)
+		}
+		createdAt := earliestUpdate(usersByID[a], usersByID[b])
+		return entity.Friend{
+			UserID1:    a,
+			UserID2:    b,
+			Status:     entity.FriendStatusAccepted,
+			AcceptedAt: &createdAt,
+			CreatedAt:  createdAt,
+		}, true
+	}
+
+	if requestedBy[a] && requestedBy[b] {
+		rep.issue("%s: both users asked each other: kept as accepted", label)
+		createdAt := earliestUpdate(usersByID[a], usersByID[b])
+		return entity.Friend{
+			UserID1:    a,
+			UserID2:    b,
+			Status:     entity.FriendStatusAccepted,
+			AcceptedAt: &createdAt,
+			CreatedAt:  createdAt,
+		}, true
+	}
+
+	sender, receiver := a, b
+	if requestedBy[b] {
+		sender, receiver = b, a
+	}
+	// The arrays never recorded when a request was sent, the sender's last update is the best guess
+	return entity.Friend{
+		UserID1:   sender,
+		UserID2:   receiver,
+		Status:    entity.FriendStatusPending,
+		CreatedAt: usersByID[sender].UpdatedAt,
+	}, true
+}
+
+func migratePair(pair *pairState, usersByID map[string]entity.User, friendRepo domain.FriendRepository, dryRun bool, rep *report) {
+	friend, ok := resolve(pair, usersByID, rep)
+	if !ok {
+		rep.skipped++
+		return
+	}
+	label := friend.UserID1 + " <-> " + friend.UserID2
+
+	blocked, err := friendRepo.IsBlocked(friend.UserID1, friend.UserID2)
+	if err != nil {
+		rep.issue("%s: unable to check blocks: %v", label, err)
+		rep.failed++
+		return
+	}
+	if blocked {
+		rep.issue("%s: users have blocked each other: %s record not created", label, friend.Status)
+		rep.skipped++
+		return
+	}
+
+	existing, err := friendRepo.GetFriendByUserIDs(friend.UserID1, friend.UserID2)
+	if err != nil {
+		rep.issue("%s: unable to load existing record: %v", label, err)
+		rep.failed++
+		return
+	}
+	if existing.ID != "" {
+		if existing.Status == friend.Status {
+			rep.alreadyMigrated++
+			return
+		}
+		rep.issue("%s: arrays say %s but the friends collection says %s: collection kept", label, friend.Status, existing.Status)
+		rep.skipped++
+		return
+	}
+
+	if !dryRun {
+		if err := friendRepo.CreateFriend(friend); err != nil {
+			rep.issue("%s: unable to create %s record: %v", label, friend.Status, err)
+			rep.failed++
+			return
+		}
+	}
+	rep.created[friend.Status]++
+}
+
+func earliestUpdate(user1, user2 entity.User) time.Time {
+	if user2.UpdatedAt.Before(user1.UpdatedAt) {
+		return user2.UpdatedAt
+	}
+	return user1.UpdatedAt
+}
+
+func unsetLegacyArrays(users *mongo.Collection) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	_, err := users.UpdateMany(ctx, bson.M{}, bson.M{
+		"$unset": bson.M{"friends": "", "sent_requests": "", "pending_requests": ""},
+	})
+	return err
+}
+
+func printReport(rep *report, dryRun bool) {
+	if dryRun {
+		fmt.Println("Dry run: nothing was written")
+	}
+	fmt.Printf("Users scanned:     %d\n", rep.users)
+	fmt.Printf("Pairs found:       %d\n", rep.pairs)
+	fmt.Printf("Accepted created:  %d\n", rep.created[entity.FriendStatusAccepted])
+	fmt.Printf("Pending created:   %d\n", rep.created[entity.FriendStatusPending])
+	fmt.Printf("Already migrated:  %d\n", rep.alreadyMigrated)
+	fmt.Printf("Skipped:           %d\n", rep.skipped)
+	fmt.Printf("Failed:            %d\n", rep.failed)
+
+	if len(rep.issues) == 0 {
+		fmt.Println("No inconsistencies found")
+		return
+	}
+	fmt.Printf("Inconsistencies (%d):\n", len(rep.issues))
+	for _, issue := range rep.issues {
+		fmt.Println("  - " + issue)
+	}
+}
@@ -1,18 +1,47 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	ServerPort    string
-	MongoDBURI    string
-	DatabaseName  string
-	JWTSecret     string
-	Environment   string
+	ServerPort         string
+	MongoDBURI         string
+	DatabaseName       string
+	JWTSecret          string // HS256 only
+	JWTAlgorithm       string // "HS256", "RS256" hoặc "EdDSA"
+	JWTKeyID           string // kid của khóa ký, mặc định suy ra từ khóa
+	JWTPrivateKeyFile  string // RS256/EdDSA: file PEM của khóa ký
+	JWTVerifyKeys      string // Khóa cũ vẫn được chấp nhận khi xoay khóa: "kid=path,kid=path"
+	Environment        string
+	MessageEditWindow  time.Duration // Thời gian cho phép sửa message sau khi gửi (0 = không giới hạn)
+	HubBackplane       string        // "memory" (một instance) hoặc "mongodb" (nhiều instance, cần replica set)
+	AccessTokenTTL     time.Duration // Thời hạn access token (JWT)
+	RefreshTokenTTL    time.Duration // Thời hạn refresh token, được gia hạn mỗi lần refresh
+	AppBaseURL         string        // URL của client, dùng để tạo link trong email
+	Mailer             string        // "log" (ghi log hoặc file .eml vào MailDir) hoặc "smtp"
+	MailFrom           string
+	MailDir            string // Mailer "log": thư mục ghi file .eml, để trống thì in ra log
+	SMTPHost           string
+	SMTPPort           string
+	SMTPUsername       string
+	SMTPPassword       string
+	EmailVerifyTTL     time.Duration // Thời hạn link xác thực email
+	PasswordResetTTL   time.Duration // Thời hạn link đặt lại mật khẩu
+	TOTPIssuer         string        // Tên hiển thị trong ứng dụng authenticator khi bật 2FA
+	LoginMaxFailures   int           // Số lần đăng nhập sai với một email trước khi bị khóa tạm thời
+	LoginMaxIPFailures int           // Số lần đăng nhập sai từ một IP (mọi email) trước khi bị khóa tạm thời
+	LoginLockout       time.Duration // Thời gian khóa
+	LoginFailureWindow time.Duration // Bộ đếm lần sai được reset nếu không sai thêm trong khoảng này
+	TrustedProxies     []string      // IP/CIDR của reverse proxy được tin X-Forwarded-For, mặc định không tin proxy nào
 }
 
 func Load() *Config {
@@ -22,11 +51,35 @@ func Load() *Config {
 	}
 
 	config := &Config{
-		ServerPort:   getEnv("SERVER_PORT", "8080"),
-		MongoDBURI:   getEnv("MONGODB_URI", ""),
-		DatabaseName: getEnv("DATABASE_NAME", "chat_app"),
-		JWTSecret:    getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
-		Environment:  getEnv("ENVIRONMENT", "development"),
+		ServerPort:         getEnv("SERVER_PORT", "8080"),
+		MongoDBURI:         getEnv("MONGODB_URI", ""),
+		DatabaseName:       getEnv("DATABASE_NAME", "chat_app"),
+		JWTSecret:          getEnv("JWT_SECRET", ""),
+		JWTAlgorithm:       getEnv("JWT_ALGORITHM", "HS256"),
+		JWTKeyID:           getEnv("JWT_KEY_ID", ""),
+		JWTPrivateKeyFile:  getEnv("JWT_PRIVATE_KEY_FILE", ""),
+		JWTVerifyKeys:      getEnv("JWT_VERIFY_KEYS", ""),
+		Environment:        getEnv("ENVIRONMENT", "development"),
+		MessageEditWindow:  getDurationEnv("MESSAGE_EDIT_WINDOW", 15*time.Minute),
+		HubBackplane:       getEnv("HUB_BACKPLANE", "memory"),
+		AccessTokenTTL:     getDurationEnv("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL:    getDurationEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		AppBaseURL:         getEnv("APP_BASE_URL", "http://localhost:3000"),
+		Mailer:             getEnv("MAILER", "log"),
+		MailFrom:           getEnv("MAIL_FROM", "Chat App <no-reply@localhost>"),
+		MailDir:            getEnv("MAIL_DIR", ""),
+		SMTPHost:           getEnv("SMTP_HOST", ""),
+		SMTPPort:           getEnv("SMTP_PORT", "587"),
+		SMTPUsername:       getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:       getEnv("SMTP_PASSWORD", ""),
+		EmailVerifyTTL:     getDurationEnv("EMAIL_VERIFY_TTL", 24*time.Hour),
+		PasswordResetTTL:   getDurationEnv("PASSWORD_RESET_TTL", time.Hour),
+		TOTPIssuer:         getEnv("TOTP_ISSUER", "Chat App"),
+		LoginMaxFailures:   getIntEnv("LOGIN_MAX_FAILURES", 5),
+		LoginMaxIPFailures: getIntEnv("LOGIN_MAX_IP_FAILURES", 50),
+		LoginLockout:       getDurationEnv("LOGIN_LOCKOUT", 15*time.Minute),
+		LoginFailureWindow: getDurationEnv("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+		TrustedProxies:     getListEnv("TRUSTED_PROXIES"),
 	}
 
 	// Validate required configs
@@ -34,9 +87,47 @@ func Load() *Config {
 		log.Fatal("[ERROR]: MONGODB_URI is required")
 	}
 
+	// A signing key must be configured explicitly in production, development gets a random secret per process
+	if config.JWTAlgorithm == "HS256" && config.JWTSecret == "" {
+		if config.Environment == "production" {
+			log.Fatal("[ERROR]: JWT_SECRET (or JWT_ALGORITHM with JWT_PRIVATE_KEY_FILE) is required in production")
+		}
+		secret, err := randomSecret()
+		if err != nil {
+			log.Fatalf("[ERROR]: JWT_SECRET is not set and no random secret could be generated: %v", err)
+		}
+		log.Println("[WARNING]: JWT_SECRET is not set, using a random secret: tokens do not survive a restart and are not shared between instances")
+		config.JWTSecret = secret
+	}
+	if config.JWTAlgorithm != "HS256" && config.JWTPrivateKeyFile == "" {
+		log.Fatalf("[ERROR]: JWT_PRIVATE_KEY_FILE is required for %s", config.JWTAlgorithm)
+	}
+
+	switch config.Mailer {
+	case "smtp":
+		if config.SMTPHost == "" {
+			log.Fatal("[ERROR]: SMTP_HOST is required when MAILER=smtp")
+		}
+	case "log":
+		if config.Environment == "production" {
+			log.Println("[WARNING]: MAILER=log in production, verification and password reset emails are not delivered")
+		}
+	default:
+		log.Fatalf("[ERROR]: unsupported MAILER %q, expected log or smtp", config.Mailer)
+	}
+
 	return config
 }
 
+// randomSecret returns a 256 bit hex-encoded secret
+func randomSecret() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
 func getEnv(key, defaultValue string) string {
 	value := os.Getenv(key)
 	if value == "" {
@@ -45,6 +136,39 @@ func getEnv(key, defaultValue string) string {
 	return value
 }
 
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("[WARNING]: invalid duration for %s, using default %s", key, defaultValue)
+		return defaultValue
+	}
+	return duration
+}
 
+func getIntEnv(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number <= 0 {
+		log.Printf("[WARNING]: invalid number for %s, using default %d", key, defaultValue)
+		return defaultValue
+	}
+	return number
+}
 
-
+// getListEnv splits a comma-separated value, empty when the variable is not set
+func getListEnv(key string) []string {
+	values := make([]string, 0)
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -9,24 +9,149 @@ const (
 	ConversationTypeGroup  ConversationType = "group"  // Chat nhóm (>2 members)
 )
 
+const (
+	ConversationRoleOwner     = "owner"
+	ConversationRoleAdmin     = "admin"
+	ConversationRoleModerator = "moderator"
+	ConversationRoleMember    = "member"
+)
+
+// Group actions controlled by the permission matrix
+const (
+	PermissionPost                 = "post"
+	PermissionPin                  = "pin"
+	PermissionRename               = "rename"
+	PermissionChangeAvatar         = "change_avatar"
+	PermissionInvite               = "invite"
+	PermissionRemoveMembers        = "remove_members"
+	PermissionDeleteOthersMessages = "delete_others_messages"
+)
+
+// DefaultGroupPermissions maps each permission to the lowest role allowed to use it
+var DefaultGroupPermissions = map[string]string{
+	PermissionPost:                 ConversationRoleMember,
+	PermissionPin:                  ConversationRoleModerator,
+	PermissionRename:               ConversationRoleAdmin,
+	PermissionChangeAvatar:         ConversationRoleAdmin,
+	PermissionInvite:               ConversationRoleAdmin,
+	PermissionRemoveMembers:        ConversationRoleModerator,
+	PermissionDeleteOthersMessages: ConversationRoleModerator,
+}
+
+// RoleRank orders roles from least (1) to most (4) privileged; unknown roles rank 0
+func RoleRank(role string) int {
+	switch role {
+	case ConversationRoleOwner:
+		return 4
+	case ConversationRoleAdmin:
+		return 3
+	case ConversationRoleModerator:
+		return 2
+	case ConversationRoleMember:
+		return 1
+	}
+	return 0
+}
+
 type ConversationMember struct {
-	UserID    string    `json:"user_id" bson:"user_id"`
-	Role      string    `json:"role,omitempty" bson:"role,omitempty"` // "admin", "member" (chỉ cho group)
-	JoinedAt  time.Time `json:"joined_at" bson:"joined_at"`
+	UserID            string     `json:"user_id" bson:"user_id"`
+	Role              string     `json:"role,omitempty" bson:"role,omitempty"` // "owner", "admin", "moderator", "member" (chỉ cho group)
+	JoinedAt          time.Time  `json:"joined_at" bson:"joined_at"`
+	LastReadMessageID string     `json:"last_read_message_id,omitempty" bson:"last_read_message_id,omitempty"` // Read cursor của member
+	LastReadAt        *time.Time `json:"last_read_at,omitempty" bson:"last_read_at,omitempty"`                 // Thời điểm tạo của message đã đọc cuối cùng
+	UnreadCount       int        `json:"unread_count" bson:"unread_count"`
 }
 
 type Conversation struct {
-	ID              string               `json:"id" bson:"_id"`
-	Type            ConversationType     `json:"type" bson:"type"` // "direct" or "group"
-	Name            string               `json:"name,omitempty" bson:"name,omitempty"` // Tên nhóm (chỉ cho group), hoặc tên chat đơn
-	Description     string               `json:"description,omitempty" bson:"description,omitempty"` // Mô tả (chỉ cho group)
-	Avatar          string               `json:"avatar,omitempty" bson:"avatar,omitempty"`
-	Members         []ConversationMember `json:"members" bson:"members"` // Danh sách thành viên
-	LastMessage     string               `json:"last_message,omitempty" bson:"last_message,omitempty"`
-	LastMessageTime *time.Time           `json:"last_message_time,omitempty" bson:"last_message_time,omitempty"`
-	Unread          int                  `json:"unread" bson:"unread"`
-	CreatedBy       string               `json:"created_by,omitempty" bson:"created_by,omitempty"` // Người tạo (chỉ cho group)
-	CreatedAt       time.Time            `json:"created_at" bson:"created_at"`
-	UpdatedAt       time.Time            `json:"updated_at" bson:"updated_at"`
+	ID                string               `json:"id" bson:"_id"`
+	Type              ConversationType     `json:"type" bson:"type"`                                   // "direct" or "group"
+	Name              string               `json:"name,omitempty" bson:"name,omitempty"`               // Tên nhóm (chỉ cho group), hoặc tên chat đơn
+	Description       string               `json:"description,omitempty" bson:"description,omitempty"` // Mô tả (chỉ cho group)
+	Avatar            string               `json:"avatar,omitempty" bson:"avatar,omitempty"`
+	Members           []ConversationMember `json:"members" bson:"members"`                                           // Danh sách thành viên
+	Permissions       map[string]string    `json:"permissions,omitempty" bson:"permissions,omitempty"`               // Permission -> role thấp nhất được phép (chỉ cho group)
+	PinnedMessages    []string             `json:"pinned_messages,omitempty" bson:"pinned_messages,omitempty"`       // IDs của messages được ghim
+	InviteRedemptions []InviteRedemption   `json:"invite_redemptions,omitempty" bson:"invite_redemptions,omitempty"` // Audit các lần dùng link mời
+	RequiresApproval  bool                 `json:"requires_approval,omitempty" bson:"requires_approval,omitempty"`   // Nhóm riêng tư: user phải gửi yêu cầu và chờ admin duyệt
+	LastMessage       string               `json:"last_message,omitempty" bson:"last_message,omitempty"`
+	LastMessageTime   *time.Time           `json:"last_message_time,omitempty" bson:"last_message_time,omitempty"`
+	CreatedBy         string               `json:"created_by,omitempty" bson:"created_by,omitempty"` // Người tạo (chỉ cho group)
+	CreatedAt         time.Time            `json:"created_at" bson:"created_at"`
+	UpdatedAt         time.Time            `json:"updated_at" bson:"updated_at"`
+}
+
+// GetMember returns the member entry of the user, if any
+func (c *Conversation) GetMember(userID string) (ConversationMember, bool) {
+	for _, member := range c.Members {
+		if member.UserID == userID {
+			return member, true
+		}
+	}
+	return ConversationMember{}, false
 }
 
+// OwnerID returns the group's owner. Groups created before owner roles existed fall back to CreatedBy.
+func (c *Conversation) OwnerID() string {
+	for _, member := range c.Members {
+		if member.Role == ConversationRoleOwner {
+			return member.UserID
+		}
+	}
+	if _, ok := c.GetMember(c.CreatedBy); ok {
+		return c.CreatedBy
+	}
+	return ""
+}
+
+// RoleOf returns the user's effective role, or "" if the user is not a member
+func (c *Conversation) RoleOf(userID string) string {
+	member, ok := c.GetMember(userID)
+	if !ok {
+		return ""
+	}
+	if c.Type == ConversationTypeGroup && userID == c.OwnerID() {
+		return ConversationRoleOwner
+	}
+	if member.Role == "" {
+		return ConversationRoleMember
+	}
+	return member.Role
+}
+
+// RequiredRole returns the lowest role allowed to use the permission in this group
+func (c *Conversation) RequiredRole(permission string) string {
+	if role, ok := c.Permissions[permission]; ok {
+		return role
+	}
+	return DefaultGroupPermissions[permission]
+}
+
+// Can reports whether the user may perform the action. In direct conversations both members may only post.
+func (c *Conversation) Can(userID, permission string) bool {
+	role := c.RoleOf(userID)
+	if role == "" {
+		return false
+	}
+	if c.Type != ConversationTypeGroup {
+		return permission == PermissionPost
+	}
+	if _, ok := DefaultGroupPermissions[permission]; !ok {
+		return false
+	}
+	return RoleRank(role) >= RoleRank(c.RequiredRole(permission))
+}
+
+// Outranks reports whether the actor's role is strictly higher than the target's
+func (c *Conversation) Outranks(actorID, targetID string) bool {
+	return RoleRank(c.RoleOf(actorID)) > RoleRank(c.RoleOf(targetID))
+}
+
+// IsPinned reports whether the message is pinned in the conversation
+func (c *Conversation) IsPinned(messageID string) bool {
+	for _, id := range c.PinnedMessages {
+		if id == messageID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+package entity
+
+import "testing"
+
+func newTestGroup() Conversation {
+	return Conversation{
+		Type:      ConversationTypeGroup,
+		CreatedBy: "owner",
+		Members: []ConversationMember{
+			{UserID: "owner", Role: ConversationRoleOwner},
+			{UserID: "admin", Role: ConversationRoleAdmin},
+			{UserID: "mod", Role: ConversationRoleModerator},
+			{UserID: "member", Role: ConversationRoleMember},
+			{UserID: "legacy"},
+		},
+	}
+}
+
+func TestRoleRank(t *testing.T) {
+	order := []string{"", ConversationRoleMember, ConversationRoleModerator, ConversationRoleAdmin, ConversationRoleOwner}
+	for i, role := range order {
+		if got := RoleRank(role); got != i {
+			t.Errorf("RoleRank(%q) = %d, want %d", role, got, i)
+		}
+	}
+	if got := RoleRank("superuser"); got != 0 {
+		t.Errorf("RoleRank(unknown) = %d, want 0", got)
+	}
+}
+
+func TestRoleOf(t *testing.T) {
+	conv := newTestGroup()
+	tests := []struct {
+		userID string
+		want   string
+	}{
+		{"owner", ConversationRoleOwner},
+		{"admin", ConversationRoleAdmin},
+		{"mod", ConversationRoleModerator},
+		{"member", ConversationRoleMember},
+		{"legacy", ConversationRoleMember},
+		{"stranger", ""},
+	}
+	for _, tt := range tests {
+		if got := conv.RoleOf(tt.userID); got != tt.want {
+			t.Errorf("RoleOf(%q) = %q, want %q", tt.userID, got, tt.want)
+		}
+	}
+}
+
+func TestRoleOfFallsBackToCreator(t *testing.T) {
+	conv := Conversation{
+		Type:      ConversationTypeGroup,
+		CreatedBy: "creator",
+		Members:   []ConversationMember{{UserID: "creator"}, {UserID: "other"}},
+	}
+	if got := conv.RoleOf("creator"); got != ConversationRoleOwner {
+		t.Errorf("RoleOf(creator) = %q, want owner", got)
+	}
+	if got := conv.RoleOf("other"); got != ConversationRoleMember {
+		t.Errorf("RoleOf(other) = %q, want member", got)
+	}
+}
+
+func TestCanDefaultPermissions(t *testing.T) {
+	conv := newTestGroup()
+	tests := []struct {
+		userID     string
+		permission string
+		want       bool
+	}{
+		{"member", PermissionPost, true},
+		{"member", PermissionPin, false},
+		{"member", PermissionRemoveMembers, false},
+		{"mod", PermissionPin, true},
+		{"mod", PermissionDeleteOthersMessages, true},
+		{"mod", PermissionRename, false},
+		{"mod", PermissionInvite, false},
+		{"admin", PermissionRename, true},
+		{"admin", PermissionChangeAvatar, true},
+		{"admin", PermissionInvite, true},
+		{"owner", PermissionInvite, true},
+		{"owner", "unknown_permission", false},
+		{"stranger", PermissionPost, false},
+	}
+	for _, tt := range tests {
+		if got := conv.Can(tt.userID, tt.permission); got != tt.want {
+			t.Errorf("Can(%q, %q) = %v, want %v", tt.userID, tt.permission, got, tt.want)
+		}
+	}
+}
+
+func TestCanCustomPermissions(t *testing.T) {
+	conv := newTestGroup()
+	conv.Permissions = map[string]string{
+		PermissionPost:   ConversationRoleModerator,
+		PermissionInvite: ConversationRoleMember,
+	}
+	tests := []struct {
+		userID     string
+		permission string
+		want       bool
+	}{
+		{"member", PermissionPost, false},
+		{"mod", PermissionPost, true},
+		{"member", PermissionInvite, true},
+		{"member", PermissionPin, false},
+		{"mod", PermissionPin, true},
+	}
+	for _, tt := range tests {
+		if got := conv.Can(tt.userID, tt.permission); got != tt.want {
+			t.Errorf("Can(%q, %q) = %v, want %v", tt.userID, tt.permission, got, tt.want)
+		}
+	}
+}
+
+func TestCanDirectConversation(t *testing.T) {
+	conv := Conversation{
+		Type:    ConversationTypeDirect,
+		Members: []ConversationMember{{UserID: "a"}, {UserID: "b"}},
+	}
+	if !conv.Can("a", PermissionPost) {
+		t.Error("direct members should be able to post")
+	}
+	for _, permission := range []string{PermissionPin, PermissionRename, PermissionInvite, PermissionRemoveMembers} {
+		if conv.Can("a", permission) {
+			t.Errorf("direct member should not have %q", permission)
+		}
+	}
+	if conv.Can("c", PermissionPost) {
+		t.Error("non-members should not be able to post")
+	}
+}
+
+func TestOutranks(t *testing.T) {
+	conv := newTestGroup()
+	tests := []struct {
+		actor, target string
+		want          bool
+	}{
+		{"owner", "admin", true},
+		{"admin", "mod", true},
+		{"mod", "member", true},
+		{"mod", "legacy", true},
+		{"member", "legacy", false},
+		{"admin", "admin", false},
+		{"admin", "owner", false},
+		{"member", "stranger", true},
+		{"stranger", "member", false},
+	}
+	for _, tt := range tests {
+		if got := conv.Outranks(tt.actor, tt.target); got != tt.want {
+			t.Errorf("Outranks(%q, %q) = %v, want %v", tt.actor, tt.target, got, tt.want)
+		}
+	}
+}
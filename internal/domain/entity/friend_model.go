@@ -1,16 +1,39 @@
 package entity
 
-import "time"
+import (
+	"sort"
+	"time"
+)
+
+const (
+	FriendStatusPending  = "pending"  // UserID1 đã gửi lời mời cho UserID2
+	FriendStatusAccepted = "accepted" // Hai người là bạn bè
+	FriendStatusRejected = "rejected" // UserID2 đã từ chối, có thể gửi lại lời mời
+	FriendStatusBlocked  = "blocked"  // UserID1 chặn UserID2, mỗi chiều một bản ghi riêng
+)
 
 type Friend struct {
-	ID        string    `json:"id" bson:"_id"`
-	UserID1   string    `json:"user_id_1" bson:"user_id_1"`
-	UserID2   string    `json:"user_id_2" bson:"user_id_2"`
-	Status    string    `json:"status" bson:"status"` // "pending", "accepted", "blocked"
-	CreatedAt time.Time `json:"created_at" bson:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+	ID         string     `json:"id" bson:"_id"`
+	UserID1    string     `json:"user_id_1" bson:"user_id_1"`
+	UserID2    string     `json:"user_id_2" bson:"user_id_2"`
+	PairKey    string     `json:"-" bson:"pair_key,omitempty"` // Khóa duy nhất cho mỗi cặp user, không dùng cho block
+	Status     string     `json:"status" bson:"status"`        // "pending", "accepted", "rejected", "blocked"
+	AcceptedAt *time.Time `json:"accepted_at,omitempty" bson:"accepted_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at" bson:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" bson:"updated_at"`
 }
 
+// FriendPairKey returns the same key for a pair regardless of who sent the request
+func FriendPairKey(userID1, userID2 string) string {
+	ids := []string{userID1, userID2}
+	sort.Strings(ids)
+	return ids[0] + ":" + ids[1]
+}
 
-
-
+// OtherUserID returns the user on the other side of the relationship
+func (f Friend) OtherUserID(userID string) string {
+	if f.UserID1 == userID {
+		return f.UserID2
+	}
+	return f.UserID1
+}
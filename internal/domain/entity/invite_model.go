@@ -0,0 +1,40 @@
+package entity
+
+import "time"
+
+type ConversationInvite struct {
+	ID               string     `json:"id" bson:"_id"`
+	ConversationID   string     `json:"conversation_id" bson:"conversation_id"`
+	Token            string     `json:"token" bson:"token"`
+	CreatedBy        string     `json:"created_by" bson:"created_by"`
+	ExpiresAt        *time.Time `json:"expires_at,omitempty" bson:"expires_at,omitempty"` // nil = không hết hạn
+	MaxUses          int        `json:"max_uses" bson:"max_uses"`                         // 0 = không giới hạn
+	Uses             int        `json:"uses" bson:"uses"`
+	RequiresApproval bool       `json:"requires_approval" bson:"requires_approval"` // Người dùng link phải chờ admin duyệt
+	RevokedAt        *time.Time `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at" bson:"created_at"`
+}
+
+// IsUsable reports whether the invite can still be redeemed at the given time
+func (i *ConversationInvite) IsUsable(now time.Time) bool {
+	if i.RevokedAt != nil {
+		return false
+	}
+	if i.ExpiresAt != nil && !now.Before(*i.ExpiresAt) {
+		return false
+	}
+	return i.MaxUses == 0 || i.Uses < i.MaxUses
+}
+
+const (
+	InviteRedemptionJoined  = "joined"
+	InviteRedemptionPending = "pending" // Chờ admin duyệt
+)
+
+// InviteRedemption is the audit record of one use of an invite link
+type InviteRedemption struct {
+	InviteID   string    `json:"invite_id" bson:"invite_id"`
+	UserID     string    `json:"user_id" bson:"user_id"`
+	Status     string    `json:"status" bson:"status"`
+	RedeemedAt time.Time `json:"redeemed_at" bson:"redeemed_at"`
+}
@@ -0,0 +1,22 @@
+package entity
+
+import "time"
+
+const (
+	JoinRequestPending  = "pending"
+	JoinRequestApproved = "approved"
+	JoinRequestRejected = "rejected"
+)
+
+type JoinRequest struct {
+	ID             string     `json:"id" bson:"_id"`
+	ConversationID string     `json:"conversation_id" bson:"conversation_id"`
+	UserID         string     `json:"user_id" bson:"user_id"`                         // Người xin vào nhóm
+	Note           string     `json:"note,omitempty" bson:"note,omitempty"`           // Lời nhắn cho admin
+	InviteID       string     `json:"invite_id,omitempty" bson:"invite_id,omitempty"` // Link mời đã dùng (nếu có)
+	Status         string     `json:"status" bson:"status"`                           // "pending", "approved", "rejected"
+	ReviewedBy     string     `json:"reviewed_by,omitempty" bson:"reviewed_by,omitempty"`
+	ReviewedAt     *time.Time `json:"reviewed_at,omitempty" bson:"reviewed_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at" bson:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" bson:"updated_at"`
+}
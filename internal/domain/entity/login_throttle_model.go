@@ -0,0 +1,35 @@
+package entity
+
+import "time"
+
+// What a login throttle counts failures for
+const (
+	LoginThrottleAccount = "account" // Theo email, kể cả email không tồn tại
+	LoginThrottleIP      = "ip"
+)
+
+// LoginThrottle counts recent failed logins for one email or one IP address.
+// Attempts are counted before the credentials are checked, so Failures includes those in progress.
+type LoginThrottle struct {
+	ID            string     `json:"id" bson:"_id"` // "<kind>:<subject>"
+	Kind          string     `json:"kind" bson:"kind"`
+	Subject       string     `json:"subject" bson:"subject"` // Email (chữ thường) hoặc IP
+	Failures      int        `json:"failures" bson:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at" bson:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty" bson:"locked_until,omitempty"` // Không cho đăng nhập trước thời điểm này
+	ExpiresAt     time.Time  `json:"expires_at" bson:"expires_at"`                         // Bộ đếm được reset sau thời điểm này
+	Allowed       bool       `json:"-" bson:"allowed"`                                     // Lần thử gần nhất có được cho phép (và được đếm) không
+}
+
+// LoginThrottleID returns the document ID of the counter for kind and subject
+func LoginThrottleID(kind, subject string) string {
+	return kind + ":" + subject
+}
+
+// RetryAfter returns how long logins stay refused, zero when they are allowed
+func (t LoginThrottle) RetryAfter(now time.Time) time.Duration {
+	if t.LockedUntil == nil || !now.Before(*t.LockedUntil) {
+		return 0
+	}
+	return t.LockedUntil.Sub(now)
+}
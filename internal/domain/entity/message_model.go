@@ -10,6 +10,7 @@ const (
 	MessageTypeVideo MessageType = "video"
 	MessageTypeFile  MessageType = "file"
 	MessageTypeAudio MessageType = "audio"
+	MessageTypeSystem MessageType = "system" // Thông báo thay đổi thành viên, do server tạo
 )
 
 type MessageStatus string
@@ -38,6 +39,11 @@ type ReadReceipt struct {
 	ReadAt    time.Time `json:"read_at" bson:"read_at"`
 }
 
+type MessageRevision struct {
+	Content  string    `json:"content" bson:"content"`
+	EditedAt time.Time `json:"edited_at" bson:"edited_at"` // Thời điểm nội dung này bị thay thế
+}
+
 type Message struct {
 	ID             string             `json:"id" bson:"_id"`
 	ConversationID string             `json:"conversation_id" bson:"conversation_id"` // Mới: thay thế chat_id và group_id
@@ -51,10 +57,29 @@ type Message struct {
 	Reactions      []MessageReaction   `json:"reactions,omitempty" bson:"reactions,omitempty"`
 	ReadReceipts   []ReadReceipt      `json:"read_receipts,omitempty" bson:"read_receipts,omitempty"`
 	Status         MessageStatus      `json:"status" bson:"status"`
+	EditHistory    []MessageRevision   `json:"edit_history,omitempty" bson:"edit_history,omitempty"` // Các phiên bản trước khi sửa
+	EditedAt       *time.Time          `json:"edited_at,omitempty" bson:"edited_at,omitempty"`
+	DeletedAt      *time.Time          `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`   // Thu hồi với mọi người (tombstone)
+	DeletedFor     []string            `json:"deleted_for,omitempty" bson:"deleted_for,omitempty"` // Users đã xóa message ở phía mình
 	CreatedAt      time.Time          `json:"time" bson:"created_at"`
 	UpdatedAt      time.Time          `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
 }
 
+// IsDeleted reports whether the message was deleted for everyone
+func (m *Message) IsDeleted() bool {
+	return m.DeletedAt != nil
+}
+
+// IsHiddenFor reports whether the user deleted the message on their side
+func (m *Message) IsHiddenFor(userID string) bool {
+	for _, id := range m.DeletedFor {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
 // GetConversationID returns conversation_id, or falls back to chat_id/group_id for backward compatibility
 func (m *Message) GetConversationID() string {
 	if m.ConversationID != "" {
@@ -0,0 +1,22 @@
+package entity
+
+import "time"
+
+// Security event types
+const (
+	SecurityEventLoginLocked   = "login_locked"   // Quá nhiều lần đăng nhập sai
+	SecurityEventLoginUnlocked = "login_unlocked" // Admin mở khóa
+)
+
+// SecurityEvent is an audit record of an account protection event
+type SecurityEvent struct {
+	ID          string     `json:"id" bson:"_id"`
+	Type        string     `json:"type" bson:"type"`
+	Email       string     `json:"email,omitempty" bson:"email,omitempty"`
+	IP          string     `json:"ip,omitempty" bson:"ip,omitempty"`
+	UserID      string     `json:"user_id,omitempty" bson:"user_id,omitempty"`   // User có email này, nếu tồn tại
+	ActorID     string     `json:"actor_id,omitempty" bson:"actor_id,omitempty"` // Admin thực hiện hành động
+	Failures    int        `json:"failures,omitempty" bson:"failures,omitempty"`
+	LockedUntil *time.Time `json:"locked_until,omitempty" bson:"locked_until,omitempty"`
+	CreatedAt   time.Time  `json:"created_at" bson:"created_at"`
+}
@@ -0,0 +1,25 @@
+package entity
+
+import "time"
+
+// Session is one login of a user on a device. Access tokens carry the session ID,
+// so revoking the session invalidates them before they expire.
+type Session struct {
+	ID                string     `json:"id" bson:"_id"`
+	UserID            string     `json:"user_id" bson:"user_id"`
+	RefreshTokenHash  string     `json:"-" bson:"refresh_token_hash"`            // SHA-256 của refresh token hiện tại
+	PreviousTokenHash string     `json:"-" bson:"previous_token_hash,omitempty"` // Refresh token vừa bị thay, dùng lại nghĩa là token đã bị lộ
+	DeviceName        string     `json:"device_name" bson:"device_name"`
+	IP                string     `json:"ip" bson:"ip"`
+	UserAgent         string     `json:"user_agent" bson:"user_agent"`
+	CreatedAt         time.Time  `json:"created_at" bson:"created_at"`
+	LastUsedAt        time.Time  `json:"last_used_at" bson:"last_used_at"`
+	ExpiresAt         time.Time  `json:"expires_at" bson:"expires_at"` // Hết hạn refresh token, MongoDB tự xóa session sau thời điểm này
+	RevokedAt         *time.Time `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+	RevokedReason     string     `json:"revoked_reason,omitempty" bson:"revoked_reason,omitempty"`
+}
+
+// IsActive reports whether the session can still be used
+func (s Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
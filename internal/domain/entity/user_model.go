@@ -10,10 +10,157 @@ type User struct {
 	FullName      string    `json:"-" bson:"full_name"` // Internal field, map to Name
 	Password      string    `json:"password,omitempty" bson:"password" validate:"required"`
 	Avatar        string    `json:"avatar,omitempty" bson:"avatar,omitempty"`
+	Bio           string    `json:"bio,omitempty" bson:"bio,omitempty"`
 	Online        bool      `json:"online,omitempty" bson:"online,omitempty"`
-	Friends       []string  `json:"friends,omitempty" bson:"friends,omitempty"`                    // Danh sách bạn bè đã chấp nhận
-	SentRequests  []string  `json:"sent_requests,omitempty" bson:"sent_requests,omitempty"`        // Lời mời đã gửi
-	PendingRequests []string `json:"pending_requests,omitempty" bson:"pending_requests,omitempty"`  // Lời mời đang chờ
+	LastSeenAt    *time.Time `json:"lastSeenAt,omitempty" bson:"last_seen_at,omitempty"` // Lần cuối ngắt kết nối
+	Role          string    `json:"role,omitempty" bson:"role,omitempty"` // "admin" hoặc rỗng
+	// nil với tài khoản tạo trước khi có xác thực email, các tài khoản này được coi là đã xác thực
+	EmailVerified   *bool      `json:"emailVerified,omitempty" bson:"email_verified,omitempty"`
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt,omitempty" bson:"email_verified_at,omitempty"`
+	TwoFactor       *TwoFactorSettings `json:"twoFactor,omitempty" bson:"two_factor,omitempty"`
+	Privacy         *PrivacySettings   `json:"-" bson:"privacy,omitempty"` // nil: dùng DefaultPrivacySettings
+	Presence        *PresenceSettings  `json:"presence,omitempty" bson:"presence,omitempty"`
+	// Legacy: quan hệ bạn bè nay lưu ở collection friends, các mảng này chỉ còn được đọc bởi cmd/migrate-friends
+	Friends       []string  `json:"-" bson:"friends,omitempty"`
+	SentRequests  []string  `json:"-" bson:"sent_requests,omitempty"`
+	PendingRequests []string `json:"-" bson:"pending_requests,omitempty"`
 	CreatedAt     time.Time `json:"createdAt" bson:"created_at"`
 	UpdatedAt     time.Time `json:"updatedAt" bson:"updated_at"`
 }
+
+// UserRoleAdmin can unlock accounts and read security events. It is granted directly in the database.
+const UserRoleAdmin = "admin"
+
+// TwoFactorSettings holds the TOTP configuration of a user. Secrets and recovery codes never leave the server.
+type TwoFactorSettings struct {
+	Enabled       bool       `json:"enabled" bson:"enabled"`
+	EnabledAt     *time.Time `json:"enabledAt,omitempty" bson:"enabled_at,omitempty"`
+	Secret        string     `json:"-" bson:"secret,omitempty"`
+	PendingSecret string     `json:"-" bson:"pending_secret,omitempty"` // Secret đang chờ xác nhận bằng mã đầu tiên
+	RecoveryCodes []string   `json:"-" bson:"recovery_codes,omitempty"` // SHA-256 của các mã khôi phục chưa dùng
+	LastUsedStep  int64      `json:"-" bson:"last_used_step,omitempty"` // Bước thời gian của mã TOTP dùng gần nhất, chống dùng lại
+}
+
+// Privacy levels: who can see a profile field or send friend requests
+const (
+	PrivacyEveryone         = "everyone"
+	PrivacyFriends          = "friends"
+	PrivacyFriendsOfFriends = "friends_of_friends" // Chỉ dùng cho FriendRequests
+	PrivacyNobody           = "nobody"
+)
+
+// PrivacySettings controls what other users see of a profile and who may send friend requests.
+// The user always sees their own profile in full.
+type PrivacySettings struct {
+	Email          string `json:"email" bson:"email,omitempty"`
+	OnlineStatus   string `json:"onlineStatus" bson:"online_status,omitempty"`
+	LastSeen       string `json:"lastSeen" bson:"last_seen,omitempty"`
+	Avatar         string `json:"avatar" bson:"avatar,omitempty"`
+	FriendRequests string `json:"friendRequests" bson:"friend_requests,omitempty"`
+}
+
+// DefaultPrivacySettings apply to accounts that never changed their settings
+func DefaultPrivacySettings() PrivacySettings {
+	return PrivacySettings{
+		Email:          PrivacyFriends,
+		OnlineStatus:   PrivacyFriends,
+		LastSeen:       PrivacyFriends,
+		Avatar:         PrivacyEveryone,
+		FriendRequests: PrivacyEveryone,
+	}
+}
+
+// EffectivePrivacy returns the user's settings with unset fields taken from the defaults
+func (u User) EffectivePrivacy() PrivacySettings {
+	settings := DefaultPrivacySettings()
+	if u.Privacy == nil {
+		return settings
+	}
+	if u.Privacy.Email != "" {
+		settings.Email = u.Privacy.Email
+	}
+	if u.Privacy.OnlineStatus != "" {
+		settings.OnlineStatus = u.Privacy.OnlineStatus
+	}
+	if u.Privacy.LastSeen != "" {
+		settings.LastSeen = u.Privacy.LastSeen
+	}
+	if u.Privacy.Avatar != "" {
+		settings.Avatar = u.Privacy.Avatar
+	}
+	if u.Privacy.FriendRequests != "" {
+		settings.FriendRequests = u.Privacy.FriendRequests
+	}
+	return settings
+}
+
+// PrivacyAllows reports whether a field with the given level is visible to a friend or a stranger
+func PrivacyAllows(level string, isFriend bool) bool {
+	switch level {
+	case PrivacyEveryone:
+		return true
+	case PrivacyFriends:
+		return isFriend
+	}
+	return false
+}
+
+// Presence statuses. Online and away follow the user's connections, dnd and invisible are chosen by the user.
+const (
+	PresenceOnline    = "online"
+	PresenceAway      = "away" // Mọi kết nối đều không hoạt động quá thời gian cho phép
+	PresenceDND       = "dnd"
+	PresenceInvisible = "invisible" // Người khác thấy là offline
+	PresenceOffline   = "offline"
+)
+
+// PresenceSettings is the status the user chose and their custom status text
+type PresenceSettings struct {
+	Status                string     `json:"status" bson:"status,omitempty"` // online (tự động online/away), dnd hoặc invisible
+	CustomStatus          string     `json:"customStatus,omitempty" bson:"custom_status,omitempty"`
+	CustomStatusExpiresAt *time.Time `json:"customStatusExpiresAt,omitempty" bson:"custom_status_expires_at,omitempty"`
+}
+
+// ChosenPresence returns the status the user picked, online when they never did
+func (u User) ChosenPresence() string {
+	if u.Presence == nil || u.Presence.Status == "" {
+		return PresenceOnline
+	}
+	return u.Presence.Status
+}
+
+// VisiblePresence combines connectionState (online, away or offline) with the status the user chose
+func (u User) VisiblePresence(connectionState string) string {
+	if connectionState == PresenceOffline {
+		return PresenceOffline
+	}
+	switch u.ChosenPresence() {
+	case PresenceInvisible:
+		return PresenceOffline
+	case PresenceDND:
+		return PresenceDND
+	}
+	return connectionState
+}
+
+// ActiveCustomStatus returns the custom status text and its expiry, or nothing once it has expired
+func (u User) ActiveCustomStatus(now time.Time) (string, *time.Time) {
+	if u.Presence == nil || u.Presence.CustomStatus == "" {
+		return "", nil
+	}
+	if u.Presence.CustomStatusExpiresAt != nil && !now.Before(*u.Presence.CustomStatusExpiresAt) {
+		return "", nil
+	}
+	return u.Presence.CustomStatus, u.Presence.CustomStatusExpiresAt
+}
+
+// IsTwoFactorEnabled reports whether login requires a second factor
+func (u User) IsTwoFactorEnabled() bool {
+	return u.TwoFactor != nil && u.TwoFactor.Enabled
+}
+
+// IsEmailVerified reports whether the user has confirmed their email address.
+// Accounts registered before verification existed have no flag and are trusted.
+func (u User) IsEmailVerified() bool {
+	return u.EmailVerified == nil || *u.EmailVerified
+}
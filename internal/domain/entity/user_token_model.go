@@ -0,0 +1,24 @@
+package entity
+
+import "time"
+
+// Purposes of single-use tokens
+const (
+	UserTokenVerifyEmail    = "verify_email"
+	UserTokenResetPassword  = "reset_password"
+	UserTokenLoginChallenge = "login_challenge" // Bước thứ hai của đăng nhập khi bật 2FA
+)
+
+// UserToken is a single-use token sent by email or handed out during login. Only its hash is stored,
+// the token itself is only known to the user.
+type UserToken struct {
+	ID        string     `json:"id" bson:"_id"`
+	UserID    string     `json:"user_id" bson:"user_id"`
+	Purpose   string     `json:"purpose" bson:"purpose"`
+	TokenHash string     `json:"-" bson:"token_hash"` // SHA-256 của token
+	Email     string     `json:"email" bson:"email"`  // Email tại thời điểm gửi, token hết hiệu lực nếu email đổi
+	CreatedAt time.Time  `json:"created_at" bson:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at" bson:"expires_at"` // MongoDB tự xóa token sau thời điểm này
+	UsedAt    *time.Time `json:"used_at,omitempty" bson:"used_at,omitempty"`
+	Attempts  int        `json:"attempts,omitempty" bson:"attempts,omitempty"` // Số lần nhập mã sai
+}
@@ -1,6 +1,10 @@
 package domain
 
-import "github.com/TomTom2k/chat-app/server/internal/domain/entity"
+import (
+	"time"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+)
 
 type UserRepository interface {
 	CreateUser(user entity.User) error
@@ -9,13 +13,21 @@ type UserRepository interface {
 	GetByID(id string) (entity.User, error)
 	SearchUsers(query string) ([]entity.User, error)
 	UpdateUser(user entity.User) error
-	AddFriend(userID1, userID2 string) error
-	RemoveFriend(userID1, userID2 string) error
-	AddSentRequest(userID, targetUserID string) error
-	RemoveSentRequest(userID, targetUserID string) error
-	AddPendingRequest(userID, senderUserID string) error
-	RemovePendingRequest(userID, senderUserID string) error
 	GetUsersByIDs(userIDs []string) ([]entity.User, error)
+	MarkEmailVerified(userID string, at time.Time) error
+	UpdatePassword(userID, hashedPassword string) error
+	UpdateProfile(userID string, update ProfileUpdate) error // Lỗi nếu username đã có người dùng
+	UpdatePrivacy(userID string, settings entity.PrivacySettings) error
+	UpdatePresenceSettings(userID string, settings entity.PresenceSettings) error
+	// Khi offline, at được lưu làm last_seen_at
+	SetPresence(userID string, online bool, at time.Time) error
+	SetPendingTwoFactorSecret(userID, secret string) error // Lỗi nếu 2FA đã bật
+	// Bật 2FA với secret đang chờ nếu nó vẫn là pendingSecret
+	EnableTwoFactor(userID, pendingSecret string, recoveryCodeHashes []string, at time.Time) error
+	DisableTwoFactor(userID string) error
+	UseTwoFactorStep(userID string, step int64) error // Lỗi nếu mã của bước này hoặc bước sau đã được dùng
+	ConsumeRecoveryCode(userID, codeHash string) error
+	ReplaceRecoveryCodes(userID string, codeHashes []string) error
 }
 
 type ConversationRepository interface {
@@ -26,27 +38,170 @@ type ConversationRepository interface {
 	UpdateConversation(conversation entity.Conversation) error
 	AddMember(conversationID, userID, role string) error
 	RemoveMember(conversationID, userID string) error
+	UpdateMemberRole(conversationID, userID, role string) error
+	TransferOwnership(conversationID, fromUserID, toUserID string) error // Người cũ thành admin, người mới thành owner
+	UpdatePermissions(conversationID string, permissions map[string]string) error
+	UpdateGroupInfo(conversationID string, update GroupInfoUpdate) error
+	PinMessage(conversationID, messageID string) error
+	UnpinMessage(conversationID, messageID string) error
+	RecordInviteRedemption(conversationID string, redemption entity.InviteRedemption) error
+	RecordNewMessage(conversationID, senderID, messageID, preview string, sentAt time.Time) error // Cập nhật last message, tăng unread cho các member khác
+	SetLastMessage(conversationID, preview string, at time.Time) error
+	// Giảm unread của các member (trừ người gửi) có read cursor trước message đã bị xóa
+	ForgetUnreadMessage(conversationID, senderID, messageID string, sentAt time.Time) error
+	// User ID -> số nhóm chung với userID, bỏ qua excludedIDs
+	CountSharedGroups(userID string, excludedIDs []string) (map[string]int, error)
+	UpdateReadCursor(conversationID, userID, messageID string, readAt time.Time, unreadCount int) error
 }
 
 type MessageRepository interface {
-	CreateMessage(message entity.Message) error
+	CreateMessage(message entity.Message) (string, error) // Trả về ID của message vừa tạo
 	GetMessagesByConversationID(conversationID string) ([]entity.Message, error)
+	GetMessagesPage(conversationID string, query MessageQuery) ([]entity.Message, bool, error) // Trả về messages (tăng dần theo thời gian) và hasMore
 	GetMessageByID(messageID string) (entity.Message, error)
 	UpdateMessage(message entity.Message) error
+	EditMessage(messageID, content string, revision entity.MessageRevision) error // Lỗi "message has been deleted" nếu message đã bị xóa
+	DeleteMessageForEveryone(messageID string) (bool, error) // false nếu message đã bị xóa trước đó
+	HideMessageForUser(messageID, userID string) error
+	// Thêm read receipt cho các message sau cursor cũ (after) tới upTo, tối đa một số message gần nhất; read cursor của member mới là nguồn chính
+	MarkMessagesReadUpTo(conversationID, userID string, after *MessageCursor, upTo MessageCursor) error
+	CountUnread(conversationID, userID string, after *MessageCursor) (int, error)
 	AddReaction(messageID, userID, emoji string) error
 	RemoveReaction(messageID, userID, emoji string) error
 	MarkAsRead(messageID, userID string) error
 	MarkAsDelivered(messageID string) error
 }
 
+type InviteRepository interface {
+	CreateInvite(invite entity.ConversationInvite) error
+	GetInviteByID(inviteID string) (entity.ConversationInvite, error)
+	GetInviteByToken(token string) (entity.ConversationInvite, error)
+	GetInvitesByConversationID(conversationID string) ([]entity.ConversationInvite, error)
+	ConsumeInvite(inviteID string, now time.Time) error // Tăng uses nếu link còn hiệu lực, lỗi nếu không
+	RevokeInvite(inviteID string) error
+}
+
+type JoinRequestRepository interface {
+	CreateJoinRequest(request entity.JoinRequest) error
+	GetJoinRequestByID(requestID string) (entity.JoinRequest, error)
+	GetPendingJoinRequest(conversationID, userID string) (entity.JoinRequest, error)
+	GetPendingJoinRequestsByConversationID(conversationID string) ([]entity.JoinRequest, error)
+	ReviewJoinRequest(requestID, status, reviewerID string) error // Chỉ áp dụng cho request đang pending
+}
+
 type FriendRepository interface {
 	CreateFriend(friend entity.Friend) error
 	GetFriendsByUserID(userID string) ([]entity.Friend, error)
+	GetFriendUserIDs(userID string) ([]string, error) // ID các user đã là bạn của userID
 	GetFriendByID(friendID string) (entity.Friend, error)
 	GetFriendByUserIDs(userID1, userID2 string) (entity.Friend, error)
 	GetPendingRequestsByUserID(userID string) ([]entity.Friend, error) // Lời mời nhận được (userID là UserID2)
-	GetSentRequestsByUserID(userID string) ([]entity.Friend, error)     // Lời mời đã gửi (userID là UserID1)
+	GetSentRequestsByUserID(userID string) ([]entity.Friend, error)    // Lời mời đã gửi (userID là UserID1)
 	DeleteFriend(friendID string) error
 	UpdateFriend(friend entity.Friend) error
+	UpdateFriendStatus(friendID, fromStatus, toStatus string) error  // Chỉ áp dụng khi bản ghi đang ở fromStatus
+	ReopenFriendRequest(friendID, senderID, receiverID string) error // Gửi lại lời mời trên bản ghi đã bị từ chối
+	GetBlock(blockerID, blockedID string) (entity.Friend, error)
+	GetBlocksByUserID(blockerID string) ([]entity.Friend, error) // Những user mà blockerID đã chặn
+	GetBlockedUserIDs(userID string) ([]string, error)           // Users có block với userID theo bất kỳ chiều nào
+	IsBlocked(userID1, userID2 string) (bool, error)             // true nếu một trong hai đã chặn người kia
+	// Bạn của các user trong friendIDs (trừ excludedIDs), nhiều bạn chung nhất trước
+	GetMutualFriendCounts(friendIDs, excludedIDs []string, sampleSize int) ([]MutualFriendCount, error)
+}
+
+type SessionRepository interface {
+	CreateSession(session entity.Session) error
+	GetSessionByID(sessionID string) (entity.Session, error)
+	GetActiveSessionsByUserID(userID string) ([]entity.Session, error) // Mới hoạt động nhất trước
+	TouchSession(sessionID string, at time.Time) error
+	// Thay refresh token nếu hash hiện tại vẫn là currentHash, lỗi nếu token đã được dùng hoặc session bị thu hồi
+	RotateRefreshToken(sessionID, currentHash, newHash string, expiresAt time.Time, ip, userAgent string) error
+	RevokeSession(sessionID, reason string) error
+	RevokeSessionsByUserID(userID, reason string) ([]string, error) // Trả về IDs của các session vừa bị thu hồi
+	RevokeOtherSessions(userID, keepSessionID, reason string) ([]string, error)
+}
+
+type UserTokenRepository interface {
+	CreateToken(token entity.UserToken) error
+	// Đánh dấu token đã dùng nếu còn hiệu lực, lỗi nếu token sai, hết hạn hoặc đã được dùng
+	ConsumeToken(tokenHash, purpose string, now time.Time) (entity.UserToken, error)
+	InvalidateTokens(userID, purpose string) error                               // Vô hiệu các token chưa dùng, ví dụ khi gửi token mới
+	GetToken(tokenHash, purpose string, now time.Time) (entity.UserToken, error) // Token còn hiệu lực, không đánh dấu đã dùng
+	RecordFailedAttempt(tokenID string, maxAttempts int) error                   // Vô hiệu token sau maxAttempts lần sai
+}
+
+type LoginThrottleRepository interface {
+	// Đếm lần thử (reset nếu đã quá window) nếu nó không bị khóa và đã chờ đủ delays[min(failures, len(delays)-1)]
+	// sau lần thử trước, trong cùng một thao tác nguyên tử; Allowed của kết quả cho biết lần thử có được cho phép
+	RecordAttempt(kind, subject string, at time.Time, window time.Duration, delays []time.Duration) (entity.LoginThrottle, error)
+	ReleaseAttempt(id string) error // Bỏ đếm một lần thử đã thành công
+	LockUntil(id string, until time.Time) error
+	ClearThrottles(ids ...string) (int64, error)
+}
+
+type SecurityEventRepository interface {
+	CreateEvent(event entity.SecurityEvent) error
+	// Mới nhất trước; email hoặc ip rỗng thì không lọc theo trường đó
+	GetEvents(email, ip string, limit int) ([]entity.SecurityEvent, error)
+}
+
+// Mailer delivers transactional emails such as verification and password reset links
+type Mailer interface {
+	Send(mail Mail) error
+}
+
+// Mail is a plain text email
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// ProfileUpdate holds the profile fields to change; nil fields are left untouched
+type ProfileUpdate struct {
+	Name     *string
+	Username *string
+	Avatar   *string
+	Bio      *string
+}
+
+// PresenceUpdate holds the presence fields to change; nil fields are left untouched.
+// An empty CustomStatus clears the custom status and its expiry.
+type PresenceUpdate struct {
+	Status                *string
+	CustomStatus          *string
+	CustomStatusExpiresAt *time.Time
 }
 
+// GroupInfoUpdate holds the group fields to change; nil fields are left untouched
+type GroupInfoUpdate struct {
+	Name             *string
+	Description      *string
+	Avatar           *string
+	RequiresApproval *bool
+}
+
+// MessageCursor marks a position in a conversation's message history.
+// ID is optional and only breaks ties between messages sharing the same CreatedAt.
+type MessageCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// MessageQuery describes one page of message history.
+// Before and After are mutually exclusive; when neither is set the latest page is returned.
+type MessageQuery struct {
+	Before      *MessageCursor
+	After       *MessageCursor
+	Limit       int
+	ViewerID    string // Bỏ qua messages mà user này đã xóa ở phía mình
+	SkipDeleted bool   // Bỏ qua messages đã thu hồi với mọi người
+}
+
+// MutualFriendCount is a friend of friends together with how many friends they share.
+// Sample holds up to sampleSize of those shared friends.
+type MutualFriendCount struct {
+	UserID string   `bson:"_id"`
+	Count  int      `bson:"count"`
+	Sample []string `bson:"sample"`
+}
@@ -1,13 +1,18 @@
 package di
 
 import (
+	"log"
+
 	"github.com/TomTom2k/chat-app/server/internal/config"
 	"github.com/TomTom2k/chat-app/server/internal/domain"
+	"github.com/TomTom2k/chat-app/server/internal/infrastructure/mailer"
+	"github.com/TomTom2k/chat-app/server/internal/infrastructure/mongodb"
 	"github.com/TomTom2k/chat-app/server/internal/infrastructure/repository"
 	"github.com/TomTom2k/chat-app/server/internal/infrastructure/websocket"
 	"github.com/TomTom2k/chat-app/server/internal/interface/http"
 	wsHandler "github.com/TomTom2k/chat-app/server/internal/interface/websocket"
 	"github.com/TomTom2k/chat-app/server/internal/usecase"
+	"github.com/TomTom2k/chat-app/server/pkg/jwt"
 )
 
 // Container holds all dependencies
@@ -17,6 +22,13 @@ type Container struct {
 	ConversationRepository domain.ConversationRepository
 	MessageRepository   domain.MessageRepository
 	FriendRepository    domain.FriendRepository
+	InviteRepository       domain.InviteRepository
+	JoinRequestRepository  domain.JoinRequestRepository
+	SessionRepository      domain.SessionRepository
+	UserTokenRepository    domain.UserTokenRepository
+	LoginThrottleRepository domain.LoginThrottleRepository
+	SecurityEventRepository domain.SecurityEventRepository
+	Mailer                 domain.Mailer
 	
 	UserUseCase         *usecase.UserUseCase
 	ConversationUseCase *usecase.ConversationUseCase
@@ -37,32 +49,91 @@ func NewContainer(cfg *config.Config) *Container {
 	conversationRepo := repository.NewConversationRepository()
 	messageRepo := repository.NewMessageRepository()
 	friendRepo := repository.NewFriendRepository()
+	inviteRepo := repository.NewInviteRepository()
+	joinRequestRepo := repository.NewJoinRequestRepository()
+	sessionRepo := repository.NewSessionRepository()
+	userTokenRepo := repository.NewUserTokenRepository()
+	loginThrottleRepo := repository.NewLoginThrottleRepository()
+	securityEventRepo := repository.NewSecurityEventRepository()
+
+	// Verification and password reset links go out by email
+	var mail domain.Mailer = mailer.NewLogMailer(cfg.MailDir, cfg.MailFrom)
+	if cfg.Mailer == "smtp" {
+		mail = mailer.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+	}
+
+	// Load the keys access tokens are signed and verified with
+	verifyKeys, err := jwt.ParseVerificationKeys(cfg.JWTVerifyKeys)
+	if err != nil {
+		log.Fatal("[ERROR]: ", err)
+	}
+	keyring, err := jwt.LoadKeyring(jwt.KeyringOptions{
+		Algorithm:        cfg.JWTAlgorithm,
+		KeyID:            cfg.JWTKeyID,
+		Secret:           cfg.JWTSecret,
+		PrivateKeyFile:   cfg.JWTPrivateKeyFile,
+		VerificationKeys: verifyKeys,
+	})
+	if err != nil {
+		log.Fatal("[ERROR]: ", err)
+	}
+	log.Printf("[INFO]: signing access tokens with %s key %s", cfg.JWTAlgorithm, keyring.SigningKeyID())
 
 	// Initialize usecases
 	userUseCase := &usecase.UserUseCase{
 		Repo:      userRepo,
-		JWTSecret: cfg.JWTSecret,
+		SessionRepo:     sessionRepo,
+		TokenRepo:        userTokenRepo,
+		Tokens:          keyring,
+		Mailer:           mail,
+		AppBaseURL:       cfg.AppBaseURL,
+		AccessTokenTTL:  cfg.AccessTokenTTL,
+		RefreshTokenTTL: cfg.RefreshTokenTTL,
+		EmailVerifyTTL:   cfg.EmailVerifyTTL,
+		PasswordResetTTL: cfg.PasswordResetTTL,
+		TOTPIssuer:       cfg.TOTPIssuer,
+		ThrottleRepo:      loginThrottleRepo,
+		SecurityEventRepo: securityEventRepo,
+		LoginPolicy: usecase.LoginPolicy{
+			MaxAccountFailures: cfg.LoginMaxFailures,
+			MaxIPFailures:      cfg.LoginMaxIPFailures,
+			LockoutDuration:    cfg.LoginLockout,
+			FailureWindow:      cfg.LoginFailureWindow,
+		},
 	}
 
 	// Initialize WebSocket Hub first (needed by use cases)
-	hub := websocket.NewHub(userRepo, conversationRepo, messageRepo)
+	var backplane websocket.Backplane = websocket.NewMemoryBackplane()
+	if cfg.HubBackplane == "mongodb" {
+		backplane = websocket.NewMongoBackplane(mongodb.OpenCollection("hub_events"))
+	}
+	hub := websocket.NewHub(userRepo, conversationRepo, messageRepo, backplane)
+	hub.FriendRepo = friendRepo
+	userUseCase.Hub = hub
 	go hub.Run()
 
 	conversationUseCase := &usecase.ConversationUseCase{
 		ConversationRepo: conversationRepo,
 		UserRepo:         userRepo,
 		MessageRepo:      messageRepo,
+		InviteRepo:        inviteRepo,
+		JoinRequestRepo:   joinRequestRepo,
+		FriendRepo:        friendRepo,
 		Hub:              hub,
+		MessageEditWindow: cfg.MessageEditWindow,
 	}
 
 	friendUseCase := &usecase.FriendUseCase{
 		UserRepo: userRepo,
+		FriendRepo: friendRepo,
+		ConversationRepo: conversationRepo,
 		Hub:      hub,
 	}
 
 	// Initialize handlers
 	userHandler := &http.UserHandler{
 		UserUseCase: *userUseCase,
+		Hub:         hub,
 	}
 
 	conversationHandler := &http.ConversationHandler{
@@ -75,10 +146,17 @@ func NewContainer(cfg *config.Config) *Container {
 		FriendUseCase: *friendUseCase,
 	}
 
+	// Let clients send commands over the socket through the same use cases
+	hub.Commands = &wsHandler.CommandHandler{
+		ConversationUseCase: *conversationUseCase,
+		Hub:                 hub,
+	}
+
 	// Initialize WebSocket Handler
 	wsHandler := &wsHandler.WebSocketHandler{
 		Hub:    hub,
 		Config: cfg,
+		UserUseCase: userUseCase,
 	}
 
 	return &Container{
@@ -87,6 +165,13 @@ func NewContainer(cfg *config.Config) *Container {
 		ConversationRepository: conversationRepo,
 		MessageRepository:     messageRepo,
 		FriendRepository:      friendRepo,
+		InviteRepository:       inviteRepo,
+		JoinRequestRepository:  joinRequestRepo,
+		SessionRepository:      sessionRepo,
+		UserTokenRepository:    userTokenRepo,
+		LoginThrottleRepository: loginThrottleRepo,
+		SecurityEventRepository: securityEventRepo,
+		Mailer:                 mail,
 		UserUseCase:           userUseCase,
 		ConversationUseCase:    conversationUseCase,
 		FriendUseCase:          friendUseCase,
@@ -0,0 +1,44 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain"
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// LogMailer is meant for local development and tests: nothing leaves the machine.
+// With Dir set every mail is written there as an .eml file, otherwise it is printed to the log.
+type LogMailer struct {
+	Dir  string
+	From string
+}
+
+func NewLogMailer(dir, from string) domain.Mailer {
+	return &LogMailer{Dir: dir, From: from}
+}
+
+func (m *LogMailer) Send(mail domain.Mail) error {
+	if m.Dir == "" {
+		log.Printf("[MAIL]: to=%s subject=%q\n%s", mail.To, mail.Subject, mail.Body)
+		return nil
+	}
+
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return fmt.Errorf("mailer: unable to create %s: %w", m.Dir, err)
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102T150405.000000000"), unsafeFileChars.ReplaceAllString(mail.To, "_"))
+	path := filepath.Join(m.Dir, name)
+	if err := os.WriteFile(path, buildMessage(m.From, mail), 0o644); err != nil {
+		return fmt.Errorf("mailer: unable to write %s: %w", path, err)
+	}
+
+	log.Printf("[MAIL]: to=%s subject=%q written to %s", mail.To, mail.Subject, path)
+	return nil
+}
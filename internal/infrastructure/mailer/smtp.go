@@ -0,0 +1,59 @@
+package mailer
+
+import (
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain"
+)
+
+// SMTPMailer sends mail through an SMTP relay. STARTTLS is used whenever the server offers it.
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string // Để trống nếu relay không yêu cầu xác thực
+	Password string
+	From     string
+}
+
+func NewSMTPMailer(host, port, username, password, from string) domain.Mailer {
+	return &SMTPMailer{
+		Host:     host,
+		Port:     port,
+		Username: username,
+		Password: password,
+		From:     from,
+	}
+}
+
+func (m *SMTPMailer) Send(mail domain.Mail) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	addr := net.JoinHostPort(m.Host, m.Port)
+	if err := smtp.SendMail(addr, auth, m.From, []string{mail.To}, buildMessage(m.From, mail)); err != nil {
+		return fmt.Errorf("mailer: unable to send mail to %s: %w", mail.To, err)
+	}
+	return nil
+}
+
+// buildMessage renders a UTF-8 plain text message; the subject is encoded so Vietnamese text survives
+func buildMessage(from string, mail domain.Mail) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + mail.To + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", mail.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(mail.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
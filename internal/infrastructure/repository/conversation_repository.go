@@ -159,3 +159,257 @@ func (r *conversationRepository) RemoveMember(conversationID, userID string) err
 	return err
 }
 
+func (r *conversationRepository) UpdateMemberRole(conversationID, userID, role string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": conversationID, "members.user_id": userID},
+		bson.M{
+			"$set": bson.M{
+				"members.$.role": role,
+				"updated_at":     time.Now(),
+			},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("member not found")
+	}
+	return nil
+}
+
+func (r *conversationRepository) TransferOwnership(conversationID, fromUserID, toUserID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Single atomic update so the group never has zero or two owners
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": conversationID, "members.user_id": toUserID},
+		bson.M{
+			"$set": bson.M{
+				"members.$[from].role": entity.ConversationRoleAdmin,
+				"members.$[to].role":   entity.ConversationRoleOwner,
+				"updated_at":           time.Now(),
+			},
+		},
+		options.UpdateOne().SetArrayFilters([]interface{}{
+			bson.M{"from.user_id": fromUserID},
+			bson.M{"to.user_id": toUserID},
+		}),
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("member not found")
+	}
+	return nil
+}
+
+func (r *conversationRepository) UpdatePermissions(conversationID string, permissions map[string]string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": conversationID},
+		bson.M{"$set": bson.M{
+			"permissions": permissions,
+			"updated_at":  time.Now(),
+		}},
+	)
+	return err
+}
+
+func (r *conversationRepository) UpdateGroupInfo(conversationID string, update domain.GroupInfoUpdate) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	set := bson.M{"updated_at": time.Now()}
+	if update.Name != nil {
+		set["name"] = *update.Name
+	}
+	if update.Description != nil {
+		set["description"] = *update.Description
+	}
+	if update.Avatar != nil {
+		set["avatar"] = *update.Avatar
+	}
+	if update.RequiresApproval != nil {
+		set["requires_approval"] = *update.RequiresApproval
+	}
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": conversationID}, bson.M{"$set": set})
+	return err
+}
+
+func (r *conversationRepository) PinMessage(conversationID, messageID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": conversationID},
+		bson.M{
+			"$addToSet": bson.M{"pinned_messages": messageID},
+			"$set":      bson.M{"updated_at": time.Now()},
+		},
+	)
+	return err
+}
+
+func (r *conversationRepository) UnpinMessage(conversationID, messageID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": conversationID},
+		bson.M{
+			"$pull": bson.M{"pinned_messages": messageID},
+			"$set":  bson.M{"updated_at": time.Now()},
+		},
+	)
+	return err
+}
+
+// Only the most recent redemptions are kept on the conversation document
+const maxInviteRedemptions = 500
+
+func (r *conversationRepository) RecordInviteRedemption(conversationID string, redemption entity.InviteRedemption) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": conversationID},
+		bson.M{"$push": bson.M{
+			"invite_redemptions": bson.M{
+				"$each":  []entity.InviteRedemption{redemption},
+				"$slice": -maxInviteRedemptions,
+			},
+		}},
+	)
+	return err
+}
+
+func (r *conversationRepository) RecordNewMessage(conversationID, senderID, messageID, preview string, sentAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Single atomic update: bump unread for everyone except the sender, whose read cursor moves to their own message
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": conversationID},
+		bson.M{
+			"$set": bson.M{
+				"last_message":                           preview,
+				"last_message_time":                      sentAt,
+				"updated_at":                             time.Now(),
+				"members.$[sender].last_read_message_id": messageID,
+				"members.$[sender].last_read_at":         sentAt,
+				"members.$[sender].unread_count":         0,
+			},
+			"$inc": bson.M{"members.$[other].unread_count": 1},
+		},
+		options.UpdateOne().SetArrayFilters([]interface{}{
+			bson.M{"sender.user_id": senderID},
+			bson.M{"other.user_id": bson.M{"$ne": senderID}},
+		}),
+	)
+	return err
+}
+
+func (r *conversationRepository) SetLastMessage(conversationID, preview string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": conversationID},
+		bson.M{"$set": bson.M{
+			"last_message":      preview,
+			"last_message_time": at,
+			"updated_at":        time.Now(),
+		}},
+	)
+	return err
+}
+
+func (r *conversationRepository) ForgetUnreadMessage(conversationID, senderID, messageID string, sentAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Only members who had not read the message counted it: their cursor is before it,
+	// or they have no cursor and joined before it was sent
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": conversationID},
+		bson.M{"$inc": bson.M{"members.$[unread].unread_count": -1}},
+		options.UpdateOne().SetArrayFilters([]interface{}{
+			bson.M{
+				"unread.user_id":      bson.M{"$ne": senderID},
+				"unread.unread_count": bson.M{"$gt": 0},
+				"$or": []bson.M{
+					{"unread.last_read_at": bson.M{"$lt": sentAt}},
+					{"unread.last_read_at": sentAt, "unread.last_read_message_id": bson.M{"$lt": messageID}},
+					{"unread.last_read_at": bson.M{"$exists": false}, "unread.joined_at": bson.M{"$lte": sentAt}},
+				},
+			},
+		}),
+	)
+	return err
+}
+
+func (r *conversationRepository) UpdateReadCursor(conversationID, userID, messageID string, readAt time.Time, unreadCount int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": conversationID, "members.user_id": userID},
+		bson.M{"$set": bson.M{
+			"members.$.last_read_message_id": messageID,
+			"members.$.last_read_at":         readAt,
+			"members.$.unread_count":         unreadCount,
+		}},
+	)
+	return err
+}
+
+func (r *conversationRepository) CountSharedGroups(userID string, excludedIDs []string) (map[string]int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"type": entity.ConversationTypeGroup, "members.user_id": userID}}},
+		{{Key: "$unwind", Value: "$members"}},
+		{{Key: "$match", Value: bson.M{"members.user_id": bson.M{"$nin": excludedIDs}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$members.user_id", "count": bson.M{"$sum": 1}}}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		UserID string `bson:"_id"`
+		Count  int    `bson:"count"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.UserID] = row.Count
+	}
+	return counts, nil
+}
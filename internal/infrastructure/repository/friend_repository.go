@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"errors"
+	"log"
 	"time"
 
 	"github.com/TomTom2k/chat-app/server/internal/domain"
@@ -10,6 +11,7 @@ import (
 	"github.com/TomTom2k/chat-app/server/internal/infrastructure/mongodb"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
 type friendRepository struct {
@@ -17,24 +19,64 @@ type friendRepository struct {
 }
 
 func NewFriendRepository() domain.FriendRepository {
-	return &friendRepository{
+	r := &friendRepository{
 		collection: mongodb.OpenCollection("friends"),
 	}
+	r.ensureIndexes()
+	return r
+}
+
+// ensureIndexes also guarantees a single friendship record per pair of users and a single block per direction
+func (r *friendRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	models := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "pair_key", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"pair_key": bson.M{"$exists": true}}),
+		},
+		{
+			Keys: bson.D{{Key: "user_id_1", Value: 1}, {Key: "user_id_2", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"status": entity.FriendStatusBlocked}),
+		},
+		{Keys: bson.D{{Key: "user_id_1", Value: 1}, {Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id_2", Value: 1}, {Key: "status", Value: 1}}},
+	}
+	if _, err := r.collection.Indexes().CreateMany(ctx, models); err != nil {
+		log.Printf("[WARNING]: unable to create friend indexes: %v", err)
+	}
 }
 
 func (r *friendRepository) CreateFriend(friend entity.Friend) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	// Migrated records keep their original timestamps
 	now := time.Now()
-	friend.CreatedAt = now
+	if friend.CreatedAt.IsZero() {
+		friend.CreatedAt = now
+	}
 	friend.UpdatedAt = now
 
 	if friend.ID == "" {
 		friend.ID = generateID()
 	}
 
+	// Blocks are directional and may coexist with each other, every other status is one record per pair
+	if friend.Status != entity.FriendStatusBlocked {
+		friend.PairKey = entity.FriendPairKey(friend.UserID1, friend.UserID2)
+	}
+
 	_, err := r.collection.InsertOne(ctx, friend)
+	if mongo.IsDuplicateKeyError(err) {
+		if friend.Status == entity.FriendStatusBlocked {
+			return errors.New("user already blocked")
+		}
+		return errors.New("friendship already exists")
+	}
 	return err
 }
 
@@ -47,7 +89,7 @@ func (r *friendRepository) GetFriendsByUserID(userID string) ([]entity.Friend, e
 			{"user_id_1": userID},
 			{"user_id_2": userID},
 		},
-		"status": "accepted",
+		"status": entity.FriendStatusAccepted,
 	}
 
 	cursor, err := r.collection.Find(ctx, filter)
@@ -64,6 +106,19 @@ func (r *friendRepository) GetFriendsByUserID(userID string) ([]entity.Friend, e
 	return friends, nil
 }
 
+func (r *friendRepository) GetFriendUserIDs(userID string) ([]string, error) {
+	friends, err := r.GetFriendsByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]string, 0, len(friends))
+	for _, friend := range friends {
+		userIDs = append(userIDs, friend.OtherUserID(userID))
+	}
+	return userIDs, nil
+}
+
 func (r *friendRepository) GetFriendByID(friendID string) (entity.Friend, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -83,11 +138,13 @@ func (r *friendRepository) GetFriendByUserIDs(userID1, userID2 string) (entity.F
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	// Blocks are stored as separate records and never count as the pair's friendship
 	filter := bson.M{
 		"$or": []bson.M{
 			{"user_id_1": userID1, "user_id_2": userID2},
 			{"user_id_1": userID2, "user_id_2": userID1},
 		},
+		"status": bson.M{"$ne": entity.FriendStatusBlocked},
 	}
 
 	var friend entity.Friend
@@ -118,16 +175,66 @@ func (r *friendRepository) UpdateFriend(friend entity.Friend) error {
 	return err
 }
 
+func (r *friendRepository) UpdateFriendStatus(friendID, fromStatus, toStatus string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	set := bson.M{"status": toStatus, "updated_at": now}
+	if toStatus == entity.FriendStatusAccepted {
+		set["accepted_at"] = now
+	}
+
+	// The current status is part of the filter so concurrent accept/reject cannot both win
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": friendID, "status": fromStatus},
+		bson.M{"$set": set},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("request is not " + fromStatus)
+	}
+	return nil
+}
+
+func (r *friendRepository) ReopenFriendRequest(friendID, senderID, receiverID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": friendID, "status": entity.FriendStatusRejected},
+		bson.M{"$set": bson.M{
+			"user_id_1":  senderID,
+			"user_id_2":  receiverID,
+			"status":     entity.FriendStatusPending,
+			"created_at": now,
+			"updated_at": now,
+		}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("request is not rejected")
+	}
+	return nil
+}
+
 func (r *friendRepository) GetPendingRequestsByUserID(userID string) ([]entity.Friend, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	filter := bson.M{
 		"user_id_2": userID,
-		"status":    "pending",
+		"status":    entity.FriendStatusPending,
 	}
 
-	cursor, err := r.collection.Find(ctx, filter)
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
 	if err != nil {
 		return nil, err
 	}
@@ -147,10 +254,10 @@ func (r *friendRepository) GetSentRequestsByUserID(userID string) ([]entity.Frie
 
 	filter := bson.M{
 		"user_id_1": userID,
-		"status":    "pending",
+		"status":    entity.FriendStatusPending,
 	}
 
-	cursor, err := r.collection.Find(ctx, filter)
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
 	if err != nil {
 		return nil, err
 	}
@@ -164,6 +271,150 @@ func (r *friendRepository) GetSentRequestsByUserID(userID string) ([]entity.Frie
 	return friends, nil
 }
 
+func (r *friendRepository) GetBlock(blockerID, blockedID string) (entity.Friend, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"user_id_1": blockerID,
+		"user_id_2": blockedID,
+		"status":    entity.FriendStatusBlocked,
+	}
+
+	var block entity.Friend
+	err := r.collection.FindOne(ctx, filter).Decode(&block)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return entity.Friend{}, errors.New("block not found")
+		}
+		return entity.Friend{}, err
+	}
+	return block, nil
+}
+
+func (r *friendRepository) GetBlocksByUserID(blockerID string) ([]entity.Friend, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"user_id_1": blockerID,
+		"status":    entity.FriendStatusBlocked,
+	}
+
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	blocks := make([]entity.Friend, 0)
+	if err := cursor.All(ctx, &blocks); err != nil {
+		return nil, err
+	}
+
+	return blocks, nil
+}
+
+func (r *friendRepository) GetBlockedUserIDs(userID string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
+	filter := bson.M{
+		"$or": []bson.M{
+			{"user_id_1": userID},
+			{"user_id_2": userID},
+		},
+		"status": entity.FriendStatusBlocked,
+	}
 
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var blocks []entity.Friend
+	if err := cursor.All(ctx, &blocks); err != nil {
+		return nil, err
+	}
 
+	userIDs := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		if block.UserID1 == userID {
+			userIDs = append(userIDs, block.UserID2)
+		} else {
+			userIDs = append(userIDs, block.UserID1)
+		}
+	}
+	return userIDs, nil
+}
+
+func (r *friendRepository) IsBlocked(userID1, userID2 string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"$or": []bson.M{
+			{"user_id_1": userID1, "user_id_2": userID2},
+			{"user_id_1": userID2, "user_id_2": userID1},
+		},
+		"status": entity.FriendStatusBlocked,
+	}
+
+	count, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *friendRepository) GetMutualFriendCounts(friendIDs, excludedIDs []string, sampleSize int) ([]domain.MutualFriendCount, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if len(friendIDs) == 0 {
+		return []domain.MutualFriendCount{}, nil
+	}
+
+	// Each accepted friendship touching one of friendIDs links that friend ("via") to a candidate
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"status": entity.FriendStatusAccepted,
+			"$or": []bson.M{
+				{"user_id_1": bson.M{"$in": friendIDs}},
+				{"user_id_2": bson.M{"$in": friendIDs}},
+			},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"via": bson.M{"$cond": bson.A{
+				bson.M{"$in": bson.A{"$user_id_1", friendIDs}}, "$user_id_1", "$user_id_2",
+			}},
+			"candidate": bson.M{"$cond": bson.A{
+				bson.M{"$in": bson.A{"$user_id_1", friendIDs}}, "$user_id_2", "$user_id_1",
+			}},
+		}}},
+		{{Key: "$match", Value: bson.M{"candidate": bson.M{"$nin": excludedIDs}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":    "$candidate",
+			"count":  bson.M{"$sum": 1},
+			"sample": bson.M{"$push": "$via"},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"count":  1,
+			"sample": bson.M{"$slice": bson.A{"$sample", sampleSize}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	counts := make([]domain.MutualFriendCount, 0)
+	if err := cursor.All(ctx, &counts); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
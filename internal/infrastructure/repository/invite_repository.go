@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain"
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+	"github.com/TomTom2k/chat-app/server/internal/infrastructure/mongodb"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type inviteRepository struct {
+	collection *mongo.Collection
+}
+
+func NewInviteRepository() domain.InviteRepository {
+	r := &inviteRepository{
+		collection: mongodb.OpenCollection("conversation_invites"),
+	}
+	r.ensureIndexes()
+	return r
+}
+
+func (r *inviteRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	models := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "token", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "conversation_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	}
+	if _, err := r.collection.Indexes().CreateMany(ctx, models); err != nil {
+		log.Printf("[WARNING]: unable to create invite indexes: %v", err)
+	}
+}
+
+func (r *inviteRepository) CreateInvite(invite entity.ConversationInvite) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if invite.ID == "" {
+		invite.ID = generateID()
+	}
+	if invite.CreatedAt.IsZero() {
+		invite.CreatedAt = time.Now()
+	}
+
+	_, err := r.collection.InsertOne(ctx, invite)
+	return err
+}
+
+func (r *inviteRepository) GetInviteByID(inviteID string) (entity.ConversationInvite, error) {
+	return r.findOne(bson.M{"_id": inviteID})
+}
+
+func (r *inviteRepository) GetInviteByToken(token string) (entity.ConversationInvite, error) {
+	return r.findOne(bson.M{"token": token})
+}
+
+func (r *inviteRepository) findOne(filter bson.M) (entity.ConversationInvite, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var invite entity.ConversationInvite
+	err := r.collection.FindOne(ctx, filter).Decode(&invite)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return invite, errors.New("invite not found")
+		}
+		return invite, err
+	}
+	return invite, nil
+}
+
+func (r *inviteRepository) GetInvitesByConversationID(conversationID string) ([]entity.ConversationInvite, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := r.collection.Find(
+		ctx,
+		bson.M{"conversation_id": conversationID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	invites := make([]entity.ConversationInvite, 0)
+	if err := cursor.All(ctx, &invites); err != nil {
+		return nil, err
+	}
+	return invites, nil
+}
+
+func (r *inviteRepository) ConsumeInvite(inviteID string, now time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Validity is checked in the filter so concurrent redemptions cannot exceed max_uses
+	filter := bson.M{
+		"_id":        inviteID,
+		"revoked_at": bson.M{"$exists": false},
+		"$and": []bson.M{
+			{"$or": []bson.M{
+				{"expires_at": bson.M{"$exists": false}},
+				{"expires_at": bson.M{"$gt": now}},
+			}},
+			{"$or": []bson.M{
+				{"max_uses": 0},
+				{"$expr": bson.M{"$lt": []string{"$uses", "$max_uses"}}},
+			}},
+		},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"uses": 1}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("invite is no longer valid")
+	}
+	return nil
+}
+
+func (r *inviteRepository) RevokeInvite(inviteID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": inviteID, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+	)
+	return err
+}
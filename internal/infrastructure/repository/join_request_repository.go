@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain"
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+	"github.com/TomTom2k/chat-app/server/internal/infrastructure/mongodb"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type joinRequestRepository struct {
+	collection *mongo.Collection
+}
+
+func NewJoinRequestRepository() domain.JoinRequestRepository {
+	r := &joinRequestRepository{
+		collection: mongodb.OpenCollection("conversation_join_requests"),
+	}
+	r.ensureIndexes()
+	return r
+}
+
+// ensureIndexes also guarantees at most one pending request per user and conversation
+func (r *joinRequestRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	models := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "conversation_id", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: 1}}},
+		{
+			Keys: bson.D{{Key: "conversation_id", Value: 1}, {Key: "user_id", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"status": entity.JoinRequestPending}),
+		},
+	}
+	if _, err := r.collection.Indexes().CreateMany(ctx, models); err != nil {
+		log.Printf("[WARNING]: unable to create join request indexes: %v", err)
+	}
+}
+
+func (r *joinRequestRepository) CreateJoinRequest(request entity.JoinRequest) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	request.CreatedAt = now
+	request.UpdatedAt = now
+
+	if request.ID == "" {
+		request.ID = generateID()
+	}
+	if request.Status == "" {
+		request.Status = entity.JoinRequestPending
+	}
+
+	_, err := r.collection.InsertOne(ctx, request)
+	if mongo.IsDuplicateKeyError(err) {
+		return errors.New("join request already pending")
+	}
+	return err
+}
+
+func (r *joinRequestRepository) GetJoinRequestByID(requestID string) (entity.JoinRequest, error) {
+	return r.findOne(bson.M{"_id": requestID})
+}
+
+func (r *joinRequestRepository) GetPendingJoinRequest(conversationID, userID string) (entity.JoinRequest, error) {
+	return r.findOne(bson.M{
+		"conversation_id": conversationID,
+		"user_id":         userID,
+		"status":          entity.JoinRequestPending,
+	})
+}
+
+func (r *joinRequestRepository) findOne(filter bson.M) (entity.JoinRequest, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var request entity.JoinRequest
+	err := r.collection.FindOne(ctx, filter).Decode(&request)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return request, errors.New("join request not found")
+		}
+		return request, err
+	}
+	return request, nil
+}
+
+func (r *joinRequestRepository) GetPendingJoinRequestsByConversationID(conversationID string) ([]entity.JoinRequest, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := r.collection.Find(
+		ctx,
+		bson.M{"conversation_id": conversationID, "status": entity.JoinRequestPending},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	requests := make([]entity.JoinRequest, 0)
+	if err := cursor.All(ctx, &requests); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+func (r *joinRequestRepository) ReviewJoinRequest(requestID, status, reviewerID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	// Only pending requests can be reviewed, so two admins cannot both act on the same request
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": requestID, "status": entity.JoinRequestPending},
+		bson.M{"$set": bson.M{
+			"status":      status,
+			"reviewed_by": reviewerID,
+			"reviewed_at": now,
+			"updated_at":  now,
+		}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("request is not pending")
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain"
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+	"github.com/TomTom2k/chat-app/server/internal/infrastructure/mongodb"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type loginThrottleRepository struct {
+	collection *mongo.Collection
+}
+
+func NewLoginThrottleRepository() domain.LoginThrottleRepository {
+	r := &loginThrottleRepository{
+		collection: mongodb.OpenCollection("login_throttles"),
+	}
+	r.ensureIndexes()
+	return r
+}
+
+// ensureIndexes lets MongoDB drop counters once their window and lockout are over
+func (r *loginThrottleRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	models := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	}
+	if _, err := r.collection.Indexes().CreateMany(ctx, models); err != nil {
+		log.Printf("[WARNING]: unable to create login throttle indexes: %v", err)
+	}
+}
+
+func (r *loginThrottleRepository) RecordAttempt(kind, subject string, at time.Time, window time.Duration, delays []time.Duration) (entity.LoginThrottle, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	delaysMs := make(bson.A, 0, len(delays))
+	for _, delay := range delays {
+		delaysMs = append(delaysMs, delay.Milliseconds())
+	}
+	if len(delaysMs) == 0 {
+		delaysMs = append(delaysMs, int64(0))
+	}
+
+	// The TTL monitor only runs once a minute, so an expired counter is reset here rather than
+	// trusted to be gone. Deciding and counting in a single pipeline update means parallel attempts
+	// each see the ones before them and none slips through before a failure is recorded.
+	failures := bson.M{"$cond": bson.A{bson.M{"$gt": bson.A{"$expires_at", at}}, "$failures", 0}}
+	wait := bson.M{"$arrayElemAt": bson.A{delaysMs, bson.M{"$min": bson.A{failures, len(delaysMs) - 1}}}}
+	allowed := bson.M{"$and": bson.A{
+		bson.M{"$lte": bson.A{bson.M{"$ifNull": bson.A{"$locked_until", at}}, at}},
+		bson.M{"$or": bson.A{
+			bson.M{"$eq": bson.A{failures, 0}},
+			bson.M{"$gte": bson.A{at, bson.M{"$add": bson.A{"$last_failure_at", wait}}}},
+		}},
+	}}
+	update := bson.A{
+		bson.M{"$set": bson.M{
+			"allowed":       allowed,
+			"prev_failures": failures,
+		}},
+		bson.M{"$set": bson.M{
+			"kind":            kind,
+			"subject":         subject,
+			"failures":        bson.M{"$cond": bson.A{"$allowed", bson.M{"$add": bson.A{"$prev_failures", 1}}, "$failures"}},
+			"last_failure_at": bson.M{"$cond": bson.A{"$allowed", at, "$last_failure_at"}},
+			"expires_at": bson.M{"$cond": bson.A{
+				"$allowed",
+				bson.M{"$max": bson.A{at.Add(window), bson.M{"$ifNull": bson.A{"$locked_until", at}}}},
+				"$expires_at",
+			}},
+		}},
+		bson.M{"$unset": "prev_failures"},
+	}
+
+	var throttle entity.LoginThrottle
+	err := r.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": entity.LoginThrottleID(kind, subject)},
+		update,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&throttle)
+	if err != nil {
+		return entity.LoginThrottle{}, err
+	}
+	return throttle, nil
+}
+
+func (r *loginThrottleRepository) ReleaseAttempt(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "failures": bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"failures": -1}},
+	)
+	return err
+}
+
+func (r *loginThrottleRepository) LockUntil(id string, until time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// The counter must outlive the lock, otherwise the TTL index would lift it early
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"locked_until": until}, "$max": bson.M{"expires_at": until}},
+	)
+	return err
+}
+
+func (r *loginThrottleRepository) ClearThrottles(ids ...string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := r.collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"errors"
+	"log"
 	"time"
 
 	"github.com/TomTom2k/chat-app/server/internal/domain"
@@ -18,12 +19,54 @@ type messageRepository struct {
 }
 
 func NewMessageRepository() domain.MessageRepository {
-	return &messageRepository{
+	r := &messageRepository{
 		collection: mongodb.OpenCollection("messages"),
 	}
+	r.ensureIndexes()
+	return r
 }
 
-func (r *messageRepository) CreateMessage(message entity.Message) error {
+// ensureIndexes creates the indexes used by paged history queries.
+// Legacy chat_id/group_id fields are indexed too so the $or filter never falls back to a collection scan.
+func (r *messageRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	models := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "conversation_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "chat_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "group_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+	}
+	if _, err := r.collection.Indexes().CreateMany(ctx, models); err != nil {
+		log.Printf("[WARNING]: unable to create message indexes: %v", err)
+	}
+}
+
+// conversationFilter matches messages of a conversation in both the old (chat_id/group_id) and new (conversation_id) format
+func conversationFilter(conversationID string) bson.M {
+	return bson.M{
+		"$or": []bson.M{
+			{"conversation_id": conversationID},
+			{"chat_id": conversationID},
+			{"group_id": conversationID},
+		},
+	}
+}
+
+// cursorFilter matches messages strictly before (op = "$lt") or after (op = "$gt") the cursor
+func cursorFilter(cursor *domain.MessageCursor, op string) bson.M {
+	if cursor.ID == "" {
+		return bson.M{"created_at": bson.M{op: cursor.CreatedAt}}
+	}
+	return bson.M{
+		"$or": []bson.M{
+			{"created_at": bson.M{op: cursor.CreatedAt}},
+			{"created_at": cursor.CreatedAt, "_id": bson.M{op: cursor.ID}},
+		},
+	}
+}
+
+func (r *messageRepository) CreateMessage(message entity.Message) (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -56,8 +99,10 @@ func (r *messageRepository) CreateMessage(message entity.Message) error {
 		}
 	}
 
-	_, err := r.collection.InsertOne(ctx, message)
-	return err
+	if _, err := r.collection.InsertOne(ctx, message); err != nil {
+		return "", err
+	}
+	return message.ID, nil
 }
 
 func (r *messageRepository) GetMessagesByConversationID(conversationID string) ([]entity.Message, error) {
@@ -65,13 +110,7 @@ func (r *messageRepository) GetMessagesByConversationID(conversationID string) (
 	defer cancel()
 
 	// Support both old format (chat_id/group_id) and new format (conversation_id)
-	filter := bson.M{
-		"$or": []bson.M{
-			{"conversation_id": conversationID},
-			{"chat_id": conversationID},
-			{"group_id": conversationID},
-		},
-	}
+	filter := conversationFilter(conversationID)
 	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
 
 	cursor, err := r.collection.Find(ctx, filter, opts)
@@ -88,6 +127,57 @@ func (r *messageRepository) GetMessagesByConversationID(conversationID string) (
 	return messages, nil
 }
 
+func (r *messageRepository) GetMessagesPage(conversationID string, query domain.MessageQuery) ([]entity.Message, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conditions := []bson.M{conversationFilter(conversationID)}
+	if query.ViewerID != "" {
+		conditions = append(conditions, bson.M{"deleted_for": bson.M{"$ne": query.ViewerID}})
+	}
+	if query.SkipDeleted {
+		conditions = append(conditions, bson.M{"deleted_at": bson.M{"$exists": false}})
+	}
+	// Walk forward from the cursor when paging with "after", otherwise walk backward from the newest message
+	direction := -1
+	if query.After != nil {
+		conditions = append(conditions, cursorFilter(query.After, "$gt"))
+		direction = 1
+	} else if query.Before != nil {
+		conditions = append(conditions, cursorFilter(query.Before, "$lt"))
+	}
+
+	// Fetch one extra document to know whether another page exists
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: direction}, {Key: "_id", Value: direction}}).
+		SetLimit(int64(query.Limit + 1))
+
+	cursor, err := r.collection.Find(ctx, bson.M{"$and": conditions}, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	defer cursor.Close(ctx)
+
+	var messages []entity.Message
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(messages) > query.Limit
+	if hasMore {
+		messages = messages[:query.Limit]
+	}
+
+	// Always return messages in ascending order
+	if direction < 0 {
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+
+	return messages, hasMore, nil
+}
+
 func (r *messageRepository) GetMessageByID(messageID string) (entity.Message, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -116,6 +206,160 @@ func (r *messageRepository) UpdateMessage(message entity.Message) error {
 	return err
 }
 
+// EditMessage replaces the content and appends the previous one to the history in a single update,
+// so a concurrent delete, reaction or hide is never overwritten
+func (r *messageRepository) EditMessage(messageID, content string, revision entity.MessageRevision) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": messageID, "deleted_at": bson.M{"$exists": false}},
+		bson.M{
+			"$set": bson.M{
+				"content":    content,
+				"edited_at":  revision.EditedAt,
+				"updated_at": revision.EditedAt,
+			},
+			"$push": bson.M{"edit_history": revision},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("message has been deleted")
+	}
+	return nil
+}
+
+func (r *messageRepository) DeleteMessageForEveryone(messageID string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Keep the document as a tombstone so reply links still resolve
+	now := time.Now()
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": messageID, "deleted_at": bson.M{"$exists": false}},
+		bson.M{
+			"$set": bson.M{
+				"content":     "",
+				"attachments": []entity.MessageAttachment{},
+				"reactions":   []entity.MessageReaction{},
+				"deleted_at":  now,
+				"updated_at":  now,
+			},
+			"$unset": bson.M{"edit_history": ""},
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (r *messageRepository) HideMessageForUser(messageID, userID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": messageID},
+		bson.M{
+			"$addToSet": bson.M{"deleted_for": userID},
+			"$set":      bson.M{"updated_at": time.Now()},
+		},
+	)
+	return err
+}
+
+// Most read receipts written by one read; older messages are covered by the member's read cursor
+const readReceiptWindow = 200
+
+func (r *messageRepository) MarkMessagesReadUpTo(conversationID, userID string, after *domain.MessageCursor, upTo domain.MessageCursor) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	upToFilter := bson.M{"created_at": bson.M{"$lte": upTo.CreatedAt}}
+	if upTo.ID != "" {
+		upToFilter = bson.M{
+			"$or": []bson.M{
+				{"created_at": bson.M{"$lt": upTo.CreatedAt}},
+				{"created_at": upTo.CreatedAt, "_id": bson.M{"$lte": upTo.ID}},
+			},
+		}
+	}
+
+	conditions := []bson.M{
+		conversationFilter(conversationID),
+		upToFilter,
+		{"sender_id": bson.M{"$ne": userID}},
+		{"read_receipts.user_id": bson.M{"$ne": userID}},
+	}
+	if after != nil {
+		conditions = append(conditions, cursorFilter(after, "$gt"))
+	}
+
+	// Only the newest messages of the window get a receipt, so one read never rewrites a long history
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(readReceiptWindow).
+		SetProjection(bson.M{"_id": 1})
+	cursor, err := r.collection.Find(ctx, bson.M{"$and": conditions}, opts)
+	if err != nil {
+		return err
+	}
+	var unread []struct {
+		ID string `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &unread); err != nil {
+		return err
+	}
+	if len(unread) == 0 {
+		return nil
+	}
+	messageIDs := make([]string, 0, len(unread))
+	for _, message := range unread {
+		messageIDs = append(messageIDs, message.ID)
+	}
+
+	now := time.Now()
+	_, err = r.collection.UpdateMany(
+		ctx,
+		bson.M{"_id": bson.M{"$in": messageIDs}, "read_receipts.user_id": bson.M{"$ne": userID}},
+		bson.M{
+			"$push": bson.M{"read_receipts": entity.ReadReceipt{UserID: userID, ReadAt: now}},
+			"$set": bson.M{
+				"status":     entity.MessageStatusRead,
+				"updated_at": now,
+			},
+		},
+	)
+	return err
+}
+
+func (r *messageRepository) CountUnread(conversationID, userID string, after *domain.MessageCursor) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conditions := []bson.M{
+		conversationFilter(conversationID),
+		{"sender_id": bson.M{"$ne": userID}},
+		{"deleted_for": bson.M{"$ne": userID}},
+		{"deleted_at": bson.M{"$exists": false}},
+	}
+	if after != nil {
+		conditions = append(conditions, cursorFilter(after, "$gt"))
+	}
+
+	count, err := r.collection.CountDocuments(ctx, bson.M{"$and": conditions})
+	if err != nil {
+		return 0, err
+	}
+	return int(count), nil
+}
+
 func (r *messageRepository) AddReaction(messageID, userID, emoji string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -218,7 +462,3 @@ func (r *messageRepository) MarkAsDelivered(messageID string) error {
 	)
 	return err
 }
-
-
-
-
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain"
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+	"github.com/TomTom2k/chat-app/server/internal/infrastructure/mongodb"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type securityEventRepository struct {
+	collection *mongo.Collection
+}
+
+func NewSecurityEventRepository() domain.SecurityEventRepository {
+	r := &securityEventRepository{
+		collection: mongodb.OpenCollection("security_events"),
+	}
+	r.ensureIndexes()
+	return r
+}
+
+func (r *securityEventRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	models := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "email", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "ip", Value: 1}, {Key: "created_at", Value: -1}}},
+	}
+	if _, err := r.collection.Indexes().CreateMany(ctx, models); err != nil {
+		log.Printf("[WARNING]: unable to create security event indexes: %v", err)
+	}
+}
+
+func (r *securityEventRepository) CreateEvent(event entity.SecurityEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if event.ID == "" {
+		event.ID = generateID()
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	_, err := r.collection.InsertOne(ctx, event)
+	return err
+}
+
+func (r *securityEventRepository) GetEvents(email, ip string, limit int) ([]entity.SecurityEvent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{}
+	if email != "" {
+		filter["email"] = email
+	}
+	if ip != "" {
+		filter["ip"] = ip
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(int64(limit))
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	events := make([]entity.SecurityEvent, 0)
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
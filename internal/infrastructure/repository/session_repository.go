@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain"
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+	"github.com/TomTom2k/chat-app/server/internal/infrastructure/mongodb"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type sessionRepository struct {
+	collection *mongo.Collection
+}
+
+func NewSessionRepository() domain.SessionRepository {
+	r := &sessionRepository{
+		collection: mongodb.OpenCollection("sessions"),
+	}
+	r.ensureIndexes()
+	return r
+}
+
+// ensureIndexes also lets MongoDB drop sessions once their refresh token has expired
+func (r *sessionRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	models := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "last_used_at", Value: -1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	}
+	if _, err := r.collection.Indexes().CreateMany(ctx, models); err != nil {
+		log.Printf("[WARNING]: unable to create session indexes: %v", err)
+	}
+}
+
+func (r *sessionRepository) CreateSession(session entity.Session) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if session.ID == "" {
+		session.ID = generateID()
+	}
+	now := time.Now()
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = now
+	}
+	if session.LastUsedAt.IsZero() {
+		session.LastUsedAt = now
+	}
+
+	_, err := r.collection.InsertOne(ctx, session)
+	return err
+}
+
+func (r *sessionRepository) GetSessionByID(sessionID string) (entity.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var session entity.Session
+	err := r.collection.FindOne(ctx, bson.M{"_id": sessionID}).Decode(&session)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return entity.Session{}, errors.New("session not found")
+		}
+		return entity.Session{}, err
+	}
+	return session, nil
+}
+
+func (r *sessionRepository) GetActiveSessionsByUserID(userID string) ([]entity.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"user_id":    userID,
+		"revoked_at": bson.M{"$exists": false},
+		"expires_at": bson.M{"$gt": time.Now()},
+	}
+
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "last_used_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	sessions := make([]entity.Session, 0)
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (r *sessionRepository) TouchSession(sessionID string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": sessionID, "last_used_at": bson.M{"$lt": at}},
+		bson.M{"$set": bson.M{"last_used_at": at}},
+	)
+	return err
+}
+
+func (r *sessionRepository) RotateRefreshToken(sessionID, currentHash, newHash string, expiresAt time.Time, ip, userAgent string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Matching on the current hash makes concurrent refreshes with the same token fail except one
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{
+			"_id":                sessionID,
+			"refresh_token_hash": currentHash,
+			"revoked_at":         bson.M{"$exists": false},
+		},
+		bson.M{"$set": bson.M{
+			"refresh_token_hash":  newHash,
+			"previous_token_hash": currentHash,
+			"expires_at":          expiresAt,
+			"ip":                  ip,
+			"user_agent":          userAgent,
+			"last_used_at":        time.Now(),
+		}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("invalid refresh token")
+	}
+	return nil
+}
+
+func (r *sessionRepository) RevokeSession(sessionID, reason string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": sessionID, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": time.Now(), "revoked_reason": reason}},
+	)
+	return err
+}
+
+func (r *sessionRepository) RevokeSessionsByUserID(userID, reason string) ([]string, error) {
+	return r.revokeSessions(bson.M{"user_id": userID, "revoked_at": bson.M{"$exists": false}}, reason)
+}
+
+func (r *sessionRepository) RevokeOtherSessions(userID, keepSessionID, reason string) ([]string, error) {
+	return r.revokeSessions(bson.M{
+		"_id":        bson.M{"$ne": keepSessionID},
+		"user_id":    userID,
+		"revoked_at": bson.M{"$exists": false},
+	}, reason)
+}
+
+// revokeSessions revokes the sessions matching filter and returns their IDs
+func (r *sessionRepository) revokeSessions(filter bson.M, reason string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var sessions []entity.Session
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+
+	sessionIDs := make([]string, 0, len(sessions))
+	for _, session := range sessions {
+		sessionIDs = append(sessionIDs, session.ID)
+	}
+	if len(sessionIDs) == 0 {
+		return sessionIDs, nil
+	}
+
+	_, err = r.collection.UpdateMany(
+		ctx,
+		bson.M{"_id": bson.M{"$in": sessionIDs}, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": time.Now(), "revoked_reason": reason}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return sessionIDs, nil
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
+	"log"
 	"strings"
 	"time"
 
@@ -13,6 +14,7 @@ import (
 	"github.com/TomTom2k/chat-app/server/internal/infrastructure/mongodb"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
 type userRepository struct {
@@ -20,9 +22,27 @@ type userRepository struct {
 }
 
 func NewUserRepository() domain.UserRepository {
-	return &userRepository{
+	r := &userRepository{
 		collection: mongodb.OpenCollection("users"),
 	}
+	r.ensureIndexes()
+	return r
+}
+
+// ensureIndexes makes usernames unique; users without one are not indexed
+func (r *userRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	models := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "username", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"username": bson.M{"$type": "string"}}),
+		},
+	}
+	if _, err := r.collection.Indexes().CreateMany(ctx, models); err != nil {
+		log.Printf("[WARNING]: unable to create user indexes: %v", err)
+	}
 }
 
 func (r *userRepository) CreateUser(user entity.User) error {
@@ -59,8 +79,10 @@ func (r *userRepository) GetByEmail(email string) (entity.User, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	// Case-insensitive so accounts registered before emails were lowercased are still found
 	var user entity.User
-	err := r.collection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
+	opts := options.FindOne().SetCollation(&options.Collation{Locale: "en", Strength: 2})
+	err := r.collection.FindOne(ctx, bson.M{"email": email}, opts).Decode(&user)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return entity.User{}, nil
@@ -157,165 +179,265 @@ func (r *userRepository) UpdateUser(user entity.User) error {
 	return err
 }
 
-func (r *userRepository) AddFriend(userID1, userID2 string) error {
+func (r *userRepository) GetUsersByIDs(userIDs []string) ([]entity.User, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	now := time.Now()
+	filter := bson.M{
+		"_id": bson.M{"$in": userIDs},
+	}
 
-	// Add userID2 to userID1's friends list
-	_, err := r.collection.UpdateOne(
-		ctx,
-		bson.M{"_id": userID1},
-		bson.M{
-			"$addToSet": bson.M{"friends": userID2},
-			"$set":      bson.M{"updated_at": now},
-		},
-	)
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var users []entity.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	// Remove passwords from results
+	for i := range users {
+		users[i].Password = ""
+	}
+
+	return users, nil
+}
+
+func (r *userRepository) MarkEmailVerified(userID string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{"$set": bson.M{
+		"email_verified":    true,
+		"email_verified_at": at,
+		"updated_at":        at,
+	}})
 	if err != nil {
 		return err
 	}
+	if result.MatchedCount == 0 {
+		return errors.New("user not found")
+	}
+	return nil
+}
 
-	// Add userID1 to userID2's friends list
-	_, err = r.collection.UpdateOne(
-		ctx,
-		bson.M{"_id": userID2},
-		bson.M{
-			"$addToSet": bson.M{"friends": userID1},
-			"$set":      bson.M{"updated_at": now},
-		},
-	)
-	return err
+func (r *userRepository) UpdatePassword(userID, hashedPassword string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{"$set": bson.M{
+		"password":   hashedPassword,
+		"updated_at": time.Now(),
+	}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("user not found")
+	}
+	return nil
 }
 
-func (r *userRepository) RemoveFriend(userID1, userID2 string) error {
+func (r *userRepository) UpdateProfile(userID string, update domain.ProfileUpdate) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	now := time.Now()
+	set := bson.M{"updated_at": time.Now()}
+	if update.Name != nil {
+		// Name and FullName are kept in sync for older clients
+		set["name"] = *update.Name
+		set["full_name"] = *update.Name
+	}
+	if update.Username != nil {
+		set["username"] = *update.Username
+	}
+	if update.Avatar != nil {
+		set["avatar"] = *update.Avatar
+	}
+	if update.Bio != nil {
+		set["bio"] = *update.Bio
+	}
 
-	// Remove userID2 from userID1's friends list
-	_, err := r.collection.UpdateOne(
-		ctx,
-		bson.M{"_id": userID1},
-		bson.M{
-			"$pull": bson.M{"friends": userID2},
-			"$set":  bson.M{"updated_at": now},
-		},
-	)
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{"$set": set})
 	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return errors.New("username already taken")
+		}
 		return err
 	}
+	if result.MatchedCount == 0 {
+		return errors.New("user not found")
+	}
+	return nil
+}
 
-	// Remove userID1 from userID2's friends list
-	_, err = r.collection.UpdateOne(
-		ctx,
-		bson.M{"_id": userID2},
-		bson.M{
-			"$pull": bson.M{"friends": userID1},
-			"$set":  bson.M{"updated_at": now},
-		},
-	)
-	return err
+func (r *userRepository) UpdatePrivacy(userID string, settings entity.PrivacySettings) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{"$set": bson.M{
+		"privacy":    settings,
+		"updated_at": time.Now(),
+	}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("user not found")
+	}
+	return nil
 }
 
-func (r *userRepository) AddSentRequest(userID, targetUserID string) error {
+func (r *userRepository) UpdatePresenceSettings(userID string, settings entity.PresenceSettings) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	now := time.Now()
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{"$set": bson.M{
+		"presence":   settings,
+		"updated_at": time.Now(),
+	}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("user not found")
+	}
+	return nil
+}
 
-	// Add targetUserID to userID's sent_requests
-	_, err := r.collection.UpdateOne(
+func (r *userRepository) SetPresence(userID string, online bool, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Only presence fields are written, a full UpdateUser could overwrite concurrent profile changes
+	set := bson.M{"online": online}
+	if !online {
+		set["last_seen_at"] = at
+	}
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{"$set": set})
+	return err
+}
+
+func (r *userRepository) SetPendingTwoFactorSecret(userID, secret string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(
 		ctx,
-		bson.M{"_id": userID},
-		bson.M{
-			"$addToSet": bson.M{"sent_requests": targetUserID},
-			"$set":      bson.M{"updated_at": now},
-		},
+		bson.M{"_id": userID, "two_factor.enabled": bson.M{"$ne": true}},
+		bson.M{"$set": bson.M{"two_factor.pending_secret": secret, "two_factor.enabled": false, "updated_at": time.Now()}},
 	)
 	if err != nil {
 		return err
 	}
+	if result.MatchedCount == 0 {
+		return errors.New("two-factor authentication already enabled")
+	}
+	return nil
+}
 
-	// Add userID to targetUserID's pending_requests
-	_, err = r.collection.UpdateOne(
+func (r *userRepository) EnableTwoFactor(userID, pendingSecret string, recoveryCodeHashes []string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(
 		ctx,
-		bson.M{"_id": targetUserID},
+		bson.M{"_id": userID, "two_factor.enabled": false, "two_factor.pending_secret": pendingSecret},
 		bson.M{
-			"$addToSet": bson.M{"pending_requests": userID},
-			"$set":      bson.M{"updated_at": now},
+			"$set": bson.M{
+				"two_factor.enabled":        true,
+				"two_factor.enabled_at":     at,
+				"two_factor.secret":         pendingSecret,
+				"two_factor.recovery_codes": recoveryCodeHashes,
+				"updated_at":                at,
+			},
+			"$unset": bson.M{"two_factor.pending_secret": "", "two_factor.last_used_step": ""},
 		},
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("two-factor setup not started")
+	}
+	return nil
 }
 
-func (r *userRepository) RemoveSentRequest(userID, targetUserID string) error {
+func (r *userRepository) DisableTwoFactor(userID string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	now := time.Now()
-
-	// Remove targetUserID from userID's sent_requests
 	_, err := r.collection.UpdateOne(
 		ctx,
 		bson.M{"_id": userID},
-		bson.M{
-			"$pull": bson.M{"sent_requests": targetUserID},
-			"$set":  bson.M{"updated_at": now},
-		},
+		bson.M{"$unset": bson.M{"two_factor": ""}, "$set": bson.M{"updated_at": time.Now()}},
 	)
-	if err != nil {
-		return err
-	}
+	return err
+}
+
+func (r *userRepository) UseTwoFactorStep(userID string, step int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	// Remove userID from targetUserID's pending_requests
-	_, err = r.collection.UpdateOne(
+	// A code is accepted once: the step only moves forward
+	result, err := r.collection.UpdateOne(
 		ctx,
-		bson.M{"_id": targetUserID},
 		bson.M{
-			"$pull": bson.M{"pending_requests": userID},
-			"$set":  bson.M{"updated_at": now},
+			"_id":                userID,
+			"two_factor.enabled": true,
+			"$or": []bson.M{
+				{"two_factor.last_used_step": bson.M{"$exists": false}},
+				{"two_factor.last_used_step": bson.M{"$lt": step}},
+			},
 		},
+		bson.M{"$set": bson.M{"two_factor.last_used_step": step}},
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("invalid code")
+	}
+	return nil
 }
 
-func (r *userRepository) AddPendingRequest(userID, senderUserID string) error {
-	// This is handled by AddSentRequest, but kept for consistency
-	return r.AddSentRequest(senderUserID, userID)
-}
+func (r *userRepository) ConsumeRecoveryCode(userID, codeHash string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-func (r *userRepository) RemovePendingRequest(userID, senderUserID string) error {
-	// This is handled by RemoveSentRequest, but kept for consistency
-	return r.RemoveSentRequest(senderUserID, userID)
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": userID, "two_factor.enabled": true, "two_factor.recovery_codes": codeHash},
+		bson.M{"$pull": bson.M{"two_factor.recovery_codes": codeHash}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("invalid code")
+	}
+	return nil
 }
 
-func (r *userRepository) GetUsersByIDs(userIDs []string) ([]entity.User, error) {
+func (r *userRepository) ReplaceRecoveryCodes(userID string, codeHashes []string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	filter := bson.M{
-		"_id": bson.M{"$in": userIDs},
-	}
-
-	cursor, err := r.collection.Find(ctx, filter)
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": userID, "two_factor.enabled": true},
+		bson.M{"$set": bson.M{"two_factor.recovery_codes": codeHashes, "updated_at": time.Now()}},
+	)
 	if err != nil {
-		return nil, err
-	}
-	defer cursor.Close(ctx)
-
-	var users []entity.User
-	if err := cursor.All(ctx, &users); err != nil {
-		return nil, err
+		return err
 	}
-
-	// Remove passwords from results
-	for i := range users {
-		users[i].Password = ""
+	if result.MatchedCount == 0 {
+		return errors.New("two-factor authentication not enabled")
 	}
-
-	return users, nil
+	return nil
 }
 
 // generateID generates a 24-character hex string (similar to MongoDB ObjectID)
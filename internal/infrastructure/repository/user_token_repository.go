@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain"
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+	"github.com/TomTom2k/chat-app/server/internal/infrastructure/mongodb"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type userTokenRepository struct {
+	collection *mongo.Collection
+}
+
+func NewUserTokenRepository() domain.UserTokenRepository {
+	r := &userTokenRepository{
+		collection: mongodb.OpenCollection("user_tokens"),
+	}
+	r.ensureIndexes()
+	return r
+}
+
+// ensureIndexes also lets MongoDB drop tokens once they have expired
+func (r *userTokenRepository) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	models := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "purpose", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	}
+	if _, err := r.collection.Indexes().CreateMany(ctx, models); err != nil {
+		log.Printf("[WARNING]: unable to create user token indexes: %v", err)
+	}
+}
+
+func (r *userTokenRepository) CreateToken(token entity.UserToken) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if token.ID == "" {
+		token.ID = generateID()
+	}
+	if token.CreatedAt.IsZero() {
+		token.CreatedAt = time.Now()
+	}
+
+	_, err := r.collection.InsertOne(ctx, token)
+	return err
+}
+
+func (r *userTokenRepository) ConsumeToken(tokenHash, purpose string, now time.Time) (entity.UserToken, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Marking the token used in the same operation that finds it makes it single-use under concurrency
+	var token entity.UserToken
+	err := r.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"token_hash": tokenHash,
+			"purpose":    purpose,
+			"used_at":    bson.M{"$exists": false},
+			"expires_at": bson.M{"$gt": now},
+		},
+		bson.M{"$set": bson.M{"used_at": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return entity.UserToken{}, errors.New("invalid or expired token")
+		}
+		return entity.UserToken{}, err
+	}
+	return token, nil
+}
+
+func (r *userTokenRepository) InvalidateTokens(userID, purpose string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.UpdateMany(
+		ctx,
+		bson.M{"user_id": userID, "purpose": purpose, "used_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"used_at": time.Now()}},
+	)
+	return err
+}
+
+func (r *userTokenRepository) GetToken(tokenHash, purpose string, now time.Time) (entity.UserToken, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var token entity.UserToken
+	err := r.collection.FindOne(ctx, bson.M{
+		"token_hash": tokenHash,
+		"purpose":    purpose,
+		"used_at":    bson.M{"$exists": false},
+		"expires_at": bson.M{"$gt": now},
+	}).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return entity.UserToken{}, errors.New("invalid or expired token")
+		}
+		return entity.UserToken{}, err
+	}
+	return token, nil
+}
+
+func (r *userTokenRepository) RecordFailedAttempt(tokenID string, maxAttempts int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": tokenID}, bson.M{"$inc": bson.M{"attempts": 1}})
+	if err != nil {
+		return err
+	}
+
+	// Concurrent attempts all count, whichever reaches the limit burns the token
+	_, err = r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": tokenID, "attempts": bson.M{"$gte": maxAttempts}, "used_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"used_at": time.Now()}},
+	)
+	return err
+}
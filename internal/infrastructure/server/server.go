@@ -25,6 +25,11 @@ func NewServer(cfg *config.Config, container *di.Container) *Server {
 
 	router := gin.Default()
 
+	// Client IPs key the login throttle, so X-Forwarded-For is only read from configured proxies
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("[ERROR]: invalid TRUSTED_PROXIES: %v", err)
+	}
+
 	// Setup middleware
 	setupMiddleware(router, cfg)
 
@@ -83,7 +88,9 @@ func setupRoutes(router *gin.Engine, container *di.Container) {
 		setupConversationRoutes(api, container)
 		setupFriendRoutes(api, container)
 		setupUserRoutes(api, container)
+		setupAdminRoutes(api, container)
 		setupWebSocketRoutes(router, container)
+		setupWellKnownRoutes(router, container)
 	}
 }
 
@@ -93,18 +100,46 @@ func setupWebSocketRoutes(router *gin.Engine, container *di.Container) {
 	router.GET("/ws", container.WebSocketHandler.HandleWebSocket)
 }
 
+func setupWellKnownRoutes(router *gin.Engine, container *di.Container) {
+	// Other services fetch our public keys here to verify access tokens
+	router.GET("/.well-known/jwks.json", container.UserHandler.JWKS)
+}
+
 func setupAuthRoutes(api *gin.RouterGroup, container *di.Container) {
 	auth := api.Group("/auth")
 	{
 		auth.POST("/register", container.UserHandler.Register)
 		auth.POST("/login", container.UserHandler.Login)
-		auth.GET("/me", http.AuthMiddleware(container.Config), container.UserHandler.GetMe)
+		auth.POST("/refresh", container.UserHandler.Refresh)
+		auth.POST("/2fa/verify", container.UserHandler.VerifyTwoFactorLogin)
+		auth.POST("/2fa/setup", http.AuthMiddleware(container.UserUseCase), container.UserHandler.SetupTwoFactor)
+		auth.POST("/2fa/confirm", http.AuthMiddleware(container.UserUseCase), container.UserHandler.ConfirmTwoFactor)
+		auth.POST("/2fa/disable", http.AuthMiddleware(container.UserUseCase), container.UserHandler.DisableTwoFactor)
+		auth.POST("/2fa/recovery-codes", http.AuthMiddleware(container.UserUseCase), container.UserHandler.RegenerateRecoveryCodes)
+		auth.POST("/verify-email", container.UserHandler.VerifyEmail)
+		auth.POST("/verify-email/resend", http.AuthMiddleware(container.UserUseCase), container.UserHandler.ResendVerificationEmail)
+		auth.POST("/forgot-password", container.UserHandler.ForgotPassword)
+		auth.POST("/reset-password", container.UserHandler.ResetPassword)
+		auth.GET("/me", http.AuthMiddleware(container.UserUseCase), container.UserHandler.GetMe)
+		auth.POST("/logout", http.AuthMiddleware(container.UserUseCase), container.UserHandler.Logout)
+		auth.POST("/logout-all", http.AuthMiddleware(container.UserUseCase), container.UserHandler.LogoutAll)
+		auth.GET("/sessions", http.AuthMiddleware(container.UserUseCase), container.UserHandler.GetSessions)
+		auth.DELETE("/sessions/:id", http.AuthMiddleware(container.UserUseCase), container.UserHandler.RevokeSession)
+	}
+}
+
+func setupAdminRoutes(api *gin.RouterGroup, container *di.Container) {
+	admin := api.Group("/admin")
+	admin.Use(http.AuthMiddleware(container.UserUseCase), http.AdminMiddleware(container.UserUseCase))
+	{
+		admin.POST("/login-locks/unlock", container.UserHandler.UnlockLogin)
+		admin.GET("/security-events", container.UserHandler.GetSecurityEvents)
 	}
 }
 
 func setupConversationRoutes(api *gin.RouterGroup, container *di.Container) {
 	conversations := api.Group("/conversations")
-	conversations.Use(http.AuthMiddleware(container.Config))
+	conversations.Use(http.AuthMiddleware(container.UserUseCase))
 	{
 		conversations.GET("", container.ConversationHandler.GetConversations)
 		conversations.POST("/direct", container.ConversationHandler.CreateDirectConversation)
@@ -112,15 +147,35 @@ func setupConversationRoutes(api *gin.RouterGroup, container *di.Container) {
 		conversations.GET("/:conversationId", container.ConversationHandler.GetConversation)
 		conversations.GET("/:conversationId/messages", container.ConversationHandler.GetMessages)
 		conversations.POST("/:conversationId/messages", container.ConversationHandler.SendMessage)
+		conversations.POST("/:conversationId/read", container.ConversationHandler.MarkConversationRead)
+		conversations.POST("/:conversationId/members", container.ConversationHandler.AddMembers)
+		conversations.PATCH("/:conversationId/members/:userId", container.ConversationHandler.UpdateMemberRole)
+		conversations.DELETE("/:conversationId/members/:userId", container.ConversationHandler.RemoveMember)
+		conversations.POST("/:conversationId/leave", container.ConversationHandler.LeaveConversation)
+		conversations.POST("/:conversationId/owner", container.ConversationHandler.TransferOwnership)
+		conversations.PATCH("/:conversationId", container.ConversationHandler.UpdateGroup)
+		conversations.PUT("/:conversationId/permissions", container.ConversationHandler.UpdatePermissions)
+		conversations.POST("/:conversationId/invites", container.ConversationHandler.CreateInvite)
+		conversations.GET("/:conversationId/invites", container.ConversationHandler.GetInvites)
+		conversations.DELETE("/:conversationId/invites/:inviteId", container.ConversationHandler.RevokeInvite)
+		conversations.POST("/invites/:token/join", container.ConversationHandler.JoinByInvite)
+		conversations.POST("/:conversationId/join-requests", container.ConversationHandler.RequestToJoin)
+		conversations.GET("/:conversationId/join-requests", container.ConversationHandler.GetJoinRequests)
+		conversations.POST("/:conversationId/join-requests/:requestId/approve", container.ConversationHandler.ApproveJoinRequest)
+		conversations.POST("/:conversationId/join-requests/:requestId/reject", container.ConversationHandler.RejectJoinRequest)
 		conversations.POST("/upload", container.ConversationHandler.UploadFile)
+		conversations.PATCH("/messages/:messageId", container.ConversationHandler.EditMessage)
+		conversations.DELETE("/messages/:messageId", container.ConversationHandler.DeleteMessage)
 		conversations.POST("/messages/:messageId/reactions", container.ConversationHandler.AddReaction)
 		conversations.DELETE("/messages/:messageId/reactions", container.ConversationHandler.RemoveReaction)
 		conversations.POST("/messages/:messageId/read", container.ConversationHandler.MarkAsRead)
+		conversations.POST("/messages/:messageId/pin", container.ConversationHandler.PinMessage)
+		conversations.DELETE("/messages/:messageId/pin", container.ConversationHandler.UnpinMessage)
 	}
 	
 	// Keep backward compatibility with /chats routes
 	chats := api.Group("/chats")
-	chats.Use(http.AuthMiddleware(container.Config))
+	chats.Use(http.AuthMiddleware(container.UserUseCase))
 	{
 		chats.GET("", container.ConversationHandler.GetConversations)
 		chats.POST("", container.ConversationHandler.CreateDirectConversation)
@@ -137,6 +192,8 @@ func setupConversationRoutes(api *gin.RouterGroup, container *di.Container) {
 			container.ConversationHandler.SendMessage(c)
 		})
 		chats.POST("/upload", container.ConversationHandler.UploadFile)
+		chats.PATCH("/messages/:messageId", container.ConversationHandler.EditMessage)
+		chats.DELETE("/messages/:messageId", container.ConversationHandler.DeleteMessage)
 		chats.POST("/messages/:messageId/reactions", container.ConversationHandler.AddReaction)
 		chats.DELETE("/messages/:messageId/reactions", container.ConversationHandler.RemoveReaction)
 		chats.POST("/messages/:messageId/read", container.ConversationHandler.MarkAsRead)
@@ -145,29 +202,46 @@ func setupConversationRoutes(api *gin.RouterGroup, container *di.Container) {
 
 func setupFriendRoutes(api *gin.RouterGroup, container *di.Container) {
 	friends := api.Group("/friends")
-	friends.Use(http.AuthMiddleware(container.Config))
+	friends.Use(http.AuthMiddleware(container.UserUseCase))
 	{
 		friends.GET("", container.FriendHandler.GetFriends)
 		friends.POST("", container.FriendHandler.AddFriend)
 		friends.DELETE("/:friendId", container.FriendHandler.DeleteFriend)
+		friends.GET("/suggestions", container.FriendHandler.GetFriendSuggestions)
 		
 		// Friend requests
 		friends.GET("/requests/pending", container.FriendHandler.GetPendingRequests)
 		friends.GET("/requests/sent", container.FriendHandler.GetSentRequests)
 		friends.POST("/requests/:requestId/accept", container.FriendHandler.AcceptFriendRequest)
 		friends.POST("/requests/:requestId/reject", container.FriendHandler.RejectFriendRequest)
+
+		// Blocks
+		friends.GET("/blocks", container.FriendHandler.GetBlockedUsers)
+		friends.POST("/blocks", container.FriendHandler.BlockUser)
+		friends.DELETE("/blocks/:userId", container.FriendHandler.UnblockUser)
 	}
 
 	users := api.Group("/users")
-	users.Use(http.AuthMiddleware(container.Config))
+	users.Use(http.AuthMiddleware(container.UserUseCase))
 	{
 		users.GET("/search", container.FriendHandler.SearchUsers)
+		users.GET("/:id", container.FriendHandler.GetUserProfile)
 	}
 }
 
 
 func setupUserRoutes(api *gin.RouterGroup, container *di.Container) {
-	// User routes can be added here if needed
+	users := api.Group("/users")
+	users.Use(http.AuthMiddleware(container.UserUseCase))
+	{
+		users.PATCH("/me", container.UserHandler.UpdateProfile)
+		users.POST("/me/avatar", container.UserHandler.UploadAvatar)
+		users.POST("/me/password", container.UserHandler.ChangePassword)
+		users.GET("/me/privacy", container.UserHandler.GetPrivacySettings)
+		users.PATCH("/me/privacy", container.UserHandler.UpdatePrivacySettings)
+		users.GET("/me/presence", container.UserHandler.GetPresence)
+		users.PATCH("/me/presence", container.UserHandler.UpdatePresence)
+	}
 }
 
 func (s *Server) Start() error {
@@ -0,0 +1,83 @@
+package websocket
+
+import (
+	"errors"
+	"sync"
+)
+
+// Envelope kinds carried by the backplane
+const (
+	envelopeEvent        = "event"         // A hub event to fan out to local clients
+	envelopePresence     = "presence"      // A user's connection state changed on a node: online, away (every connection idle) or offline
+	envelopeHeartbeat    = "heartbeat"     // Periodic snapshot of the users connected to a node and which of them are away
+	envelopeCloseSession = "close_session" // Sessions were revoked, their sockets must be closed on every node
+)
+
+// Envelope is what hub nodes exchange over the backplane
+type Envelope struct {
+	Kind     string   `json:"kind"`
+	NodeID   string   `json:"nodeId"`
+	Message  *Message `json:"message,omitempty"`  // envelopeEvent
+	Origin   string   `json:"origin,omitempty"`   // envelopeEvent, Message.Origin is not serialized with the message
+	UserID   string   `json:"userId,omitempty"`   // envelopePresence
+	Presence string   `json:"presence,omitempty"` // envelopePresence
+	Users    []string `json:"users,omitempty"`    // envelopeHeartbeat
+	Away     []string `json:"away,omitempty"`     // envelopeHeartbeat, subset of Users
+	Sessions []string `json:"sessions,omitempty"` // envelopeHeartbeat (sessions with a live socket), envelopeCloseSession
+}
+
+// Backplane fans hub events out to every server replica, including the publishing one.
+// Each node then delivers the events to its own connected clients.
+type Backplane interface {
+	Publish(envelope *Envelope) error
+	Subscribe(handler func(envelope *Envelope)) error
+	Close() error
+}
+
+// MemoryBackplane is the single-node backplane: envelopes loop straight back to the local subscribers
+type MemoryBackplane struct {
+	queue    chan *Envelope
+	handlers []func(envelope *Envelope)
+	mu       sync.RWMutex
+	once     sync.Once
+}
+
+func NewMemoryBackplane() *MemoryBackplane {
+	b := &MemoryBackplane{
+		queue: make(chan *Envelope, 1024),
+	}
+	go b.dispatch()
+	return b
+}
+
+func (b *MemoryBackplane) Publish(envelope *Envelope) error {
+	select {
+	case b.queue <- envelope:
+		return nil
+	default:
+		// Never block the publisher, it may be the hub loop consuming our deliveries
+		return errors.New("backplane queue is full")
+	}
+}
+
+func (b *MemoryBackplane) Subscribe(handler func(envelope *Envelope)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+	return nil
+}
+
+func (b *MemoryBackplane) Close() error {
+	b.once.Do(func() { close(b.queue) })
+	return nil
+}
+
+func (b *MemoryBackplane) dispatch() {
+	for envelope := range b.queue {
+		b.mu.RLock()
+		for _, handler := range b.handlers {
+			handler(envelope)
+		}
+		b.mu.RUnlock()
+	}
+}
@@ -0,0 +1,171 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	// How long published envelopes are kept before the TTL index removes them
+	mongoBackplaneTTL = time.Minute
+
+	// Envelopes waiting to be inserted; beyond this Publish fails instead of blocking the hub
+	mongoBackplaneQueueSize = 1024
+)
+
+type mongoEnvelope struct {
+	ID        string    `bson:"_id,omitempty"`
+	NodeID    string    `bson:"node_id"`
+	Payload   string    `bson:"payload"` // JSON-encoded Envelope
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+// MongoBackplane relays envelopes between nodes through a MongoDB change stream.
+// It requires MongoDB to run as a replica set (change streams are unavailable on standalone servers).
+type MongoBackplane struct {
+	collection *mongo.Collection
+	queue      chan *Envelope
+	ctx        context.Context
+	cancel     context.CancelFunc
+}
+
+func NewMongoBackplane(collection *mongo.Collection) *MongoBackplane {
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &MongoBackplane{
+		collection: collection,
+		queue:      make(chan *Envelope, mongoBackplaneQueueSize),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+	b.ensureIndexes()
+	go b.publishLoop()
+	return b
+}
+
+func (b *MongoBackplane) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := b.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "created_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(mongoBackplaneTTL.Seconds())),
+	})
+	if err != nil {
+		log.Printf("[WARNING]: unable to create backplane TTL index: %v", err)
+	}
+}
+
+// Publish queues the envelope for insertion. It never waits on MongoDB, the caller is usually the hub loop.
+func (b *MongoBackplane) Publish(envelope *Envelope) error {
+	select {
+	case b.queue <- envelope:
+		return nil
+	default:
+		return errors.New("backplane queue is full")
+	}
+}
+
+// publishLoop inserts queued envelopes until the backplane is closed
+func (b *MongoBackplane) publishLoop() {
+	for {
+		select {
+		case <-b.ctx.Done():
+			return
+		case envelope := <-b.queue:
+			if err := b.insert(envelope); err != nil {
+				log.Printf("Backplane: dropping %s envelope: %v", envelope.Kind, err)
+			}
+		}
+	}
+}
+
+func (b *MongoBackplane) insert(envelope *Envelope) error {
+	ctx, cancel := context.WithTimeout(b.ctx, 5*time.Second)
+	defer cancel()
+
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+
+	_, err = b.collection.InsertOne(ctx, mongoEnvelope{
+		NodeID:    envelope.NodeID,
+		Payload:   string(payload),
+		CreatedAt: time.Now(),
+	})
+	return err
+}
+
+func (b *MongoBackplane) Subscribe(handler func(envelope *Envelope)) error {
+	stream, err := b.watch(nil)
+	if err != nil {
+		return err
+	}
+	go b.consume(stream, handler)
+	return nil
+}
+
+func (b *MongoBackplane) Close() error {
+	b.cancel()
+	return nil
+}
+
+// watch opens the change stream, resuming after the given token when set
+func (b *MongoBackplane) watch(resumeToken bson.Raw) (*mongo.ChangeStream, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"operationType": "insert"}}},
+	}
+	opts := options.ChangeStream()
+	if resumeToken != nil {
+		opts.SetResumeAfter(resumeToken)
+	}
+	return b.collection.Watch(b.ctx, pipeline, opts)
+}
+
+// consume reads the change stream until the backplane is closed, reopening it after errors
+func (b *MongoBackplane) consume(stream *mongo.ChangeStream, handler func(envelope *Envelope)) {
+	for {
+		for stream.Next(b.ctx) {
+			var change struct {
+				FullDocument mongoEnvelope `bson:"fullDocument"`
+			}
+			if err := stream.Decode(&change); err != nil {
+				log.Printf("Backplane: decode error: %v", err)
+				continue
+			}
+
+			var envelope Envelope
+			if err := json.Unmarshal([]byte(change.FullDocument.Payload), &envelope); err != nil {
+				log.Printf("Backplane: invalid payload: %v", err)
+				continue
+			}
+			handler(&envelope)
+		}
+
+		if err := stream.Err(); err != nil {
+			log.Printf("Backplane: change stream error: %v", err)
+		}
+		resumeToken := stream.ResumeToken()
+		stream.Close(context.Background())
+
+		// Reopen where we left off until closed
+		for {
+			if b.ctx.Err() != nil {
+				return
+			}
+			time.Sleep(time.Second)
+			var err error
+			if stream, err = b.watch(resumeToken); err == nil {
+				break
+			}
+			log.Printf("Backplane: unable to reopen change stream: %v", err)
+		}
+	}
+}
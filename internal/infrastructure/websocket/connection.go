@@ -62,45 +62,14 @@ func (c *Client) ReadPump() {
 			break
 		}
 
-		var message Message
-		if err := json.Unmarshal(messageBytes, &message); err != nil {
+		var event InboundEvent
+		if err := json.Unmarshal(messageBytes, &event); err != nil {
 			log.Printf("Error unmarshaling message: %v", err)
+			c.sendError(&InboundEvent{}, "invalid_frame", "invalid JSON frame")
 			continue
 		}
 
-		// Handle different message types
-		switch message.Type {
-		case "subscribe":
-			// Subscribe to a chat
-			if chatID := message.ChatID; chatID != "" {
-				c.Mu.Lock()
-				c.Chats[chatID] = true
-				c.Mu.Unlock()
-			}
-			if groupID := message.GroupID; groupID != "" {
-				c.Mu.Lock()
-				c.Chats[groupID] = true
-				c.Mu.Unlock()
-			}
-		case "unsubscribe":
-			// Unsubscribe from a chat
-			if chatID := message.ChatID; chatID != "" {
-				c.Mu.Lock()
-				delete(c.Chats, chatID)
-				c.Mu.Unlock()
-			}
-			if groupID := message.GroupID; groupID != "" {
-				c.Mu.Lock()
-				delete(c.Chats, groupID)
-				c.Mu.Unlock()
-			}
-		case "typing":
-			// Broadcast typing indicator
-			c.Hub.Broadcast <- &message
-		default:
-			// Broadcast other messages
-			c.Hub.Broadcast <- &message
-		}
+		c.handleInbound(&event)
 	}
 }
 
@@ -0,0 +1,136 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// Events kept per user for replay; must stay below the client send buffer so a replay never overflows it
+	eventLogSize = 200
+
+	// Events older than this are dropped; a client further behind must resync
+	eventLogRetention = 10 * time.Minute
+)
+
+// ephemeralEvents are not worth replaying and carry no sequence number
+var ephemeralEvents = map[string]bool{
+	EventTyping:   true,
+	EventPresence: true,
+	EventAck:      true,
+	EventError:    true,
+}
+
+type loggedEvent struct {
+	seq  uint64
+	at   time.Time
+	data []byte
+}
+
+type userEventLog struct {
+	seq    uint64 // Last sequence number handed out, survives pruning
+	events []loggedEvent
+}
+
+// eventLog assigns per-user sequence numbers to outbound events and keeps a bounded window of them for replay
+type eventLog struct {
+	mu        sync.Mutex
+	size      int
+	retention time.Duration
+	users     map[string]*userEventLog
+}
+
+func newEventLog(size int, retention time.Duration) *eventLog {
+	return &eventLog{
+		size:      size,
+		retention: retention,
+		users:     make(map[string]*userEventLog),
+	}
+}
+
+// append stamps the next sequence number of the user on a copy of message, records it and returns the encoded frame
+func (l *eventLog) append(userID string, message *Message) []byte {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	userLog, ok := l.users[userID]
+	if !ok {
+		userLog = &userEventLog{}
+		l.users[userID] = userLog
+	}
+	userLog.seq++
+
+	stamped := *message
+	stamped.Seq = userLog.seq
+	data, err := json.Marshal(&stamped)
+	if err != nil {
+		log.Printf("Error marshaling message: %v", err)
+		return nil
+	}
+
+	userLog.events = append(userLog.events, loggedEvent{seq: userLog.seq, at: time.Now(), data: data})
+	if len(userLog.events) > l.size {
+		userLog.events = userLog.events[len(userLog.events)-l.size:]
+	}
+
+	return data
+}
+
+// since returns the events after seq. ok is false when the gap can no longer be replayed.
+func (l *eventLog) since(userID string, seq uint64) (events [][]byte, latest uint64, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	userLog, found := l.users[userID]
+	if !found {
+		// Nothing was ever sent to this user on this node
+		return nil, 0, seq == 0
+	}
+
+	latest = userLog.seq
+	if seq > latest {
+		// Client is ahead of us, e.g. the server restarted
+		return nil, latest, false
+	}
+	if seq == latest {
+		return nil, latest, true
+	}
+	if len(userLog.events) == 0 || userLog.events[0].seq > seq+1 {
+		return nil, latest, false
+	}
+
+	for _, event := range userLog.events {
+		if event.seq > seq {
+			events = append(events, event.data)
+		}
+	}
+	return events, latest, true
+}
+
+// latest returns the last sequence number handed out to the user
+func (l *eventLog) latest(userID string) uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if userLog, ok := l.users[userID]; ok {
+		return userLog.seq
+	}
+	return 0
+}
+
+// prune drops events older than the retention window
+func (l *eventLog) prune(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cutoff := now.Add(-l.retention)
+	for _, userLog := range l.users {
+		i := 0
+		for i < len(userLog.events) && userLog.events[i].at.Before(cutoff) {
+			i++
+		}
+		userLog.events = userLog.events[i:]
+	}
+}
@@ -0,0 +1,170 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+)
+
+// Inbound event types a client is allowed to send. Anything else is rejected with an error frame.
+const (
+	EventSubscribe   = "subscribe"
+	EventUnsubscribe = "unsubscribe"
+	EventTyping      = "typing"
+	EventHeartbeat   = "heartbeat" // Sent periodically by the client, data.active=false reports the user idle
+
+	// Commands go through the use cases and are answered with an ack or error frame
+	EventSendMessage = "send_message"
+	EventReact       = "react"
+	EventUnreact     = "unreact"
+	EventMarkRead    = "mark_read"
+)
+
+// Outbound frame types answering an inbound event
+const (
+	EventAck   = "ack"
+	EventError = "error"
+)
+
+// EventPresence tells friends a user's status changed (online, away, dnd or offline) or their custom status did
+const EventPresence = "presence"
+
+// InboundEvent is a frame received from a client.
+// It deliberately has no SenderID: the sender is always the authenticated client.
+type InboundEvent struct {
+	Type      string          `json:"type"`
+	RequestID string          `json:"requestId,omitempty"` // Correlation ID chosen by the client, echoed in ack/error
+	ChatID    string          `json:"chatId,omitempty"`
+	GroupID   string          `json:"groupId,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+// ConversationID returns chatId, or falls back to groupId
+func (e *InboundEvent) ConversationID() string {
+	if e.ChatID != "" {
+		return e.ChatID
+	}
+	return e.GroupID
+}
+
+// Decode unmarshals the event payload into v
+func (e *InboundEvent) Decode(v interface{}) error {
+	if len(e.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(e.Data, v)
+}
+
+// CommandExecutor runs state-changing client commands on behalf of the authenticated user.
+// It lives in the interface layer so the hub does not depend on the use cases.
+type CommandExecutor interface {
+	// clientID identifies the issuing connection and is set as Origin of the events it broadcasts
+	Execute(userID, clientID string, event *InboundEvent) (interface{}, error)
+}
+
+// handleInbound validates and dispatches a client event
+func (c *Client) handleInbound(event *InboundEvent) {
+	// Whatever the client sends shows the user is active, heartbeats say so explicitly
+	if event.Type != EventHeartbeat {
+		c.Hub.reportActivity(c, true)
+	}
+
+	switch event.Type {
+	case EventHeartbeat:
+		var data struct {
+			Active *bool `json:"active"`
+		}
+		if err := event.Decode(&data); err != nil {
+			c.sendError(event, "bad_request", "invalid data")
+			return
+		}
+		c.Hub.reportActivity(c, data.Active == nil || *data.Active)
+
+	case EventSubscribe:
+		conversationID := event.ConversationID()
+		if !c.Hub.IsMember(conversationID, c.UserID) {
+			c.sendError(event, "forbidden", "not a member of this conversation")
+			return
+		}
+		c.Mu.Lock()
+		c.Chats[conversationID] = true
+		c.Mu.Unlock()
+
+	case EventUnsubscribe:
+		c.Mu.Lock()
+		delete(c.Chats, event.ConversationID())
+		c.Mu.Unlock()
+
+	case EventTyping:
+		conversationID := event.ConversationID()
+		if !c.Hub.IsMember(conversationID, c.UserID) {
+			c.sendError(event, "forbidden", "not a member of this conversation")
+			return
+		}
+		var data map[string]interface{}
+		if err := event.Decode(&data); err != nil {
+			c.sendError(event, "bad_request", "invalid data")
+			return
+		}
+		c.Hub.Broadcast <- &Message{
+			Type:      EventTyping,
+			ChatID:    conversationID,
+			SenderID:  c.UserID,
+			Data:      data,
+			Timestamp: time.Now().Format(time.RFC3339),
+		}
+
+	case EventSendMessage, EventReact, EventUnreact, EventMarkRead:
+		if c.Hub.Commands == nil {
+			c.sendError(event, "unavailable", "commands are not enabled")
+			return
+		}
+		result, err := c.Hub.Commands.Execute(c.UserID, c.ID, event)
+		if err != nil {
+			c.sendError(event, errorCode(err), err.Error())
+			return
+		}
+		c.Hub.sendToClient(c, &Message{
+			Type:      EventAck,
+			RequestID: event.RequestID,
+			Data: map[string]interface{}{
+				"requestType": event.Type,
+				"result":      result,
+			},
+			Timestamp: time.Now().Format(time.RFC3339),
+		})
+
+	default:
+		log.Printf("WebSocket: rejected event %q from user %s", event.Type, c.UserID)
+		c.sendError(event, "unknown_type", "unsupported event type")
+	}
+}
+
+// sendError queues an error frame for this connection only
+func (c *Client) sendError(event *InboundEvent, code, message string) {
+	c.Hub.sendToClient(c, &Message{
+		Type:      EventError,
+		RequestID: event.RequestID,
+		Data: map[string]interface{}{
+			"requestType": event.Type,
+			"code":        code,
+			"error":       message,
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+// errorCode maps use case errors to error frame codes, mirroring the HTTP status mapping
+func errorCode(err error) string {
+	switch {
+	case strings.Contains(err.Error(), "unauthorized"), strings.Contains(err.Error(), "forbidden"):
+		return "forbidden"
+	case strings.Contains(err.Error(), "not found"):
+		return "not_found"
+	case strings.Contains(err.Error(), "required"), strings.Contains(err.Error(), "invalid"):
+		return "bad_request"
+	default:
+		return "internal"
+	}
+}
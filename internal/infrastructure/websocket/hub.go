@@ -4,24 +4,38 @@ import (
 	"encoding/json"
 	"log"
 	"sync"
+	"time"
 
 	"github.com/TomTom2k/chat-app/server/internal/domain"
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
 )
 
 // Client represents a WebSocket client
 type Client struct {
+	ID       string // Random per connection, see Message.Origin
 	Hub      *Hub
 	Conn     *Connection
 	UserID   string
 	Send     chan []byte
 	Chats    map[string]bool // Track which chats this client is subscribed to
 	Mu       sync.RWMutex
+
+	// Set when the client reconnects with ?since=<seq>: missed events are replayed before live ones
+	Resume bool
+	Since  uint64
+	Node   string // Node that issued the sequence numbers, sequence numbers are per node
+
+	// Login session the connection was authenticated with, its sockets are closed when it is revoked
+	SessionID string
+
+	// Last time the client reported user activity, zero once it reported the user idle. Guarded by Mu.
+	lastActiveAt time.Time
 }
 
 // Hub maintains the set of active clients and broadcasts messages to the clients
 type Hub struct {
-	// Registered clients
-	clients map[string]*Client // userID -> Client
+	// Registered clients, one set of connections (devices/tabs) per user
+	clients map[string]map[*Client]bool // userID -> connections
 
 	// Inbound messages from the clients
 	Broadcast chan *Message
@@ -32,6 +46,9 @@ type Hub struct {
 	// Unregister requests from clients
 	Unregister chan *Client
 
+	// Users whose connections reported activity or idleness, their away state is re-evaluated in Run
+	activity chan string
+
 	// User repository for updating online status
 	UserRepo domain.UserRepository
 
@@ -41,85 +58,240 @@ type Hub struct {
 	// Message repository for getting message info
 	MessageRepo domain.MessageRepository
 
+	// Friend repository for blocks; typing and presence are never relayed across a block
+	FriendRepo domain.FriendRepository
+
+	// Executes state-changing client commands (send_message, react, ...), set after the use cases are built
+	Commands CommandExecutor
+
+	// Per-user sequence numbers and recent events for replay on reconnect
+	events *eventLog
+
+	// Identifies this server replica on the backplane
+	NodeID string
+
+	// Fans events out across replicas; every node delivers to its own clients
+	Backplane Backplane
+
+	// Envelopes received from the backplane, handled in Run
+	remote chan *Envelope
+
+	// Cluster-wide presence: userID -> nodeID -> online or away, for the nodes where the user has a connection
+	presence map[string]map[string]string
+
+	// Last time each node was heard from
+	nodeSeen map[string]time.Time
+
+	// Sessions with a live socket on other nodes, from their last heartbeat: nodeID -> session IDs
+	remoteSessions map[string]map[string]bool
+
 	mu sync.RWMutex
 }
 
 // Message represents a WebSocket message
 type Message struct {
-	Type      string                 `json:"type"`      // message, typing, online, offline
+	Type      string                 `json:"type"`                // message, typing, presence, ...
+	RequestID string                 `json:"requestId,omitempty"` // Chỉ có ở ack/error, echo correlation ID của client
+	Seq       uint64                 `json:"seq,omitempty"`       // Sequence number theo từng user, dùng để replay khi reconnect
 	ChatID    string                 `json:"chatId,omitempty"`
 	GroupID   string                 `json:"groupId,omitempty"`
 	SenderID  string                 `json:"senderId,omitempty"`
 	Content   string                 `json:"content,omitempty"`
 	Data      map[string]interface{} `json:"data,omitempty"`
 	Timestamp string                 `json:"timestamp,omitempty"`
+
+	// Connection that issued the command behind the event; it gets an ack instead, the user's other devices get the event
+	Origin string `json:"-"`
+}
+
+// NewClientID returns a random ID for a new connection
+func NewClientID() string {
+	return generateNodeID()
 }
 
-func NewHub(userRepo domain.UserRepository, conversationRepo domain.ConversationRepository, messageRepo domain.MessageRepository) *Hub {
+func NewHub(userRepo domain.UserRepository, conversationRepo domain.ConversationRepository, messageRepo domain.MessageRepository, backplane Backplane) *Hub {
+	if backplane == nil {
+		backplane = NewMemoryBackplane()
+	}
 	return &Hub{
-		clients:          make(map[string]*Client),
+		clients:          make(map[string]map[*Client]bool),
 		Broadcast:        make(chan *Message, 256),
 		Register:         make(chan *Client),
 		Unregister:       make(chan *Client),
+		activity:         make(chan string, 256),
 		UserRepo:         userRepo,
 		ConversationRepo: conversationRepo,
 		MessageRepo:      messageRepo,
+		events:           newEventLog(eventLogSize, eventLogRetention),
+		NodeID:           generateNodeID(),
+		Backplane:        backplane,
+		remote:           make(chan *Envelope, 1024),
+		presence:         make(map[string]map[string]string),
+		nodeSeen:         make(map[string]time.Time),
+		remoteSessions:   make(map[string]map[string]bool),
 		mu:               sync.RWMutex{},
 	}
 }
 
 func (h *Hub) Run() {
+	subscriber := func(envelope *Envelope) { h.remote <- envelope }
+	if err := h.Backplane.Subscribe(subscriber); err != nil {
+		log.Printf("Hub: backplane unavailable, running single-node: %v", err)
+		h.Backplane = NewMemoryBackplane()
+		h.Backplane.Subscribe(subscriber)
+	}
+	log.Printf("Hub: node %s started", h.NodeID)
+
+	pruneTicker := time.NewTicker(time.Minute)
+	defer pruneTicker.Stop()
+	heartbeatTicker := time.NewTicker(presenceHeartbeat)
+	defer heartbeatTicker.Stop()
+
 	for {
 		select {
 		case client := <-h.Register:
 			h.mu.Lock()
-			h.clients[client.UserID] = client
-			h.mu.Unlock()
-			
-			// Update user online status
-			user, err := h.UserRepo.GetByID(client.UserID)
-			if err == nil {
-				user.Online = true
-				h.UserRepo.UpdateUser(user)
+			connections, ok := h.clients[client.UserID]
+			if !ok {
+				connections = make(map[*Client]bool)
+				h.clients[client.UserID] = connections
 			}
+			connections[client] = true
+			h.mu.Unlock()
 			
-			// Broadcast online status to friends
-			h.broadcastOnlineStatus(client.UserID, true)
+			// A new connection counts as activity. Presence is reference-counted: the first connection
+			// brings the user online, later ones may bring them back from away.
+			client.Mu.Lock()
+			client.lastActiveAt = time.Now()
+			client.Mu.Unlock()
+			h.refreshLocalPresence(client.UserID, time.Now())
 			
-			log.Printf("Client registered: %s (Total: %d)", client.UserID, len(h.clients))
+			// Replay runs inside the hub loop so no live event can slip in before the gap is filled
+			h.syncClient(client)
+
+			log.Printf("Client registered: %s (Devices: %d, Users: %d)", client.UserID, h.ConnectionCount(client.UserID), h.userCount())
 
 		case client := <-h.Unregister:
 			h.mu.Lock()
-			if _, ok := h.clients[client.UserID]; ok {
-				delete(h.clients, client.UserID)
+			lastConnection := false
+			if connections, ok := h.clients[client.UserID]; ok && connections[client] {
+				delete(connections, client)
 				close(client.Send)
+				if len(connections) == 0 {
+					delete(h.clients, client.UserID)
+					lastConnection = true
+				}
 			}
 			h.mu.Unlock()
 			
-			// Update user offline status
-			user, err := h.UserRepo.GetByID(client.UserID)
-			if err == nil {
-				user.Online = false
-				h.UserRepo.UpdateUser(user)
+			// Only the last connection going away takes the user offline
+			if lastConnection {
+				// The user may still be connected to another node
+				h.publish(&Envelope{Kind: envelopePresence, UserID: client.UserID, Presence: entity.PresenceOffline})
+			} else {
+				// The remaining connections may all be idle
+				h.refreshLocalPresence(client.UserID, time.Now())
 			}
 			
-			// Broadcast offline status to friends
-			h.broadcastOnlineStatus(client.UserID, false)
-			
-			log.Printf("Client unregistered: %s (Total: %d)", client.UserID, len(h.clients))
+			log.Printf("Client unregistered: %s (Devices: %d, Users: %d)", client.UserID, h.ConnectionCount(client.UserID), h.userCount())
 
 		case message := <-h.Broadcast:
-			h.handleBroadcast(message)
+			h.publish(&Envelope{Kind: envelopeEvent, Message: message, Origin: message.Origin})
+
+		case envelope := <-h.remote:
+			h.handleEnvelope(envelope)
+
+		case userID := <-h.activity:
+			h.refreshLocalPresence(userID, time.Now())
+
+		case now := <-pruneTicker.C:
+			h.events.prune(now)
+
+		case now := <-heartbeatTicker.C:
+			// Users whose connections all went quiet become away before the snapshot is taken
+			users, away := h.localUsers(now)
+			for _, userID := range users {
+				h.refreshLocalPresence(userID, now)
+			}
+			h.publish(&Envelope{Kind: envelopeHeartbeat, Users: users, Away: away, Sessions: h.localSessions()})
+			h.expireNodes(now)
 		}
 	}
 }
 
+// syncClient replays missed events to a resuming client, then tells it the current sequence number.
+// A client whose gap is no longer in the log is asked to resync from the REST API instead.
+func (h *Hub) syncClient(client *Client) {
+	if !client.Resume {
+		h.sendToClient(client, &Message{
+			Type: "sync",
+			Data: map[string]interface{}{
+				"seq":  h.events.latest(client.UserID),
+				"node": h.NodeID,
+			},
+		})
+		return
+	}
+
+	events, latest, ok := h.events.since(client.UserID, client.Since)
+	// Sequence numbers issued by another node mean nothing here
+	if !ok || (client.Node != "" && client.Node != h.NodeID) {
+		h.sendToClient(client, &Message{
+			Type: "resync_required",
+			Data: map[string]interface{}{
+				"seq":  h.events.latest(client.UserID),
+				"node": h.NodeID,
+			},
+		})
+		return
+	}
+
+	h.mu.RLock()
+	for _, data := range events {
+		h.deliver(client, data)
+	}
+	h.mu.RUnlock()
+
+	h.sendToClient(client, &Message{
+		Type: "sync",
+		Data: map[string]interface{}{
+			"seq":      latest,
+			"node":     h.NodeID,
+			"replayed": len(events),
+		},
+	})
+}
+
 func (h *Hub) handleBroadcast(message *Message) {
 	switch message.Type {
 	case "message":
 		h.broadcastToChat(message)
 	case "typing":
+		// Typing indicators are not relayed across a block, nor to the typing user's other devices
+		excluded := h.blockedWith(message.SenderID)
+		excluded[message.SenderID] = true
+		h.broadcastToChatExcept(message, excluded)
+	case "message_edited", "message_deleted", "conversation_read":
+		h.broadcastToChat(message)
+	case "member_added", "member_role_changed", "ownership_transferred",
+		"conversation_updated", "permissions_updated", "message_pinned", "message_unpinned":
 		h.broadcastToChat(message)
+	case "member_removed", "member_left":
+		h.broadcastToChat(message)
+		// The user is no longer a member and would not get the event otherwise
+		if userID, ok := message.Data["userId"].(string); ok && userID != message.SenderID {
+			h.sendToUser(userID, message)
+		}
+	case "join_request":
+		h.sendToReviewers(message)
+	case "join_request_approved", "join_request_rejected":
+		// Only the requester is told about the decision
+		if userID, ok := message.Data["userId"].(string); ok {
+			h.sendToUser(userID, message)
+		}
+	case "message_hidden":
+		// Only concerns the user who hid the message
+		h.sendToUser(message.SenderID, message)
 	case "reaction", "read_receipt":
 		// Get conversation ID from message data or from the original message
 		conversationID := message.ChatID
@@ -127,32 +299,41 @@ func (h *Hub) handleBroadcast(message *Message) {
 			conversationID = message.GroupID
 		}
 		
-		if conversationID != "" {
-			message.ChatID = conversationID
-			h.broadcastToChat(message)
-		} else {
+		if conversationID == "" {
 			// Try to get conversationID from messageID
 			msgID, ok := message.Data["messageId"].(string)
-			if ok && h.MessageRepo != nil {
+			if !ok || h.MessageRepo == nil {
+				log.Printf("Hub: dropping %s event without conversation", message.Type)
+				return
+			}
 				msg, err := h.MessageRepo.GetMessageByID(msgID)
-				if err == nil {
-					message.ChatID = msg.GetConversationID()
-					h.broadcastToChat(message)
-				} else {
-					h.broadcastToAll(message)
-				}
-			} else {
-				h.broadcastToAll(message)
+			if err != nil {
+				log.Printf("Hub: dropping %s event for unknown message %s", message.Type, msgID)
+				return
 			}
+			conversationID = msg.GetConversationID()
 		}
-	case "online", "offline":
+
+		message.ChatID = conversationID
+					h.broadcastToChat(message)
+	case EventPresence:
 		h.broadcastToFriends(message)
+	case "profile_updated":
+		h.broadcastToFriends(message)
+		// The user's other devices show the new profile too
+		h.sendToUser(message.SenderID, message)
 	default:
-		h.broadcastToAll(message)
+		// Never fan out unknown event types
+		log.Printf("Hub: dropping event with unknown type %q", message.Type)
 	}
 }
 
 func (h *Hub) broadcastToChat(message *Message) {
+	h.broadcastToChatExcept(message, nil)
+}
+
+// broadcastToChatExcept sends to the conversation members, skipping the excluded users and the originating connection
+func (h *Hub) broadcastToChatExcept(message *Message, excluded map[string]bool) {
 	conversationID := message.ChatID
 	if conversationID == "" {
 		conversationID = message.GroupID
@@ -170,106 +351,125 @@ func (h *Hub) broadcastToChat(message *Message) {
 		return
 	}
 
-	// Send to all members of the conversation (if they're online)
+	// Send to all members of the conversation; offline members still get the event logged for replay
+	shared := h.messageToBytes(message)
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
 	for _, member := range conversation.Members {
-		// Skip sender
-		if member.UserID == message.SenderID {
+		if excluded[member.UserID] {
 			continue
 		}
 
-		if client, ok := h.clients[member.UserID]; ok {
-			select {
-			case client.Send <- h.messageToBytes(message):
-			default:
-				close(client.Send)
-				delete(h.clients, member.UserID)
+		// The sender's other devices get the event too
+		data := h.payloadFor(member.UserID, message, shared)
+		for client := range h.clients[member.UserID] {
+			if message.Origin != "" && client.ID == message.Origin {
+				continue
 			}
+			h.deliver(client, data)
+		}
+	}
+}
+
+// sendToReviewers notifies the group members allowed to review join requests
+func (h *Hub) sendToReviewers(message *Message) {
+	conversation, err := h.ConversationRepo.GetConversationByID(message.ChatID)
+	if err != nil {
+		return
+	}
+
+	for _, member := range conversation.Members {
+		if conversation.Can(member.UserID, entity.PermissionInvite) {
+			h.sendToUser(member.UserID, message)
 		}
 	}
 }
 
 func (h *Hub) broadcastToSubscribedClients(message *Message, chatID string) {
+	data := h.messageToBytes(message)
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
 	// Send to all clients subscribed to this chat
-	for userID, client := range h.clients {
+	for _, connections := range h.clients {
+		for client := range connections {
 		client.Mu.RLock()
 		subscribed := client.Chats[chatID]
 		client.Mu.RUnlock()
 
-		if subscribed {
-			select {
-			case client.Send <- h.messageToBytes(message):
-			default:
-				close(client.Send)
-				delete(h.clients, userID)
+		if subscribed && (message.Origin == "" || client.ID != message.Origin) {
+				h.deliver(client, data)
 			}
 		}
 	}
 }
 
 func (h *Hub) broadcastToFriends(message *Message) {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
-
 	// Get user's friends
-	user, err := h.UserRepo.GetByID(message.SenderID)
+	if h.FriendRepo == nil {
+		return
+	}
+	friendIDs, err := h.FriendRepo.GetFriendUserIDs(message.SenderID)
 	if err != nil {
 		return
 	}
 
+	blocked := h.blockedWith(message.SenderID)
+	shared := h.messageToBytes(message)
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
 	// Send to all online friends
-	for _, friendID := range user.Friends {
-		if client, ok := h.clients[friendID]; ok {
-			select {
-			case client.Send <- h.messageToBytes(message):
-			default:
-				close(client.Send)
-				delete(h.clients, friendID)
-			}
+	for _, friendID := range friendIDs {
+		if blocked[friendID] {
+			continue
+		}
+		data := h.payloadFor(friendID, message, shared)
+		for client := range h.clients[friendID] {
+			h.deliver(client, data)
 		}
 	}
 }
 
-func (h *Hub) broadcastToAll(message *Message) {
+func (h *Hub) sendToUser(userID string, message *Message) {
+	data := h.payloadFor(userID, message, h.messageToBytes(message))
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
-	for _, client := range h.clients {
-		select {
-		case client.Send <- h.messageToBytes(message):
-		default:
-			close(client.Send)
-			delete(h.clients, client.UserID)
-		}
+	for client := range h.clients[userID] {
+		h.deliver(client, data)
 	}
 }
 
-func (h *Hub) broadcastOnlineStatus(userID string, online bool) {
-	_, err := h.UserRepo.GetByID(userID)
-	if err != nil {
-		return
-	}
+// sendToClient queues a message for a single connection
+func (h *Hub) sendToClient(client *Client, message *Message) {
+	data := h.messageToBytes(message)
+	h.mu.RLock()
+	defer h.mu.RUnlock()
 
-	statusType := "offline"
-	if online {
-		statusType = "online"
+	if h.clients[client.UserID][client] {
+			h.deliver(client, data)
+		}
 	}
 
-	message := &Message{
-		Type:     statusType,
-		SenderID: userID,
-		Data: map[string]interface{}{
-			"userId": userID,
-			"online": online,
-		},
+// payloadFor returns the frame for userID: durable events get the user's next sequence number
+// and are logged for replay, ephemeral ones reuse the shared encoding.
+func (h *Hub) payloadFor(userID string, message *Message, shared []byte) []byte {
+	if ephemeralEvents[message.Type] {
+		return shared
 	}
+	return h.events.append(userID, message)
+}
 
-	h.broadcastToFriends(message)
+// deliver queues data on a single connection. A connection whose buffer is full is
+// considered dead and unregistered asynchronously, since the caller holds the read lock.
+func (h *Hub) deliver(client *Client, data []byte) {
+	select {
+	case client.Send <- data:
+	default:
+		go func() { h.Unregister <- client }()
+	}
 }
 
 func (h *Hub) messageToBytes(message *Message) []byte {
@@ -281,16 +481,78 @@ func (h *Hub) messageToBytes(message *Message) []byte {
 	return data
 }
 
-func (h *Hub) GetClient(userID string) *Client {
+// GetClients returns every live connection of the user
+func (h *Hub) GetClients(userID string) []*Client {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
-	return h.clients[userID]
+
+	clients := make([]*Client, 0, len(h.clients[userID]))
+	for client := range h.clients[userID] {
+		clients = append(clients, client)
+	}
+	return clients
 }
 
-func (h *Hub) IsUserOnline(userID string) bool {
+// ConnectionCount returns the number of live connections of the user
+func (h *Hub) ConnectionCount(userID string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients[userID])
+}
+
+func (h *Hub) userCount() int {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
-	_, ok := h.clients[userID]
+	return len(h.clients)
+}
+
+// blockedWith returns the users who blocked, or were blocked by, userID
+func (h *Hub) blockedWith(userID string) map[string]bool {
+	blocked := make(map[string]bool)
+	if h.FriendRepo == nil {
+		return blocked
+	}
+	userIDs, err := h.FriendRepo.GetBlockedUserIDs(userID)
+	if err != nil {
+		log.Printf("Hub: unable to load blocks of %s: %v", userID, err)
+		return blocked
+	}
+	for _, id := range userIDs {
+		blocked[id] = true
+	}
+	return blocked
+}
+
+// IsMember reports whether the user belongs to the conversation
+func (h *Hub) IsMember(conversationID, userID string) bool {
+	if conversationID == "" {
+		return false
+	}
+	conversation, err := h.ConversationRepo.GetConversationByID(conversationID)
+	if err != nil {
+		return false
+	}
+	_, ok := conversation.GetMember(userID)
 	return ok
 }
 
+// IsUserOnline reports whether the user is connected to any node of the cluster
+func (h *Hub) IsUserOnline(userID string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients[userID]) > 0 || len(h.presence[userID]) > 0
+}
+
+// ConnectionState returns online when the user is active on any node, away when every connection
+// of the user is idle and offline otherwise. The status the user chose is not applied.
+func (h *Hub) ConnectionState(userID string) string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	state := h.connectionStateLocked(userID)
+	if state == entity.PresenceOffline && len(h.clients[userID]) > 0 {
+		// Connected here, the presence envelope has not come back yet
+		return entity.PresenceOnline
+	}
+	return state
+}
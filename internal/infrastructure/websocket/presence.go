@@ -0,0 +1,322 @@
+package websocket
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+)
+
+const (
+	// How often a node announces the users connected to it
+	presenceHeartbeat = 30 * time.Second
+
+	// A node silent for this long is considered dead and its users offline
+	presenceNodeTimeout = 3 * presenceHeartbeat
+
+	// A connection without activity for this long is idle; a user whose connections are all idle is away
+	presenceAwayAfter = 5 * time.Minute
+)
+
+// publish sends an envelope to every node. If the backplane is unavailable the envelope
+// is still handled locally so a single node keeps working.
+func (h *Hub) publish(envelope *Envelope) {
+	envelope.NodeID = h.NodeID
+	if err := h.Backplane.Publish(envelope); err != nil {
+		log.Printf("Hub: backplane publish failed, delivering locally: %v", err)
+		h.handleEnvelope(envelope)
+	}
+}
+
+// handleEnvelope processes an envelope received from the backplane (our own ones included)
+func (h *Hub) handleEnvelope(envelope *Envelope) {
+	h.mu.Lock()
+	h.nodeSeen[envelope.NodeID] = time.Now()
+	h.mu.Unlock()
+
+	switch envelope.Kind {
+	case envelopeEvent:
+		if envelope.Message != nil {
+			envelope.Message.Origin = envelope.Origin
+			h.handleBroadcast(envelope.Message)
+		}
+	case envelopePresence:
+		h.setPresence(envelope.NodeID, envelope.UserID, envelope.Presence)
+	case envelopeHeartbeat:
+		h.applyHeartbeat(envelope.NodeID, envelope.Users, envelope.Away)
+		h.applySessionSnapshot(envelope.NodeID, envelope.Sessions)
+	case envelopeCloseSession:
+		h.closeLocalSessions(envelope.Sessions)
+	}
+}
+
+// setPresence records the user's connection state on the node and reacts to cluster-wide transitions
+func (h *Hub) setPresence(nodeID, userID, state string) {
+	h.mu.Lock()
+	before, after := h.setNodeStateLocked(userID, nodeID, state)
+	h.mu.Unlock()
+
+	if before != after {
+		// Only the node that saw the connection change persists it
+		h.presenceChanged(userID, before, after, nodeID == h.NodeID)
+	}
+}
+
+// setNodeStateLocked stores the user's state on one node, offline removes it.
+// Returns the cluster-wide connection state before and after. Callers hold h.mu.
+func (h *Hub) setNodeStateLocked(userID, nodeID, state string) (before, after string) {
+	before = h.connectionStateLocked(userID)
+	if state == entity.PresenceOffline {
+		if nodes, ok := h.presence[userID]; ok {
+			delete(nodes, nodeID)
+			if len(nodes) == 0 {
+				delete(h.presence, userID)
+			}
+		}
+	} else {
+		if h.presence[userID] == nil {
+			h.presence[userID] = make(map[string]string)
+		}
+		h.presence[userID][nodeID] = state
+	}
+	return before, h.connectionStateLocked(userID)
+}
+
+// connectionStateLocked is online if the user is active on any node, away if connected but idle everywhere
+func (h *Hub) connectionStateLocked(userID string) string {
+	state := entity.PresenceOffline
+	for _, nodeState := range h.presence[userID] {
+		if nodeState == entity.PresenceOnline {
+			return entity.PresenceOnline
+		}
+		state = entity.PresenceAway
+	}
+	return state
+}
+
+// presenceTransition is a change of a user's cluster-wide connection state
+type presenceTransition struct {
+	before, after string
+}
+
+// applyHeartbeat reconciles the presence table with a remote node's snapshot, e.g. after missed envelopes
+func (h *Hub) applyHeartbeat(nodeID string, users, away []string) {
+	if nodeID == h.NodeID {
+		return
+	}
+
+	listed := make(map[string]string, len(users))
+	for _, userID := range users {
+		listed[userID] = entity.PresenceOnline
+	}
+	for _, userID := range away {
+		if _, ok := listed[userID]; ok {
+			listed[userID] = entity.PresenceAway
+		}
+	}
+
+	changed := make(map[string]presenceTransition)
+	h.mu.Lock()
+	for userID, nodes := range h.presence {
+		if _, ok := nodes[nodeID]; ok && listed[userID] == "" {
+			if before, after := h.setNodeStateLocked(userID, nodeID, entity.PresenceOffline); before != after {
+				changed[userID] = presenceTransition{before, after}
+			}
+		}
+	}
+	for userID, state := range listed {
+		if before, after := h.setNodeStateLocked(userID, nodeID, state); before != after {
+			changed[userID] = presenceTransition{before, after}
+		}
+	}
+	h.mu.Unlock()
+
+	for userID, transition := range changed {
+		h.presenceChanged(userID, transition.before, transition.after, false)
+	}
+}
+
+// expireNodes drops nodes that stopped sending heartbeats; their users go offline
+func (h *Hub) expireNodes(now time.Time) {
+	changed := make(map[string]presenceTransition)
+	h.mu.Lock()
+	for nodeID, seen := range h.nodeSeen {
+		if nodeID == h.NodeID || now.Sub(seen) < presenceNodeTimeout {
+			continue
+		}
+		log.Printf("Hub: node %s timed out", nodeID)
+		delete(h.nodeSeen, nodeID)
+		delete(h.remoteSessions, nodeID)
+		for userID, nodes := range h.presence {
+			if _, ok := nodes[nodeID]; !ok {
+				continue
+			}
+			before, after := h.setNodeStateLocked(userID, nodeID, entity.PresenceOffline)
+			if transition, ok := changed[userID]; ok {
+				// Several nodes may time out at once
+				before = transition.before
+			}
+			if before != after {
+				changed[userID] = presenceTransition{before, after}
+			}
+		}
+	}
+	h.mu.Unlock()
+
+	// The dead node cannot persist it, so every node does (idempotent)
+	for userID, transition := range changed {
+		h.presenceChanged(userID, transition.before, transition.after, true)
+	}
+}
+
+// localUsers returns the users with at least one connection on this node, and those of them whose connections are all idle
+func (h *Hub) localUsers(now time.Time) (users, away []string) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	users = make([]string, 0, len(h.clients))
+	for userID, connections := range h.clients {
+		users = append(users, userID)
+		if localStateLocked(connections, now) == entity.PresenceAway {
+			away = append(away, userID)
+		}
+	}
+	return users, away
+}
+
+// localStateLocked is online if any of the connections is active, away otherwise
+func localStateLocked(connections map[*Client]bool, now time.Time) string {
+	for client := range connections {
+		if !client.idle(now) {
+			return entity.PresenceOnline
+		}
+	}
+	return entity.PresenceAway
+}
+
+// idle reports whether the client has not shown user activity for presenceAwayAfter, or reported the user idle
+func (c *Client) idle(now time.Time) bool {
+	c.Mu.RLock()
+	defer c.Mu.RUnlock()
+	return c.lastActiveAt.IsZero() || now.Sub(c.lastActiveAt) >= presenceAwayAfter
+}
+
+// reportActivity records that the client saw the user active, or idle, and wakes the hub when that flips the connection
+func (h *Hub) reportActivity(client *Client, active bool) {
+	now := time.Now()
+	wasIdle := client.idle(now)
+
+	client.Mu.Lock()
+	if active {
+		client.lastActiveAt = now
+	} else {
+		client.lastActiveAt = time.Time{}
+	}
+	client.Mu.Unlock()
+
+	if wasIdle == active {
+		select {
+		case h.activity <- client.UserID:
+		default:
+			// The heartbeat tick catches up
+		}
+	}
+}
+
+// refreshLocalPresence tells the cluster when the user's state on this node changed between online and away
+func (h *Hub) refreshLocalPresence(userID string, now time.Time) {
+	h.mu.RLock()
+	connections := h.clients[userID]
+	if len(connections) == 0 {
+		h.mu.RUnlock()
+		return
+	}
+	state := localStateLocked(connections, now)
+	published := h.presence[userID][h.NodeID]
+	h.mu.RUnlock()
+
+	// The table is updated when the envelope comes back
+	if state != published {
+		h.publish(&Envelope{Kind: envelopePresence, UserID: userID, Presence: state})
+	}
+}
+
+// presenceChanged persists connects and disconnects, disconnects also recording when the user was last seen,
+// and tells the user's friends connected to this node about the new state
+func (h *Hub) presenceChanged(userID, before, after string, persist bool) {
+	wasOnline, isOnline := before != entity.PresenceOffline, after != entity.PresenceOffline
+	if persist && wasOnline != isOnline {
+		if err := h.UserRepo.SetPresence(userID, isOnline, time.Now()); err != nil {
+			log.Printf("Hub: unable to save presence of %s: %v", userID, err)
+		}
+	}
+
+	user, err := h.UserRepo.GetByID(userID)
+	if err != nil {
+		return
+	}
+	if message := presenceEvent(user, after, time.Now()); message != nil {
+		h.broadcastToFriends(message)
+	}
+}
+
+// PublishPresence tells the user's friends on every node about a change of the status or custom status they chose
+func (h *Hub) PublishPresence(userID string) {
+	user, err := h.UserRepo.GetByID(userID)
+	if err != nil {
+		return
+	}
+	message := presenceEvent(user, h.ConnectionState(userID), time.Now())
+	if message == nil {
+		return
+	}
+	// Never called from the hub loop, so waiting for it cannot deadlock; a dropped change would never be resent
+	h.Broadcast <- message
+}
+
+// presenceEvent builds the presence event friends see for the user's connection state, nil when the user hides
+// their online status. Invisible users show as offline; offline events carry the last seen time if shared.
+func presenceEvent(user entity.User, connectionState string, now time.Time) *Message {
+	// Presence only goes to friends, so "friends" and "everyone" both allow it
+	privacy := user.EffectivePrivacy()
+	if !entity.PrivacyAllows(privacy.OnlineStatus, true) {
+		return nil
+	}
+
+	status := user.VisiblePresence(connectionState)
+	data := map[string]interface{}{
+		"userId": user.ID,
+		"status": status,
+		"online": status != entity.PresenceOffline,
+	}
+	if status == entity.PresenceOffline {
+		if entity.PrivacyAllows(privacy.LastSeen, true) {
+			if connectionState == entity.PresenceOffline {
+				data["lastSeenAt"] = now
+			} else if user.LastSeenAt != nil {
+				// Invisible: the last real disconnect
+				data["lastSeenAt"] = user.LastSeenAt
+			}
+		}
+	} else if text, expiresAt := user.ActiveCustomStatus(now); text != "" {
+		data["customStatus"] = text
+		if expiresAt != nil {
+			data["customStatusExpiresAt"] = expiresAt
+		}
+	}
+
+	return &Message{
+		Type:      EventPresence,
+		SenderID:  user.ID,
+		Data:      data,
+		Timestamp: now.Format(time.RFC3339),
+	}
+}
+
+func generateNodeID() string {
+	bytes := make([]byte, 8)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
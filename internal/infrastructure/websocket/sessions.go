@@ -0,0 +1,98 @@
+package websocket
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// HasLiveSession reports whether the login session has an open socket on any node.
+// Remote nodes are known from their last heartbeat, so a socket may be reported up to one heartbeat late.
+func (h *Hub) HasLiveSession(sessionID string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, connections := range h.clients {
+		for client := range connections {
+			if client.SessionID == sessionID {
+				return true
+			}
+		}
+	}
+	for _, sessions := range h.remoteSessions {
+		if sessions[sessionID] {
+			return true
+		}
+	}
+	return false
+}
+
+// CloseSessions disconnects every socket of the given sessions, on all nodes
+func (h *Hub) CloseSessions(sessionIDs ...string) {
+	if len(sessionIDs) == 0 {
+		return
+	}
+	h.publish(&Envelope{Kind: envelopeCloseSession, Sessions: sessionIDs})
+}
+
+// closeLocalSessions closes this node's sockets of the given sessions. ReadPump then fails and unregisters the client.
+func (h *Hub) closeLocalSessions(sessionIDs []string) {
+	revoked := make(map[string]bool, len(sessionIDs))
+	for _, sessionID := range sessionIDs {
+		revoked[sessionID] = true
+	}
+
+	var closing []*Client
+	h.mu.RLock()
+	for _, connections := range h.clients {
+		for client := range connections {
+			if revoked[client.SessionID] {
+				closing = append(closing, client)
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	// Writing the close frame may wait on a slow peer, which must not stall the hub loop
+	message := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "session revoked")
+	for _, client := range closing {
+		go func(conn *websocket.Conn) {
+			conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(writeWait))
+			conn.Close()
+		}(client.Conn.Ws)
+	}
+}
+
+// localSessions returns the sessions with at least one socket on this node
+func (h *Hub) localSessions() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	sessions := make([]string, 0)
+	for _, connections := range h.clients {
+		for client := range connections {
+			if client.SessionID != "" && !seen[client.SessionID] {
+				seen[client.SessionID] = true
+				sessions = append(sessions, client.SessionID)
+			}
+		}
+	}
+	return sessions
+}
+
+// applySessionSnapshot replaces what is known about the live sessions of a remote node
+func (h *Hub) applySessionSnapshot(nodeID string, sessionIDs []string) {
+	if nodeID == h.NodeID {
+		return
+	}
+
+	sessions := make(map[string]bool, len(sessionIDs))
+	for _, sessionID := range sessionIDs {
+		sessions[sessionID] = true
+	}
+
+	h.mu.Lock()
+	h.remoteSessions[nodeID] = sessions
+	h.mu.Unlock()
+}
@@ -0,0 +1,85 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UnlockLogin godoc
+// @Summary      Mở khóa đăng nhập
+// @Description  Xóa bộ đếm đăng nhập sai và gỡ khóa tạm thời của một email và/hoặc một IP; hành động được ghi vào security events
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body object true "Unlock Request" example({"email":"user@example.com","ip":"203.0.113.7"})
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/login-locks/unlock [post]
+func (h *UserHandler) UnlockLogin(c *gin.Context) {
+	type Req struct {
+		Email string `json:"email"`
+		IP    string `json:"ip"`
+	}
+	var req Req
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID, _ := c.Get("userID")
+	cleared, err := h.UserUseCase.UnlockLogin(adminID.(string), req.Email, req.IP)
+	if err != nil {
+		if strings.Contains(err.Error(), "required") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Login unlocked", "cleared": cleared})
+}
+
+// GetSecurityEvents godoc
+// @Summary      Lịch sử khóa đăng nhập
+// @Description  Các sự kiện khóa/mở khóa đăng nhập mới nhất, lọc theo email hoặc IP nếu có (limit mặc định 50, tối đa 200)
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        email  query  string  false  "Email"
+// @Param        ip     query  string  false  "IP"
+// @Param        limit  query  int     false  "Số sự kiện tối đa"
+// @Success      200  {array}   entity.SecurityEvent
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/security-events [get]
+func (h *UserHandler) GetSecurityEvents(c *gin.Context) {
+	limit := 0
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		limit = parsed
+	}
+
+	events, err := h.UserUseCase.GetSecurityEvents(c.Query("email"), c.Query("ip"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
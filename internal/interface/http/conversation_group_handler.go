@@ -0,0 +1,154 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain"
+	"github.com/gin-gonic/gin"
+)
+
+// UpdateGroup godoc
+// @Summary      Cập nhật thông tin nhóm
+// @Description  Đổi tên, mô tả (cần quyền rename), ảnh nhóm (cần quyền change_avatar) hoặc bật/tắt duyệt thành viên (cần quyền invite)
+// @Tags         Conversations
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        conversationId  path  string  true  "Conversation ID"
+// @Param        request body object true "Update Group Request" example({"name":"Nhóm mới","avatar":"https://example.com/avatar.png"})
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /conversations/{conversationId} [patch]
+func (h *ConversationHandler) UpdateGroup(c *gin.Context) {
+	type Req struct {
+		Name             *string `json:"name"`
+		Description      *string `json:"description"`
+		Avatar           *string `json:"avatar"`
+		RequiresApproval *bool   `json:"requiresApproval"`
+	}
+	var req Req
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	conversationID := c.Param("conversationId")
+	userID, _ := c.Get("userID")
+
+	result, err := h.ConversationUseCase.UpdateGroupInfo(conversationID, userID.(string), domain.GroupInfoUpdate{
+		Name:             req.Name,
+		Description:      req.Description,
+		Avatar:           req.Avatar,
+		RequiresApproval: req.RequiresApproval,
+	})
+	if err != nil {
+		c.JSON(memberErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	h.broadcastMemberEvent("conversation_updated", conversationID, userID.(string), result)
+
+	c.JSON(http.StatusOK, result)
+}
+
+// UpdatePermissions godoc
+// @Summary      Cập nhật phân quyền nhóm
+// @Description  Trưởng nhóm đặt vai trò thấp nhất cho từng quyền: post, pin, rename, change_avatar, invite, remove_members, delete_others_messages
+// @Tags         Conversations
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        conversationId  path  string  true  "Conversation ID"
+// @Param        request body object true "Permissions Request" example({"permissions":{"post":"member","pin":"admin"}})
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /conversations/{conversationId}/permissions [put]
+func (h *ConversationHandler) UpdatePermissions(c *gin.Context) {
+	type Req struct {
+		Permissions map[string]string `json:"permissions" binding:"required"`
+	}
+	var req Req
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	conversationID := c.Param("conversationId")
+	userID, _ := c.Get("userID")
+
+	result, err := h.ConversationUseCase.UpdatePermissions(conversationID, userID.(string), req.Permissions)
+	if err != nil {
+		c.JSON(memberErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	h.broadcastMemberEvent("permissions_updated", conversationID, userID.(string), result)
+
+	c.JSON(http.StatusOK, result)
+}
+
+// PinMessage godoc
+// @Summary      Ghim message
+// @Description  Ghim một message trong nhóm (cần quyền pin)
+// @Tags         Conversations
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        messageId  path  string  true  "Message ID"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /conversations/messages/{messageId}/pin [post]
+func (h *ConversationHandler) PinMessage(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	result, err := h.ConversationUseCase.PinMessage(c.Param("messageId"), userID.(string))
+	if err != nil {
+		c.JSON(memberErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	h.broadcastMemberEvent("message_pinned", result["conversationId"].(string), userID.(string), result)
+
+	c.JSON(http.StatusOK, result)
+}
+
+// UnpinMessage godoc
+// @Summary      Bỏ ghim message
+// @Description  Bỏ ghim một message trong nhóm (cần quyền pin)
+// @Tags         Conversations
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        messageId  path  string  true  "Message ID"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /conversations/messages/{messageId}/pin [delete]
+func (h *ConversationHandler) UnpinMessage(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	result, err := h.ConversationUseCase.UnpinMessage(c.Param("messageId"), userID.(string))
+	if err != nil {
+		c.JSON(memberErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	h.broadcastMemberEvent("message_unpinned", result["conversationId"].(string), userID.(string), result)
+
+	c.JSON(http.StatusOK, result)
+}
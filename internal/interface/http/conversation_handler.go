@@ -3,8 +3,7 @@ package http
 import (
 	"fmt"
 	"net/http"
-	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -89,6 +88,7 @@ func (h *ConversationHandler) GetConversation(c *gin.Context) {
 // @Success      200  {object}  map[string]interface{}
 // @Failure      400  {object}  map[string]string
 // @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
 // @Router       /conversations/direct [post]
 func (h *ConversationHandler) CreateDirectConversation(c *gin.Context) {
@@ -110,6 +110,10 @@ func (h *ConversationHandler) CreateDirectConversation(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		if strings.Contains(err.Error(), "forbidden") {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -160,13 +164,16 @@ func (h *ConversationHandler) CreateGroupConversation(c *gin.Context) {
 
 // GetMessages godoc
 // @Summary      Lấy danh sách messages trong conversation
-// @Description  Lấy tất cả messages trong một conversation
+// @Description  Lấy messages trong một conversation theo trang (cursor là message ID hoặc timestamp RFC3339)
 // @Tags         Conversations
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        conversationId  path  string  true  "Conversation ID"
-// @Success      200  {array}   map[string]interface{}
+// @Param        conversationId  path   string  true   "Conversation ID"
+// @Param        before          query  string  false  "Lấy messages cũ hơn cursor"
+// @Param        after           query  string  false  "Lấy messages mới hơn cursor"
+// @Param        limit           query  int     false  "Số messages mỗi trang (mặc định 50, tối đa 100)"
+// @Success      200  {object}  usecase.MessagePage
 // @Failure      400  {object}  map[string]string
 // @Failure      401  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
@@ -175,8 +182,22 @@ func (h *ConversationHandler) GetMessages(c *gin.Context) {
 	conversationID := c.Param("conversationId")
 	userID, _ := c.Get("userID")
 
-	messages, err := h.ConversationUseCase.GetMessages(conversationID, userID.(string))
+	limit := 0
+	if limitParam := c.Query("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		limit = parsed
+	}
+
+	messages, err := h.ConversationUseCase.GetMessages(conversationID, userID.(string), c.Query("before"), c.Query("after"), limit)
 	if err != nil {
+		if strings.Contains(err.Error(), "invalid cursor") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		if strings.Contains(err.Error(), "unauthorized") {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			return
@@ -200,13 +221,14 @@ func (h *ConversationHandler) GetMessages(c *gin.Context) {
 // @Success      200  {object}  map[string]interface{}
 // @Failure      400  {object}  map[string]string
 // @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
 // @Router       /conversations/{conversationId}/messages [post]
 func (h *ConversationHandler) SendMessage(c *gin.Context) {
 	type Req struct {
-		Content    string                   `json:"content"`
-		ReplyToID  string                   `json:"replyToId,omitempty"`
-		Type       string                   `json:"type,omitempty"`
+		Content     string                     `json:"content"`
+		ReplyToID   string                     `json:"replyToId,omitempty"`
+		Type        string                     `json:"type,omitempty"`
 		Attachments []entity.MessageAttachment `json:"attachments,omitempty"`
 	}
 	var req Req
@@ -232,6 +254,10 @@ func (h *ConversationHandler) SendMessage(c *gin.Context) {
 
 	result, err := h.ConversationUseCase.SendMessage(conversationID, userID.(string), req.Content, req.ReplyToID, messageType, req.Attachments)
 	if err != nil {
+		if strings.Contains(err.Error(), "forbidden") {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		if strings.Contains(err.Error(), "unauthorized") {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			return
@@ -261,6 +287,138 @@ func (h *ConversationHandler) SendMessage(c *gin.Context) {
 	c.JSON(http.StatusOK, result)
 }
 
+// EditMessage godoc
+// @Summary      Sửa message
+// @Description  Sửa nội dung message của chính mình trong thời gian cho phép, lưu lại lịch sử chỉnh sửa
+// @Tags         Conversations
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        messageId  path  string  true  "Message ID"
+// @Param        request body object true "Edit Message Request" example({"content":"Nội dung đã sửa"})
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /conversations/messages/{messageId} [patch]
+func (h *ConversationHandler) EditMessage(c *gin.Context) {
+	type Req struct {
+		Content string `json:"content"`
+	}
+	var req Req
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	messageID := c.Param("messageId")
+	userID, _ := c.Get("userID")
+
+	result, err := h.ConversationUseCase.EditMessage(messageID, userID.(string), req.Content)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if strings.Contains(err.Error(), "unauthorized") {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if strings.Contains(err.Error(), "expired") || strings.Contains(err.Error(), "deleted") {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if strings.Contains(err.Error(), "required") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Broadcast edit via WebSocket
+	if h.Hub != nil {
+		message := &websocket.Message{
+			Type:      "message_edited",
+			ChatID:    result["conversationId"].(string),
+			SenderID:  userID.(string),
+			Content:   req.Content,
+			Timestamp: time.Now().Format(time.RFC3339),
+			Data:      result,
+		}
+		select {
+		case h.Hub.Broadcast <- message:
+		default:
+		}
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// DeleteMessage godoc
+// @Summary      Xóa message
+// @Description  scope=everyone: thu hồi message với mọi người (chỉ người gửi). scope=me: chỉ ẩn message ở phía mình
+// @Tags         Conversations
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        messageId  path   string  true   "Message ID"
+// @Param        scope      query  string  false  "everyone | me (mặc định me)"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /conversations/messages/{messageId} [delete]
+func (h *ConversationHandler) DeleteMessage(c *gin.Context) {
+	scope := c.DefaultQuery("scope", "me")
+	if scope != "everyone" && scope != "me" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope must be 'everyone' or 'me'"})
+		return
+	}
+
+	messageID := c.Param("messageId")
+	userID, _ := c.Get("userID")
+
+	result, err := h.ConversationUseCase.DeleteMessage(messageID, userID.(string), scope == "everyone")
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if strings.Contains(err.Error(), "unauthorized") {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Broadcast deletion via WebSocket
+	if h.Hub != nil {
+		eventType := "message_hidden"
+		if scope == "everyone" {
+			eventType = "message_deleted"
+		}
+		message := &websocket.Message{
+			Type:      eventType,
+			ChatID:    result["conversationId"].(string),
+			SenderID:  userID.(string),
+			Timestamp: time.Now().Format(time.RFC3339),
+			Data:      result,
+		}
+		select {
+		case h.Hub.Broadcast <- message:
+		default:
+		}
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
 // UploadFile godoc
 // @Summary      Upload file (image, video, file, audio)
 // @Description  Upload file for conversation message with size limits
@@ -277,10 +435,10 @@ func (h *ConversationHandler) SendMessage(c *gin.Context) {
 func (h *ConversationHandler) UploadFile(c *gin.Context) {
 	// File size limits (in bytes)
 	const (
-		MaxImageSize = 10 * 1024 * 1024  // 10MB
-		MaxVideoSize = 50 * 1024 * 1024  // 50MB
-		MaxFileSize  = 20 * 1024 * 1024  // 20MB
-		MaxAudioSize = 10 * 1024 * 1024  // 10MB
+		MaxImageSize = 10 * 1024 * 1024 // 10MB
+		MaxVideoSize = 50 * 1024 * 1024 // 50MB
+		MaxFileSize  = 20 * 1024 * 1024 // 20MB
+		MaxAudioSize = 10 * 1024 * 1024 // 10MB
 	)
 
 	file, err := c.FormFile("file")
@@ -316,27 +474,16 @@ func (h *ConversationHandler) UploadFile(c *gin.Context) {
 		return
 	}
 
-	// Create uploads directory if not exists
-	uploadDir := "uploads"
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create upload directory"})
-		return
-	}
-
-	// Generate unique filename
-	filename := fmt.Sprintf("%d_%s", time.Now().UnixNano(), file.Filename)
-	filepath := filepath.Join(uploadDir, filename)
-
-	// Save file
-	if err := c.SaveUploadedFile(file, filepath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save file"})
+	url, err := saveUpload(c, file)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	// Return file info
 	c.JSON(http.StatusOK, gin.H{
 		"type":      fileType,
-		"url":       "/uploads/" + filename,
+		"url":       url,
 		"file_name": file.Filename,
 		"file_size": file.Size,
 		"mime_type": contentType,
@@ -377,32 +524,36 @@ func (h *ConversationHandler) AddReaction(c *gin.Context) {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			return
 		}
+		if strings.Contains(err.Error(), "deleted") {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	// Get message to find conversationID
-		msg, err := h.MessageRepo.GetMessageByID(messageID)
-		if err == nil {
-			// Broadcast reaction via WebSocket
-			if h.Hub != nil {
-				message := &websocket.Message{
-					Type:      "reaction",
-					ChatID:    msg.GetConversationID(),
-					SenderID:  userID.(string),
-					Timestamp: time.Now().Format(time.RFC3339),
-					Data: map[string]interface{}{
-						"messageId": messageID,
-						"emoji":     req.Emoji,
-						"action":    "add",
-					},
-				}
-				select {
-				case h.Hub.Broadcast <- message:
-				default:
-				}
+	msg, err := h.MessageRepo.GetMessageByID(messageID)
+	if err == nil {
+		// Broadcast reaction via WebSocket
+		if h.Hub != nil {
+			message := &websocket.Message{
+				Type:      "reaction",
+				ChatID:    msg.GetConversationID(),
+				SenderID:  userID.(string),
+				Timestamp: time.Now().Format(time.RFC3339),
+				Data: map[string]interface{}{
+					"messageId": messageID,
+					"emoji":     req.Emoji,
+					"action":    "add",
+				},
+			}
+			select {
+			case h.Hub.Broadcast <- message:
+			default:
 			}
 		}
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "reaction added"})
 }
@@ -446,27 +597,27 @@ func (h *ConversationHandler) RemoveReaction(c *gin.Context) {
 	}
 
 	// Get message to find conversationID
-		msg, err := h.MessageRepo.GetMessageByID(messageID)
-		if err == nil {
-			// Broadcast reaction removal via WebSocket
-			if h.Hub != nil {
-				message := &websocket.Message{
-					Type:      "reaction",
-					ChatID:    msg.GetConversationID(),
-					SenderID:  userID.(string),
-					Timestamp: time.Now().Format(time.RFC3339),
-					Data: map[string]interface{}{
-						"messageId": messageID,
-						"emoji":     req.Emoji,
-						"action":    "remove",
-					},
-				}
-				select {
-				case h.Hub.Broadcast <- message:
-				default:
-				}
+	msg, err := h.MessageRepo.GetMessageByID(messageID)
+	if err == nil {
+		// Broadcast reaction removal via WebSocket
+		if h.Hub != nil {
+			message := &websocket.Message{
+				Type:      "reaction",
+				ChatID:    msg.GetConversationID(),
+				SenderID:  userID.(string),
+				Timestamp: time.Now().Format(time.RFC3339),
+				Data: map[string]interface{}{
+					"messageId": messageID,
+					"emoji":     req.Emoji,
+					"action":    "remove",
+				},
+			}
+			select {
+			case h.Hub.Broadcast <- message:
+			default:
 			}
 		}
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "reaction removed"})
 }
@@ -521,3 +672,68 @@ func (h *ConversationHandler) MarkAsRead(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "marked as read"})
 }
 
+// MarkConversationRead godoc
+// @Summary      Đánh dấu đã đọc conversation
+// @Description  Di chuyển read cursor của user tới messageId (hoặc message mới nhất nếu bỏ trống) và cập nhật số tin chưa đọc
+// @Tags         Conversations
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        conversationId  path  string  true  "Conversation ID"
+// @Param        request body object false "Mark Conversation Read Request" example({"messageId":"507f1f77bcf86cd799439011"})
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /conversations/{conversationId}/read [post]
+func (h *ConversationHandler) MarkConversationRead(c *gin.Context) {
+	type Req struct {
+		MessageID string `json:"messageId"`
+	}
+	var req Req
+
+	// Body is optional
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	conversationID := c.Param("conversationId")
+	userID, _ := c.Get("userID")
+
+	result, err := h.ConversationUseCase.MarkConversationRead(conversationID, userID.(string), req.MessageID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if strings.Contains(err.Error(), "unauthorized") {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Broadcast read cursor via WebSocket
+	if h.Hub != nil {
+		if _, ok := result["lastReadMessageId"]; ok {
+			message := &websocket.Message{
+				Type:      "conversation_read",
+				ChatID:    conversationID,
+				SenderID:  userID.(string),
+				Timestamp: time.Now().Format(time.RFC3339),
+				Data:      result,
+			}
+			select {
+			case h.Hub.Broadcast <- message:
+			default:
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, result)
+}
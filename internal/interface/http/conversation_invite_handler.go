@@ -0,0 +1,150 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateInvite godoc
+// @Summary      Tạo link mời vào nhóm
+// @Description  Tạo invite token cho group conversation với thời hạn, số lần dùng tối đa và tùy chọn cần admin duyệt (cần quyền invite)
+// @Tags         Conversations
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        conversationId  path  string  true  "Conversation ID"
+// @Param        request body object false "Create Invite Request" example({"expiresIn":"24h","maxUses":10,"requiresApproval":false})
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /conversations/{conversationId}/invites [post]
+func (h *ConversationHandler) CreateInvite(c *gin.Context) {
+	type Req struct {
+		ExpiresIn        string `json:"expiresIn" example:"24h"` // Go duration, bỏ trống = không hết hạn
+		MaxUses          int    `json:"maxUses" example:"10"`    // 0 = không giới hạn
+		RequiresApproval bool   `json:"requiresApproval"`
+	}
+	var req Req
+
+	// Body is optional
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var expiresIn time.Duration
+	if req.ExpiresIn != "" {
+		parsed, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid expiresIn"})
+			return
+		}
+		expiresIn = parsed
+	}
+
+	conversationID := c.Param("conversationId")
+	userID, _ := c.Get("userID")
+
+	result, err := h.ConversationUseCase.CreateInvite(conversationID, userID.(string), expiresIn, req.MaxUses, req.RequiresApproval)
+	if err != nil {
+		c.JSON(memberErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetInvites godoc
+// @Summary      Danh sách link mời của nhóm
+// @Description  Liệt kê các invite token và lịch sử sử dụng (cần quyền invite)
+// @Tags         Conversations
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        conversationId  path  string  true  "Conversation ID"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /conversations/{conversationId}/invites [get]
+func (h *ConversationHandler) GetInvites(c *gin.Context) {
+	conversationID := c.Param("conversationId")
+	userID, _ := c.Get("userID")
+
+	result, err := h.ConversationUseCase.GetInvites(conversationID, userID.(string))
+	if err != nil {
+		c.JSON(memberErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// RevokeInvite godoc
+// @Summary      Thu hồi link mời
+// @Description  Vô hiệu hóa một invite token của nhóm (cần quyền invite)
+// @Tags         Conversations
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        conversationId  path  string  true  "Conversation ID"
+// @Param        inviteId        path  string  true  "Invite ID"
+// @Success      200  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /conversations/{conversationId}/invites/{inviteId} [delete]
+func (h *ConversationHandler) RevokeInvite(c *gin.Context) {
+	conversationID := c.Param("conversationId")
+	userID, _ := c.Get("userID")
+
+	if err := h.ConversationUseCase.RevokeInvite(conversationID, c.Param("inviteId"), userID.(string)); err != nil {
+		c.JSON(memberErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "invite revoked"})
+}
+
+// JoinByInvite godoc
+// @Summary      Tham gia nhóm bằng link mời
+// @Description  Dùng invite token để vào nhóm; nếu link cần duyệt, trạng thái trả về là "pending"
+// @Tags         Conversations
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        token  path  string  true  "Invite token"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /conversations/invites/{token}/join [post]
+func (h *ConversationHandler) JoinByInvite(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	result, err := h.ConversationUseCase.JoinByInvite(c.Param("token"), userID.(string))
+	if err != nil {
+		c.JSON(memberErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if result["status"] == entity.InviteRedemptionJoined && result["systemMessage"] != nil {
+		h.broadcastMemberEvent("member_added", result["conversationId"].(string), userID.(string), result)
+	}
+	if joinRequest, ok := result["joinRequest"].(map[string]interface{}); ok {
+		h.broadcastMemberEvent("join_request", result["conversationId"].(string), userID.(string), joinRequest)
+	}
+
+	c.JSON(http.StatusOK, result)
+}
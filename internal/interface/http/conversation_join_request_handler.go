@@ -0,0 +1,148 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestToJoin godoc
+// @Summary      Xin vào nhóm
+// @Description  Gửi yêu cầu tham gia nhóm cần duyệt, kèm lời nhắn tùy chọn cho admin
+// @Tags         Conversations
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        conversationId  path  string  true  "Conversation ID"
+// @Param        request body object false "Join Request" example({"note":"Mình là bạn của Lan"})
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /conversations/{conversationId}/join-requests [post]
+func (h *ConversationHandler) RequestToJoin(c *gin.Context) {
+	type Req struct {
+		Note string `json:"note"`
+	}
+	var req Req
+
+	// Body is optional
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	conversationID := c.Param("conversationId")
+	userID, _ := c.Get("userID")
+
+	result, err := h.ConversationUseCase.RequestToJoin(conversationID, userID.(string), req.Note)
+	if err != nil {
+		c.JSON(memberErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	h.broadcastMemberEvent("join_request", conversationID, userID.(string), result)
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetJoinRequests godoc
+// @Summary      Danh sách yêu cầu vào nhóm
+// @Description  Liệt kê các yêu cầu tham gia đang chờ duyệt, cũ nhất trước (cần quyền invite)
+// @Tags         Conversations
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        conversationId  path  string  true  "Conversation ID"
+// @Success      200  {array}   map[string]interface{}
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /conversations/{conversationId}/join-requests [get]
+func (h *ConversationHandler) GetJoinRequests(c *gin.Context) {
+	conversationID := c.Param("conversationId")
+	userID, _ := c.Get("userID")
+
+	result, err := h.ConversationUseCase.GetJoinRequests(conversationID, userID.(string))
+	if err != nil {
+		c.JSON(memberErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ApproveJoinRequest godoc
+// @Summary      Duyệt yêu cầu vào nhóm
+// @Description  Chấp nhận yêu cầu và thêm user vào nhóm (cần quyền invite)
+// @Tags         Conversations
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        conversationId  path  string  true  "Conversation ID"
+// @Param        requestId       path  string  true  "Join Request ID"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /conversations/{conversationId}/join-requests/{requestId}/approve [post]
+func (h *ConversationHandler) ApproveJoinRequest(c *gin.Context) {
+	conversationID := c.Param("conversationId")
+	userID, _ := c.Get("userID")
+
+	result, err := h.ConversationUseCase.ApproveJoinRequest(conversationID, c.Param("requestId"), userID.(string))
+	if err != nil {
+		c.JSON(joinRequestErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	h.broadcastMemberEvent("join_request_approved", conversationID, userID.(string), result)
+	h.broadcastMemberEvent("member_added", conversationID, userID.(string), result)
+
+	c.JSON(http.StatusOK, result)
+}
+
+// RejectJoinRequest godoc
+// @Summary      Từ chối yêu cầu vào nhóm
+// @Description  Từ chối một yêu cầu tham gia đang chờ duyệt (cần quyền invite)
+// @Tags         Conversations
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        conversationId  path  string  true  "Conversation ID"
+// @Param        requestId       path  string  true  "Join Request ID"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /conversations/{conversationId}/join-requests/{requestId}/reject [post]
+func (h *ConversationHandler) RejectJoinRequest(c *gin.Context) {
+	conversationID := c.Param("conversationId")
+	userID, _ := c.Get("userID")
+
+	result, err := h.ConversationUseCase.RejectJoinRequest(conversationID, c.Param("requestId"), userID.(string))
+	if err != nil {
+		c.JSON(joinRequestErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	h.broadcastMemberEvent("join_request_rejected", conversationID, userID.(string), result)
+
+	c.JSON(http.StatusOK, result)
+}
+
+func joinRequestErrorStatus(err error) int {
+	if err.Error() == "request is not pending" {
+		return http.StatusBadRequest
+	}
+	return memberErrorStatus(err)
+}
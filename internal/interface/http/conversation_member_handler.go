@@ -0,0 +1,260 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/TomTom2k/chat-app/server/internal/infrastructure/websocket"
+	"github.com/gin-gonic/gin"
+)
+
+// AddMembers godoc
+// @Summary      Thêm thành viên vào nhóm
+// @Description  Thêm một hoặc nhiều user vào group conversation (cần quyền invite)
+// @Tags         Conversations
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        conversationId  path  string  true  "Conversation ID"
+// @Param        request body object true "Add Members Request" example({"userIds":["507f1f77bcf86cd799439012"]})
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /conversations/{conversationId}/members [post]
+func (h *ConversationHandler) AddMembers(c *gin.Context) {
+	type Req struct {
+		UserIDs []string `json:"userIds" binding:"required"`
+	}
+	var req Req
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	conversationID := c.Param("conversationId")
+	userID, _ := c.Get("userID")
+
+	result, err := h.ConversationUseCase.AddMembers(conversationID, userID.(string), req.UserIDs)
+	if err != nil {
+		c.JSON(memberErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	h.broadcastMemberEvent("member_added", conversationID, userID.(string), result)
+
+	c.JSON(http.StatusOK, result)
+}
+
+// RemoveMember godoc
+// @Summary      Xóa thành viên khỏi nhóm
+// @Description  Xóa một thành viên có vai trò thấp hơn mình khỏi group conversation (cần quyền remove_members)
+// @Tags         Conversations
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        conversationId  path  string  true  "Conversation ID"
+// @Param        userId          path  string  true  "User ID"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /conversations/{conversationId}/members/{userId} [delete]
+func (h *ConversationHandler) RemoveMember(c *gin.Context) {
+	conversationID := c.Param("conversationId")
+	userID, _ := c.Get("userID")
+
+	result, err := h.ConversationUseCase.RemoveMember(conversationID, userID.(string), c.Param("userId"))
+	if err != nil {
+		c.JSON(memberErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	h.broadcastMemberEvent("member_removed", conversationID, userID.(string), result)
+
+	c.JSON(http.StatusOK, result)
+}
+
+// UpdateMemberRole godoc
+// @Summary      Đổi vai trò thành viên
+// @Description  Đổi vai trò (admin/moderator/member) của một thành viên có vai trò thấp hơn mình
+// @Tags         Conversations
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        conversationId  path  string  true  "Conversation ID"
+// @Param        userId          path  string  true  "User ID"
+// @Param        request body object true "Update Member Role Request" example({"role":"admin"})
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /conversations/{conversationId}/members/{userId} [patch]
+func (h *ConversationHandler) UpdateMemberRole(c *gin.Context) {
+	type Req struct {
+		Role string `json:"role" binding:"required" example:"admin"`
+	}
+	var req Req
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	conversationID := c.Param("conversationId")
+	userID, _ := c.Get("userID")
+
+	result, err := h.ConversationUseCase.UpdateMemberRole(conversationID, userID.(string), c.Param("userId"), req.Role)
+	if err != nil {
+		c.JSON(memberErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	h.broadcastMemberEvent("member_role_changed", conversationID, userID.(string), result)
+
+	c.JSON(http.StatusOK, result)
+}
+
+// LeaveConversation godoc
+// @Summary      Rời nhóm
+// @Description  Thành viên tự rời group conversation; nếu là trưởng nhóm, quyền trưởng nhóm được chuyển cho successorId (hoặc thành viên có vai trò cao nhất, lâu nhất)
+// @Tags         Conversations
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        conversationId  path  string  true  "Conversation ID"
+// @Param        request body object false "Leave Request" example({"successorId":"507f1f77bcf86cd799439012"})
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /conversations/{conversationId}/leave [post]
+func (h *ConversationHandler) LeaveConversation(c *gin.Context) {
+	type Req struct {
+		SuccessorID string `json:"successorId"`
+	}
+	var req Req
+
+	// Body is optional
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	conversationID := c.Param("conversationId")
+	userID, _ := c.Get("userID")
+
+	result, err := h.ConversationUseCase.LeaveConversation(conversationID, userID.(string), req.SuccessorID)
+	if err != nil {
+		c.JSON(memberErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	h.broadcastMemberEvent("member_left", conversationID, userID.(string), result)
+
+	c.JSON(http.StatusOK, result)
+}
+
+// TransferOwnership godoc
+// @Summary      Chuyển quyền trưởng nhóm
+// @Description  Trưởng nhóm chuyển quyền cho một thành viên khác, bản thân trở thành admin
+// @Tags         Conversations
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        conversationId  path  string  true  "Conversation ID"
+// @Param        request body object true "Transfer Ownership Request" example({"userId":"507f1f77bcf86cd799439012"})
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /conversations/{conversationId}/owner [post]
+func (h *ConversationHandler) TransferOwnership(c *gin.Context) {
+	type Req struct {
+		UserID string `json:"userId" binding:"required"`
+	}
+	var req Req
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	conversationID := c.Param("conversationId")
+	userID, _ := c.Get("userID")
+
+	result, err := h.ConversationUseCase.TransferOwnership(conversationID, userID.(string), req.UserID)
+	if err != nil {
+		c.JSON(memberErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	h.broadcastMemberEvent("ownership_transferred", conversationID, userID.(string), result)
+
+	c.JSON(http.StatusOK, result)
+}
+
+// broadcastMemberEvent pushes a membership or settings change, and its system message if any, to the conversation
+func (h *ConversationHandler) broadcastMemberEvent(eventType, conversationID, actorID string, result map[string]interface{}) {
+	if h.Hub == nil {
+		return
+	}
+
+	events := []*websocket.Message{
+		{
+			Type:      eventType,
+			ChatID:    conversationID,
+			SenderID:  actorID,
+			Timestamp: time.Now().Format(time.RFC3339),
+			Data:      result,
+		},
+	}
+	if systemMessage, ok := result["systemMessage"].(map[string]interface{}); ok {
+		events = append(events, &websocket.Message{
+			Type:      "message",
+			ChatID:    conversationID,
+			SenderID:  actorID,
+			Content:   systemMessage["content"].(string),
+			Timestamp: time.Now().Format(time.RFC3339),
+			Data:      systemMessage,
+		})
+	}
+
+	for _, message := range events {
+		select {
+		case h.Hub.Broadcast <- message:
+		default:
+		}
+	}
+}
+
+func memberErrorStatus(err error) int {
+	switch {
+	case strings.Contains(err.Error(), "forbidden"):
+		return http.StatusForbidden
+	case strings.Contains(err.Error(), "not found"):
+		return http.StatusNotFound
+	case strings.Contains(err.Error(), "unauthorized"):
+		return http.StatusUnauthorized
+	case strings.Contains(err.Error(), "invalid"),
+		strings.Contains(err.Error(), "required"),
+		strings.Contains(err.Error(), "already"),
+		strings.Contains(err.Error(), "not a group"),
+		strings.Contains(err.Error(), "use leave"):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
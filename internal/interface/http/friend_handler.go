@@ -2,6 +2,7 @@ package http
 
 import (
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/TomTom2k/chat-app/server/internal/usecase"
@@ -37,7 +38,7 @@ func (h *FriendHandler) GetFriends(c *gin.Context) {
 
 // AddFriend godoc
 // @Summary      Thêm bạn bè
-// @Description  Thêm một user vào danh sách bạn bè
+// @Description  Gửi lời mời kết bạn; nếu user kia đã gửi lời mời trước thì hai người trở thành bạn bè. Tài khoản chưa xác thực email không gửi được lời mời (403)
 // @Tags         Friends
 // @Accept       json
 // @Produce      json
@@ -46,6 +47,7 @@ func (h *FriendHandler) GetFriends(c *gin.Context) {
 // @Success      200  {object}  map[string]string
 // @Failure      400  {object}  map[string]string
 // @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
 // @Failure      409  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
 // @Router       /friends [post]
@@ -68,10 +70,18 @@ func (h *FriendHandler) AddFriend(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		if strings.Contains(err.Error(), "already exists") {
+		if strings.Contains(err.Error(), "cannot add yourself") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if strings.Contains(err.Error(), "already") {
 			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 			return
 		}
+		if strings.Contains(err.Error(), "forbidden") {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -115,7 +125,7 @@ func (h *FriendHandler) DeleteFriend(c *gin.Context) {
 
 // SearchUsers godoc
 // @Summary      Tìm kiếm users
-// @Description  Tìm kiếm users theo tên hoặc email
+// @Description  Tìm kiếm users theo tên hoặc email (không bao gồm users có quan hệ chặn với user hiện tại); email, avatar và trạng thái online chỉ có khi cài đặt quyền riêng tư cho phép, user ẩn email không thể tìm thấy qua email
 // @Tags         Friends
 // @Accept       json
 // @Produce      json
@@ -133,7 +143,9 @@ func (h *FriendHandler) SearchUsers(c *gin.Context) {
 		return
 	}
 
-	users, err := h.FriendUseCase.SearchUsers(query)
+	userID, _ := c.Get("userID")
+
+	users, err := h.FriendUseCase.SearchUsers(query, userID.(string))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -142,6 +154,75 @@ func (h *FriendHandler) SearchUsers(c *gin.Context) {
 	c.JSON(http.StatusOK, users)
 }
 
+// GetUserProfile godoc
+// @Summary      Xem hồ sơ user
+// @Description  Lấy hồ sơ của một user theo cài đặt quyền riêng tư của họ (email, trạng thái online, lần cuối truy cập, avatar), kèm quan hệ bạn bè (none, friends, request_sent, request_received, self); dùng "me" cho chính mình
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "User ID"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /users/{id} [get]
+func (h *FriendHandler) GetUserProfile(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	targetID := c.Param("id")
+	if targetID == "me" {
+		targetID = userID.(string)
+	}
+
+	profile, err := h.FriendUseCase.GetUserProfile(targetID, userID.(string))
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// GetFriendSuggestions godoc
+// @Summary      Gợi ý kết bạn
+// @Description  Gợi ý những user chưa là bạn, xếp theo số bạn chung rồi số nhóm chung (không gồm user bị chặn hoặc đang có lời mời)
+// @Tags         Friends
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        limit  query  int  false  "Số gợi ý (mặc định 20, tối đa 50)"
+// @Success      200  {array}   map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /friends/suggestions [get]
+func (h *FriendHandler) GetFriendSuggestions(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	limit := 0
+	if limitParam := c.Query("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		limit = parsed
+	}
+
+	suggestions, err := h.FriendUseCase.GetFriendSuggestions(userID.(string), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, suggestions)
+}
+
 // GetPendingRequests godoc
 // @Summary      Lấy danh sách lời mời kết bạn đang chờ
 // @Description  Lấy danh sách lời mời kết bạn mà user nhận được
@@ -195,19 +276,19 @@ func (h *FriendHandler) GetSentRequests(c *gin.Context) {
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        requestId  path  string  true  "Sender User ID"
+// @Param        requestId  path  string  true  "Request ID (vẫn chấp nhận Sender User ID)"
 // @Success      200  {object}  map[string]string
 // @Failure      400  {object}  map[string]string
 // @Failure      401  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
 // @Router       /friends/requests/{requestId}/accept [post]
 func (h *FriendHandler) AcceptFriendRequest(c *gin.Context) {
-	senderUserID := c.Param("requestId") // requestId is actually senderUserID
+	requestID := c.Param("requestId")
 	userID, _ := c.Get("userID")
 
-	err := h.FriendUseCase.AcceptFriendRequest(senderUserID, userID.(string))
+	err := h.FriendUseCase.AcceptFriendRequest(requestID, userID.(string))
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		if strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "not pending") {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
@@ -229,19 +310,19 @@ func (h *FriendHandler) AcceptFriendRequest(c *gin.Context) {
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        requestId  path  string  true  "Sender User ID"
+// @Param        requestId  path  string  true  "Request ID (vẫn chấp nhận Sender User ID)"
 // @Success      200  {object}  map[string]string
 // @Failure      400  {object}  map[string]string
 // @Failure      401  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
 // @Router       /friends/requests/{requestId}/reject [post]
 func (h *FriendHandler) RejectFriendRequest(c *gin.Context) {
-	senderUserID := c.Param("requestId") // requestId is actually senderUserID
+	requestID := c.Param("requestId")
 	userID, _ := c.Get("userID")
 
-	err := h.FriendUseCase.RejectFriendRequest(senderUserID, userID.(string))
+	err := h.FriendUseCase.RejectFriendRequest(requestID, userID.(string))
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		if strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "not pending") {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
@@ -256,3 +337,98 @@ func (h *FriendHandler) RejectFriendRequest(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Friend request rejected successfully"})
 }
 
+// BlockUser godoc
+// @Summary      Chặn user
+// @Description  Chặn một user: hủy kết bạn và lời mời, không thể nhắn tin trực tiếp, không thấy nhau khi tìm kiếm
+// @Tags         Friends
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body object true "Block User Request" example({"userId":"507f1f77bcf86cd799439012"})
+// @Success      200  {object}  map[string]string
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /friends/blocks [post]
+func (h *FriendHandler) BlockUser(c *gin.Context) {
+	type Req struct {
+		UserID string `json:"userId" binding:"required" example:"507f1f77bcf86cd799439012"`
+	}
+	var req Req
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+
+	err := h.FriendUseCase.BlockUser(userID.(string), req.UserID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "yourself") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if strings.Contains(err.Error(), "already blocked") {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User blocked successfully"})
+}
+
+// UnblockUser godoc
+// @Summary      Bỏ chặn user
+// @Description  Bỏ chặn một user đã chặn trước đó
+// @Tags         Friends
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        userId  path  string  true  "User ID"
+// @Success      200  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /friends/blocks/{userId} [delete]
+func (h *FriendHandler) UnblockUser(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	err := h.FriendUseCase.UnblockUser(userID.(string), c.Param("userId"))
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unblocked successfully"})
+}
+
+// GetBlockedUsers godoc
+// @Summary      Danh sách users đã chặn
+// @Description  Lấy danh sách users mà user hiện tại đã chặn
+// @Tags         Friends
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   map[string]interface{}
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /friends/blocks [get]
+func (h *FriendHandler) GetBlockedUsers(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	users, err := h.FriendUseCase.GetBlockedUsers(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
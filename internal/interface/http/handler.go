@@ -1,10 +1,14 @@
 package http
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+	"github.com/TomTom2k/chat-app/server/internal/infrastructure/websocket"
 	"github.com/TomTom2k/chat-app/server/internal/usecase"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
@@ -12,15 +16,16 @@ import (
 
 type UserHandler struct {
 	UserUseCase usecase.UserUseCase
+	Hub         *websocket.Hub
 }
 
 // Register godoc
 // @Summary      Đăng ký user mới
-// @Description  Tạo tài khoản user mới
+// @Description  Tạo tài khoản user mới và mở phiên đăng nhập đầu tiên (deviceName không bắt buộc)
 // @Tags         Authentication
 // @Accept       json
 // @Produce      json
-// @Param        request body object true "Register Request" example({"name":"Nguyễn Văn A","email":"user@example.com","password":"password123"})
+// @Param        request body object true "Register Request" example({"name":"Nguyễn Văn A","email":"user@example.com","password":"password123","deviceName":"iPhone của A"})
 // @Success      200  {object}  usecase.RegisterResult
 // @Failure      400  {object}  map[string]string
 // @Failure      409  {object}  map[string]string
@@ -28,9 +33,10 @@ type UserHandler struct {
 // @Router       /auth/register [post]
 func (h *UserHandler) Register(c *gin.Context) {
 	type Req struct {
-		Name     string `json:"name" validate:"required,min=1,max=100" example:"Nguyễn Văn A"`
-		Email    string `json:"email" validate:"required,email" example:"user@example.com"`
-		Password string `json:"password" validate:"required,min=6" example:"password123"`
+		Name       string `json:"name" validate:"required,min=1,max=100" example:"Nguyễn Văn A"`
+		Email      string `json:"email" validate:"required,email" example:"user@example.com"`
+		Password   string `json:"password" validate:"required,min=6" example:"password123"`
+		DeviceName string `json:"deviceName" validate:"max=100" example:"iPhone của A"`
 	}
 	var req Req
 
@@ -54,7 +60,7 @@ func (h *UserHandler) Register(c *gin.Context) {
 		Name:     req.Name,
 		Email:    req.Email,
 		Password: req.Password,
-	})
+	}, clientInfo(c, req.DeviceName))
 	if err != nil {
 		// Check if it's a duplicate user error
 		if strings.Contains(err.Error(), "already exists") {
@@ -70,20 +76,24 @@ func (h *UserHandler) Register(c *gin.Context) {
 
 // Login godoc
 // @Summary      Đăng nhập
-// @Description  Đăng nhập với email và password
+// @Description  Đăng nhập với email và password, trả về access token ngắn hạn và refresh token của phiên (deviceName không bắt buộc).
+// @Description  Nếu tài khoản bật 2FA, chỉ trả về twoFactor.challengeToken để gửi kèm mã TOTP tới /auth/2fa/verify.
+// @Description  Đăng nhập sai nhiều lần theo email hoặc IP sẽ bị trì hoãn rồi khóa tạm thời (429, header Retry-After)
 // @Tags         Authentication
 // @Accept       json
 // @Produce      json
-// @Param        request body object true "Login Request" example({"email":"user@example.com","password":"password123"})
-// @Success      200  {object}  usecase.RegisterResult
+// @Param        request body object true "Login Request" example({"email":"user@example.com","password":"password123","deviceName":"iPhone của A"})
+// @Success      200  {object}  usecase.LoginResult
 // @Failure      400  {object}  map[string]string
 // @Failure      401  {object}  map[string]string
+// @Failure      429  {object}  map[string]interface{}
 // @Failure      500  {object}  map[string]string
 // @Router       /auth/login [post]
 func (h *UserHandler) Login(c *gin.Context) {
 	type Req struct {
-		Email    string `json:"email" validate:"required,email" example:"user@example.com"`
-		Password string `json:"password" validate:"required" example:"password123"`
+		Email      string `json:"email" validate:"required,email" example:"user@example.com"`
+		Password   string `json:"password" validate:"required" example:"password123"`
+		DeviceName string `json:"deviceName" validate:"max=100" example:"iPhone của A"`
 	}
 	var req Req
 
@@ -103,8 +113,11 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 
-	result, err := h.UserUseCase.Login(req.Email, req.Password)
+	result, err := h.UserUseCase.Login(req.Email, req.Password, clientInfo(c, req.DeviceName))
 	if err != nil {
+		if respondThrottled(c, err) {
+			return
+		}
 		if strings.Contains(err.Error(), "invalid credentials") {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			return
@@ -116,6 +129,214 @@ func (h *UserHandler) Login(c *gin.Context) {
 	c.JSON(http.StatusOK, result)
 }
 
+// VerifyTwoFactorLogin godoc
+// @Summary      Hoàn tất đăng nhập 2FA
+// @Description  Gửi challengeToken nhận được khi đăng nhập cùng mã TOTP 6 số hoặc một mã khôi phục; nhập sai quá 5 lần thì phải đăng nhập lại
+// @Tags         Two-Factor Authentication
+// @Accept       json
+// @Produce      json
+// @Param        request body object true "Verify 2FA Request" example({"challengeToken":"<challenge token>","code":"123456","deviceName":"iPhone của A"})
+// @Success      200  {object}  usecase.RegisterResult
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      429  {object}  map[string]interface{}
+// @Failure      500  {object}  map[string]string
+// @Router       /auth/2fa/verify [post]
+func (h *UserHandler) VerifyTwoFactorLogin(c *gin.Context) {
+	type Req struct {
+		ChallengeToken string `json:"challengeToken" validate:"required"`
+		Code           string `json:"code" validate:"required" example:"123456"`
+		DeviceName     string `json:"deviceName" validate:"max=100" example:"iPhone của A"`
+	}
+	var req Req
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Validate request
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		var errors []string
+		for _, err := range err.(validator.ValidationErrors) {
+			errors = append(errors, err.Error())
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": strings.Join(errors, ", ")})
+		return
+	}
+
+	result, err := h.UserUseCase.VerifyTwoFactorLogin(req.ChallengeToken, req.Code, clientInfo(c, req.DeviceName))
+	if err != nil {
+		if respondThrottled(c, err) {
+			return
+		}
+		if strings.Contains(err.Error(), "invalid") {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// SetupTwoFactor godoc
+// @Summary      Bắt đầu bật 2FA
+// @Description  Tạo secret TOTP mới và otpauth URI (hiển thị dạng QR); 2FA chỉ được bật sau khi xác nhận bằng /auth/2fa/confirm
+// @Tags         Two-Factor Authentication
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  usecase.TwoFactorSetup
+// @Failure      401  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /auth/2fa/setup [post]
+func (h *UserHandler) SetupTwoFactor(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	setup, err := h.UserUseCase.SetupTwoFactor(userID.(string))
+	if err != nil {
+		if strings.Contains(err.Error(), "already enabled") {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, setup)
+}
+
+// ConfirmTwoFactor godoc
+// @Summary      Xác nhận bật 2FA
+// @Description  Nhập mã TOTP đầu tiên từ ứng dụng authenticator để bật 2FA; trả về các mã khôi phục, chỉ hiển thị một lần
+// @Tags         Two-Factor Authentication
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body object true "Confirm 2FA Request" example({"code":"123456"})
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /auth/2fa/confirm [post]
+func (h *UserHandler) ConfirmTwoFactor(c *gin.Context) {
+	type Req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	var req Req
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	codes, err := h.UserUseCase.ConfirmTwoFactor(userID.(string), req.Code)
+	if err != nil {
+		if strings.Contains(err.Error(), "already enabled") {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if strings.Contains(err.Error(), "invalid code") || strings.Contains(err.Error(), "not started") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication enabled", "recoveryCodes": codes})
+}
+
+// DisableTwoFactor godoc
+// @Summary      Tắt 2FA
+// @Description  Tắt 2FA, yêu cầu mật khẩu và một mã TOTP hoặc mã khôi phục
+// @Tags         Two-Factor Authentication
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body object true "Disable 2FA Request" example({"password":"password123","code":"123456"})
+// @Success      200  {object}  map[string]string
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      429  {object}  map[string]interface{}
+// @Failure      500  {object}  map[string]string
+// @Router       /auth/2fa/disable [post]
+func (h *UserHandler) DisableTwoFactor(c *gin.Context) {
+	type Req struct {
+		Password string `json:"password" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+	}
+	var req Req
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	if err := h.UserUseCase.DisableTwoFactor(userID.(string), req.Password, req.Code, clientInfo(c, "")); err != nil {
+		if respondThrottled(c, err) {
+			return
+		}
+		if strings.Contains(err.Error(), "invalid") || strings.Contains(err.Error(), "not enabled") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary      Tạo lại mã khôi phục 2FA
+// @Description  Thay toàn bộ mã khôi phục bằng bộ mới, các mã cũ không dùng được nữa; yêu cầu một mã TOTP hoặc mã khôi phục
+// @Tags         Two-Factor Authentication
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body object true "Regenerate Recovery Codes Request" example({"code":"123456"})
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      429  {object}  map[string]interface{}
+// @Failure      500  {object}  map[string]string
+// @Router       /auth/2fa/recovery-codes [post]
+func (h *UserHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	type Req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	var req Req
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	codes, err := h.UserUseCase.RegenerateRecoveryCodes(userID.(string), req.Code, clientInfo(c, ""))
+	if err != nil {
+		if respondThrottled(c, err) {
+			return
+		}
+		if strings.Contains(err.Error(), "invalid code") || strings.Contains(err.Error(), "not enabled") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
+
 // GetMe godoc
 // @Summary      Lấy thông tin user hiện tại
 // @Description  Lấy thông tin của user đang đăng nhập
@@ -142,3 +363,316 @@ func (h *UserHandler) GetMe(c *gin.Context) {
 
 	c.JSON(http.StatusOK, user)
 }
+
+// Refresh godoc
+// @Summary      Làm mới access token
+// @Description  Đổi refresh token lấy cặp token mới; refresh token cũ không dùng được nữa, dùng lại sẽ thu hồi cả phiên
+// @Tags         Authentication
+// @Accept       json
+// @Produce      json
+// @Param        request body object true "Refresh Request" example({"refreshToken":"<refresh token>"})
+// @Success      200  {object}  usecase.TokenPair
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /auth/refresh [post]
+func (h *UserHandler) Refresh(c *gin.Context) {
+	type Req struct {
+		RefreshToken string `json:"refreshToken" binding:"required"`
+	}
+	var req Req
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := h.UserUseCase.Refresh(req.RefreshToken, clientInfo(c, ""))
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid refresh token") {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout godoc
+// @Summary      Đăng xuất
+// @Description  Thu hồi phiên hiện tại: access token và refresh token của phiên không còn hiệu lực
+// @Tags         Authentication
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /auth/logout [post]
+func (h *UserHandler) Logout(c *gin.Context) {
+	sessionID, _ := c.Get("sessionID")
+
+	if err := h.UserUseCase.Logout(sessionID.(string)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// LogoutAll godoc
+// @Summary      Đăng xuất khỏi mọi thiết bị
+// @Description  Thu hồi tất cả các phiên của user, kể cả phiên hiện tại
+// @Tags         Authentication
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /auth/logout-all [post]
+func (h *UserHandler) LogoutAll(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	sessionIDs, err := h.UserUseCase.LogoutAll(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out from all devices", "revoked": len(sessionIDs)})
+}
+
+// GetSessions godoc
+// @Summary      Danh sách phiên đăng nhập
+// @Description  Các thiết bị đang đăng nhập: tên thiết bị, IP, thời điểm tạo, hoạt động gần nhất, có đang kết nối WebSocket hay không
+// @Tags         Authentication
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   map[string]interface{}
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /auth/sessions [get]
+func (h *UserHandler) GetSessions(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	sessionID, _ := c.Get("sessionID")
+
+	sessions, err := h.UserUseCase.GetSessions(userID.(string), sessionID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession godoc
+// @Summary      Đăng xuất một thiết bị
+// @Description  Thu hồi một phiên đăng nhập và ngắt các kết nối WebSocket của phiên đó
+// @Tags         Authentication
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  string  true  "Session ID"
+// @Success      200  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /auth/sessions/{id} [delete]
+func (h *UserHandler) RevokeSession(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	if err := h.UserUseCase.RevokeSession(userID.(string), c.Param("id")); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// VerifyEmail godoc
+// @Summary      Xác thực email
+// @Description  Xác thực địa chỉ email bằng token trong link đã gửi qua email; token chỉ dùng được một lần
+// @Tags         Authentication
+// @Accept       json
+// @Produce      json
+// @Param        request body object true "Verify Email Request" example({"token":"<token trong email>"})
+// @Success      200  {object}  map[string]string
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /auth/verify-email [post]
+func (h *UserHandler) VerifyEmail(c *gin.Context) {
+	type Req struct {
+		Token string `json:"token" binding:"required"`
+	}
+	var req Req
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.UserUseCase.VerifyEmail(req.Token); err != nil {
+		if strings.Contains(err.Error(), "invalid or expired token") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// ResendVerificationEmail godoc
+// @Summary      Gửi lại email xác thực
+// @Description  Gửi link xác thực mới tới email của user hiện tại; các link gửi trước đó hết hiệu lực
+// @Tags         Authentication
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /auth/verify-email/resend [post]
+func (h *UserHandler) ResendVerificationEmail(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	if err := h.UserUseCase.SendVerificationEmail(userID.(string)); err != nil {
+		if strings.Contains(err.Error(), "already verified") {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+// ForgotPassword godoc
+// @Summary      Quên mật khẩu
+// @Description  Gửi link đặt lại mật khẩu nếu email thuộc một tài khoản; luôn trả về 200 để không lộ email nào đã đăng ký
+// @Tags         Authentication
+// @Accept       json
+// @Produce      json
+// @Param        request body object true "Forgot Password Request" example({"email":"user@example.com"})
+// @Success      200  {object}  map[string]string
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /auth/forgot-password [post]
+func (h *UserHandler) ForgotPassword(c *gin.Context) {
+	type Req struct {
+		Email string `json:"email" validate:"required,email" example:"user@example.com"`
+	}
+	var req Req
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Validate request
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		var errors []string
+		for _, err := range err.(validator.ValidationErrors) {
+			errors = append(errors, err.Error())
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": strings.Join(errors, ", ")})
+		return
+	}
+
+	if err := h.UserUseCase.ForgotPassword(req.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the email is registered, a password reset link has been sent"})
+}
+
+// ResetPassword godoc
+// @Summary      Đặt lại mật khẩu
+// @Description  Đặt mật khẩu mới bằng token trong email quên mật khẩu; token chỉ dùng được một lần và mọi phiên đăng nhập bị thu hồi
+// @Tags         Authentication
+// @Accept       json
+// @Produce      json
+// @Param        request body object true "Reset Password Request" example({"token":"<token trong email>","password":"newpassword123"})
+// @Success      200  {object}  map[string]string
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /auth/reset-password [post]
+func (h *UserHandler) ResetPassword(c *gin.Context) {
+	type Req struct {
+		Token    string `json:"token" validate:"required"`
+		Password string `json:"password" validate:"required,min=6" example:"newpassword123"`
+	}
+	var req Req
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Validate request
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		var errors []string
+		for _, err := range err.(validator.ValidationErrors) {
+			errors = append(errors, err.Error())
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": strings.Join(errors, ", ")})
+		return
+	}
+
+	if err := h.UserUseCase.ResetPassword(req.Token, req.Password); err != nil {
+		if strings.Contains(err.Error(), "invalid or expired token") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
+
+// JWKS godoc
+// @Summary      Public keys xác thực access token
+// @Description  JSON Web Key Set gồm các public key (RS256/EdDSA) đang được chấp nhận; khóa HS256 không bao giờ được công bố
+// @Tags         Authentication
+// @Produce      json
+// @Success      200  {object}  jwt.JWKSet
+// @Router       /.well-known/jwks.json [get]
+func (h *UserHandler) JWKS(c *gin.Context) {
+	c.JSON(http.StatusOK, h.UserUseCase.JWKS())
+}
+
+// respondThrottled answers 429 with Retry-After when err is a login throttle
+func respondThrottled(c *gin.Context, err error) bool {
+	var throttled *usecase.LoginThrottledError
+	if !errors.As(err, &throttled) {
+		return false
+	}
+	retryAfter := int(math.Ceil(throttled.RetryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error(), "retryAfter": retryAfter})
+	return true
+}
+
+// clientInfo describes the device making the request
+func clientInfo(c *gin.Context, deviceName string) usecase.ClientInfo {
+	return usecase.ClientInfo{
+		DeviceName: deviceName,
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	}
+}
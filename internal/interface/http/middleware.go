@@ -4,13 +4,12 @@ import (
 	"net/http"
 	"strings"
 
-	"github.com/TomTom2k/chat-app/server/internal/config"
-	"github.com/TomTom2k/chat-app/server/pkg/jwt"
+	"github.com/TomTom2k/chat-app/server/internal/usecase"
 	"github.com/gin-gonic/gin"
 )
 
-// AuthMiddleware validates JWT token and sets user ID in context
-func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
+// AuthMiddleware validates JWT token, checks its session is still active and sets user ID in context
+func AuthMiddleware(userUseCase *usecase.UserUseCase) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -28,7 +27,7 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 		}
 
 		token := parts[1]
-		claims, err := jwt.ValidateToken(token, cfg.JWTSecret)
+		claims, err := userUseCase.Authenticate(token)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			c.Abort()
@@ -38,6 +37,20 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 		// Set user ID in context
 		c.Set("userID", claims.UserID)
 		c.Set("userEmail", claims.Email)
+		c.Set("sessionID", claims.SessionID)
+		c.Next()
+	}
+}
+
+// AdminMiddleware only lets users with the admin role through; it must run after AuthMiddleware
+func AdminMiddleware(userUseCase *usecase.UserUseCase) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+		if id, ok := userID.(string); !ok || !userUseCase.IsAdmin(id) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
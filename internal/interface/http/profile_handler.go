@@ -0,0 +1,321 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain"
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+	"github.com/TomTom2k/chat-app/server/internal/infrastructure/websocket"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// MaxAvatarSize limits avatar uploads
+const MaxAvatarSize = 5 * 1024 * 1024 // 5MB
+
+// UpdateProfile godoc
+// @Summary      Cập nhật hồ sơ
+// @Description  Đổi tên hiển thị, username (duy nhất, 3-30 ký tự a-z 0-9 _ .) hoặc bio; avatar chỉ được xóa ("") ở đây, ảnh mới phải upload qua POST /users/me/avatar; bạn bè nhận sự kiện profile_updated qua WebSocket
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body object true "Update Profile Request" example({"name":"Nguyễn Văn A","username":"nguyenvana","bio":"Xin chào!"})
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /users/me [patch]
+func (h *UserHandler) UpdateProfile(c *gin.Context) {
+	type Req struct {
+		Name     *string `json:"name"`
+		Username *string `json:"username"`
+		Avatar   *string `json:"avatar"`
+		Bio      *string `json:"bio"`
+	}
+	var req Req
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// New avatars go through the upload pipeline, which checks type and size; here it can only be removed
+	if req.Avatar != nil && *req.Avatar != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid avatar: upload it with POST /users/me/avatar, or send an empty string to remove it"})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	profile, err := h.UserUseCase.UpdateProfile(userID.(string), domain.ProfileUpdate{
+		Name:     req.Name,
+		Username: req.Username,
+		Avatar:   req.Avatar,
+		Bio:      req.Bio,
+	})
+	if err != nil {
+		c.JSON(profileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	h.broadcastProfileUpdate(userID.(string))
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// UploadAvatar godoc
+// @Summary      Upload avatar
+// @Description  Upload ảnh đại diện (image/*, tối đa 5MB) và đặt làm avatar
+// @Tags         Users
+// @Accept       multipart/form-data
+// @Produce      json
+// @Security     BearerAuth
+// @Param        file formData file true "Ảnh đại diện"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /users/me/avatar [post]
+func (h *UserHandler) UploadAvatar(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+
+	if !strings.HasPrefix(file.Header.Get("Content-Type"), "image/") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "avatar must be an image"})
+		return
+	}
+	if file.Size > MaxAvatarSize {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("file size exceeds limit: %d bytes (max: %d bytes)", file.Size, MaxAvatarSize),
+		})
+		return
+	}
+
+	url, err := saveUpload(c, file)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	profile, err := h.UserUseCase.UpdateProfile(userID.(string), domain.ProfileUpdate{Avatar: &url})
+	if err != nil {
+		c.JSON(profileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	h.broadcastProfileUpdate(userID.(string))
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// ChangePassword godoc
+// @Summary      Đổi mật khẩu
+// @Description  Đổi mật khẩu, yêu cầu mật khẩu hiện tại; các phiên đăng nhập khác bị thu hồi, phiên hiện tại được giữ
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body object true "Change Password Request" example({"currentPassword":"password123","newPassword":"newpassword123"})
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /users/me/password [post]
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	type Req struct {
+		CurrentPassword string `json:"currentPassword" validate:"required"`
+		NewPassword     string `json:"newPassword" validate:"required,min=6" example:"newpassword123"`
+	}
+	var req Req
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Validate request
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		var errors []string
+		for _, err := range err.(validator.ValidationErrors) {
+			errors = append(errors, err.Error())
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": strings.Join(errors, ", ")})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	sessionID, _ := c.Get("sessionID")
+	revoked, err := h.UserUseCase.ChangePassword(userID.(string), sessionID.(string), req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		c.JSON(profileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully", "revokedSessions": revoked})
+}
+
+// GetPrivacySettings godoc
+// @Summary      Lấy cài đặt quyền riêng tư
+// @Description  Ai được xem email, trạng thái online, lần cuối truy cập, avatar (everyone, friends, nobody) và ai được gửi lời mời kết bạn (everyone, friends_of_friends, nobody)
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  entity.PrivacySettings
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /users/me/privacy [get]
+func (h *UserHandler) GetPrivacySettings(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	settings, err := h.UserUseCase.GetPrivacySettings(userID.(string))
+	if err != nil {
+		c.JSON(profileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// UpdatePrivacySettings godoc
+// @Summary      Cập nhật cài đặt quyền riêng tư
+// @Description  Chỉ các trường được gửi mới thay đổi; áp dụng cho hồ sơ, tìm kiếm, danh sách bạn bè và sự kiện online/offline qua WebSocket
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body object true "Privacy Settings Request" example({"email":"nobody","onlineStatus":"friends","lastSeen":"nobody","avatar":"everyone","friendRequests":"friends_of_friends"})
+// @Success      200  {object}  entity.PrivacySettings
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /users/me/privacy [patch]
+func (h *UserHandler) UpdatePrivacySettings(c *gin.Context) {
+	var req entity.PrivacySettings
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	settings, err := h.UserUseCase.UpdatePrivacySettings(userID.(string), req)
+	if err != nil {
+		c.JSON(profileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// Friends may have to see a different avatar now
+	h.broadcastProfileUpdate(userID.(string))
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// GetPresence godoc
+// @Summary      Lấy trạng thái hiện diện
+// @Description  Trạng thái đã chọn (online, dnd, invisible), trạng thái bạn bè đang thấy (online, away, dnd, offline), trạng thái tùy chỉnh và lần cuối truy cập
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /users/me/presence [get]
+func (h *UserHandler) GetPresence(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	presence, err := h.UserUseCase.GetPresence(userID.(string))
+	if err != nil {
+		c.JSON(profileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, presence)
+}
+
+// UpdatePresence godoc
+// @Summary      Cập nhật trạng thái hiện diện
+// @Description  Chọn online (tự chuyển away khi mọi thiết bị không hoạt động), dnd hoặc invisible (bạn bè thấy offline) và/hoặc đặt trạng thái tùy chỉnh (tối đa 100 ký tự, có thể kèm thời điểm hết hạn; chuỗi rỗng để xóa); bạn bè nhận sự kiện presence qua WebSocket
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body object true "Update Presence Request" example({"status":"dnd","customStatus":"Đang họp","customStatusExpiresAt":"2026-01-01T10:00:00Z"})
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /users/me/presence [patch]
+func (h *UserHandler) UpdatePresence(c *gin.Context) {
+	type Req struct {
+		Status                *string    `json:"status"`
+		CustomStatus          *string    `json:"customStatus"`
+		CustomStatusExpiresAt *time.Time `json:"customStatusExpiresAt"`
+	}
+	var req Req
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	presence, err := h.UserUseCase.UpdatePresence(userID.(string), domain.PresenceUpdate{
+		Status:                req.Status,
+		CustomStatus:          req.CustomStatus,
+		CustomStatusExpiresAt: req.CustomStatusExpiresAt,
+	})
+	if err != nil {
+		c.JSON(profileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if h.Hub != nil {
+		h.Hub.PublishPresence(userID.(string))
+	}
+
+	c.JSON(http.StatusOK, presence)
+}
+
+// broadcastProfileUpdate tells the user's friends and other devices about the new profile
+func (h *UserHandler) broadcastProfileUpdate(userID string) {
+	if h.Hub == nil {
+		return
+	}
+	profile, err := h.UserUseCase.SharedProfile(userID)
+	if err != nil {
+		return
+	}
+
+	message := &websocket.Message{
+		Type:      "profile_updated",
+		SenderID:  userID,
+		Timestamp: time.Now().Format(time.RFC3339),
+		Data:      profile,
+	}
+	// Friends keep showing the old profile until the next change if the event is dropped
+	h.Hub.Broadcast <- message
+}
+
+func profileErrorStatus(err error) int {
+	switch {
+	case strings.Contains(err.Error(), "already taken"):
+		return http.StatusConflict
+	case strings.Contains(err.Error(), "not found"):
+		return http.StatusNotFound
+	case strings.Contains(err.Error(), "invalid"),
+		strings.Contains(err.Error(), "required"):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
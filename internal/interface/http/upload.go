@@ -0,0 +1,32 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// uploadDir is served at /uploads
+const uploadDir = "uploads"
+
+// saveUpload stores an uploaded file under a unique name and returns its public URL
+func saveUpload(c *gin.Context, file *multipart.FileHeader) (string, error) {
+	// Create uploads directory if not exists
+	if err := os.MkdirAll(uploadDir, 0755); err != nil {
+		return "", errors.New("failed to create upload directory")
+	}
+
+	// Generate unique filename
+	filename := fmt.Sprintf("%d_%s", time.Now().UnixNano(), filepath.Base(file.Filename))
+
+	if err := c.SaveUploadedFile(file, filepath.Join(uploadDir, filename)); err != nil {
+		return "", errors.New("failed to save file")
+	}
+
+	return "/" + uploadDir + "/" + filename, nil
+}
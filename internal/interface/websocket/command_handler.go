@@ -0,0 +1,159 @@
+package websocket
+
+import (
+	"errors"
+	"time"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+	ws "github.com/TomTom2k/chat-app/server/internal/infrastructure/websocket"
+	"github.com/TomTom2k/chat-app/server/internal/usecase"
+)
+
+// CommandHandler executes client commands received over the socket through the same
+// use cases as the REST handlers, and broadcasts the same events they do.
+type CommandHandler struct {
+	ConversationUseCase usecase.ConversationUseCase
+	Hub                 *ws.Hub
+}
+
+func (h *CommandHandler) Execute(userID, clientID string, event *ws.InboundEvent) (interface{}, error) {
+	switch event.Type {
+	case ws.EventSendMessage:
+		return h.sendMessage(userID, clientID, event)
+	case ws.EventReact:
+		return h.react(userID, clientID, event, true)
+	case ws.EventUnreact:
+		return h.react(userID, clientID, event, false)
+	case ws.EventMarkRead:
+		return h.markRead(userID, clientID, event)
+	default:
+		return nil, errors.New("invalid command")
+	}
+}
+
+func (h *CommandHandler) sendMessage(userID, clientID string, event *ws.InboundEvent) (interface{}, error) {
+	type Req struct {
+		Content     string                     `json:"content"`
+		ReplyToID   string                     `json:"replyToId,omitempty"`
+		Type        string                     `json:"type,omitempty"`
+		Attachments []entity.MessageAttachment `json:"attachments,omitempty"`
+	}
+	var req Req
+	if err := event.Decode(&req); err != nil {
+		return nil, errors.New("invalid data")
+	}
+
+	conversationID := event.ConversationID()
+	if conversationID == "" {
+		return nil, errors.New("chatId required")
+	}
+
+	messageType := entity.MessageTypeText
+	if req.Type != "" {
+		messageType = entity.MessageType(req.Type)
+	}
+
+	result, err := h.ConversationUseCase.SendMessage(conversationID, userID, req.Content, req.ReplyToID, messageType, req.Attachments)
+	if err != nil {
+		return nil, err
+	}
+
+	h.broadcast(&ws.Message{
+		Type:      "message",
+		ChatID:    conversationID,
+		SenderID:  userID,
+		Content:   req.Content,
+		Timestamp: time.Now().Format(time.RFC3339),
+		Data:      result,
+		Origin:    clientID,
+	})
+
+	return result, nil
+}
+
+func (h *CommandHandler) react(userID, clientID string, event *ws.InboundEvent, add bool) (interface{}, error) {
+	type Req struct {
+		MessageID string `json:"messageId"`
+		Emoji     string `json:"emoji"`
+	}
+	var req Req
+	if err := event.Decode(&req); err != nil {
+		return nil, errors.New("invalid data")
+	}
+	if req.MessageID == "" || req.Emoji == "" {
+		return nil, errors.New("messageId and emoji required")
+	}
+
+	var err error
+	action := "add"
+	if add {
+		err = h.ConversationUseCase.AddReaction(req.MessageID, userID, req.Emoji)
+	} else {
+		action = "remove"
+		err = h.ConversationUseCase.RemoveReaction(req.MessageID, userID, req.Emoji)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := h.ConversationUseCase.GetMessage(req.MessageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	h.broadcast(&ws.Message{
+		Type:      "reaction",
+		ChatID:    message["conversationId"].(string),
+		SenderID:  userID,
+		Timestamp: time.Now().Format(time.RFC3339),
+		Data: map[string]interface{}{
+			"messageId": req.MessageID,
+			"emoji":     req.Emoji,
+			"action":    action,
+		},
+		Origin: clientID,
+	})
+
+	return message, nil
+}
+
+func (h *CommandHandler) markRead(userID, clientID string, event *ws.InboundEvent) (interface{}, error) {
+	type Req struct {
+		MessageID string `json:"messageId"`
+	}
+	var req Req
+	if err := event.Decode(&req); err != nil {
+		return nil, errors.New("invalid data")
+	}
+
+	conversationID := event.ConversationID()
+	if conversationID == "" {
+		return nil, errors.New("chatId required")
+	}
+
+	result, err := h.ConversationUseCase.MarkConversationRead(conversationID, userID, req.MessageID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := result["lastReadMessageId"]; ok {
+		h.broadcast(&ws.Message{
+			Type:      "conversation_read",
+			ChatID:    conversationID,
+			SenderID:  userID,
+			Timestamp: time.Now().Format(time.RFC3339),
+			Data:      result,
+			Origin:    clientID,
+		})
+	}
+
+	return result, nil
+}
+
+func (h *CommandHandler) broadcast(message *ws.Message) {
+	select {
+	case h.Hub.Broadcast <- message:
+	default:
+		// Channel is full, skip broadcast
+	}
+}
@@ -3,18 +3,20 @@ package websocket
 import (
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/TomTom2k/chat-app/server/internal/config"
 	ws "github.com/TomTom2k/chat-app/server/internal/infrastructure/websocket"
-	"github.com/TomTom2k/chat-app/server/pkg/jwt"
+	"github.com/TomTom2k/chat-app/server/internal/usecase"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 )
 
 type WebSocketHandler struct {
-	Hub    *ws.Hub
-	Config *config.Config
+	Hub         *ws.Hub
+	Config      *config.Config
+	UserUseCase *usecase.UserUseCase
 }
 
 var upgrader = websocket.Upgrader{
@@ -49,8 +51,8 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 		return
 	}
 
-	// Validate token
-	claims, err := jwt.ValidateToken(token, h.Config.JWTSecret)
+	// Validate token and its session
+	claims, err := h.UserUseCase.Authenticate(token)
 	if err != nil {
 		log.Printf("WebSocket: Invalid token: %v", err)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
@@ -60,6 +62,17 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 	userID := claims.UserID
 	log.Printf("WebSocket: User %s connecting", userID)
 
+	// Optional resume point: events after this sequence number are replayed
+	var since uint64
+	sinceParam := c.Query("since")
+	if sinceParam != "" {
+		since, err = strconv.ParseUint(sinceParam, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid since"})
+			return
+		}
+	}
+
 	// Upgrade connection
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
@@ -77,11 +90,17 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 
 	// Create client
 	client := &ws.Client{
+		ID:     ws.NewClientID(),
 		Hub:    h.Hub,
 		Conn:   wsConn,
 		UserID: userID,
 		Send:   make(chan []byte, 256),
 		Chats:  make(map[string]bool),
+		Resume: sinceParam != "",
+		Since:  since,
+		Node:   c.Query("node"),
+
+		SessionID: claims.SessionID,
 	}
 
 	// Register client
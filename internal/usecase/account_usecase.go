@@ -0,0 +1,156 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain"
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+	"github.com/TomTom2k/chat-app/server/pkg/utils"
+)
+
+// SendVerificationEmail mails a new verification link; links sent before stop working
+func (u *UserUseCase) SendVerificationEmail(userID string) error {
+	user, err := u.Repo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.IsEmailVerified() {
+		return errors.New("email already verified")
+	}
+
+	token, err := u.issueUserToken(user, entity.UserTokenVerifyEmail, u.EmailVerifyTTL)
+	if err != nil {
+		return err
+	}
+
+	return u.Mailer.Send(domain.Mail{
+		To:      user.Email,
+		Subject: "Xác thực địa chỉ email",
+		Body: fmt.Sprintf("Chào %s,\n\nVui lòng mở liên kết sau để xác thực địa chỉ email của bạn:\n\n%s\n\nLiên kết có hiệu lực trong %s. Nếu bạn không tạo tài khoản, hãy bỏ qua email này.\n",
+			displayName(user), u.appLink("/verify-email", token), u.EmailVerifyTTL),
+	})
+}
+
+// VerifyEmail marks the address the token was sent to as verified
+func (u *UserUseCase) VerifyEmail(token string) error {
+	now := time.Now()
+	userToken, err := u.TokenRepo.ConsumeToken(hashToken(token), entity.UserTokenVerifyEmail, now)
+	if err != nil {
+		return err
+	}
+
+	user, err := u.Repo.GetByID(userToken.UserID)
+	if err != nil || !strings.EqualFold(user.Email, userToken.Email) {
+		return errors.New("invalid or expired token")
+	}
+
+	return u.Repo.MarkEmailVerified(user.ID, now)
+}
+
+// ForgotPassword mails a password reset link. It succeeds whether or not the email belongs
+// to an account, so the endpoint cannot be used to find out which addresses are registered.
+func (u *UserUseCase) ForgotPassword(email string) error {
+	user, err := u.Repo.GetByEmail(normalizeEmail(email))
+	if err != nil {
+		return err
+	}
+	if user.ID == "" {
+		return nil
+	}
+
+	token, err := u.issueUserToken(user, entity.UserTokenResetPassword, u.PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	err = u.Mailer.Send(domain.Mail{
+		To:      user.Email,
+		Subject: "Đặt lại mật khẩu",
+		Body: fmt.Sprintf("Chào %s,\n\nChúng tôi nhận được yêu cầu đặt lại mật khẩu cho tài khoản của bạn. Mở liên kết sau để đặt mật khẩu mới:\n\n%s\n\nLiên kết chỉ dùng được một lần và có hiệu lực trong %s. Nếu bạn không yêu cầu, hãy bỏ qua email này, mật khẩu của bạn không thay đổi.\n",
+			displayName(user), u.appLink("/reset-password", token), u.PasswordResetTTL),
+	})
+	if err != nil {
+		// Reporting the failure would reveal that the account exists
+		log.Printf("[ERROR]: unable to send password reset email: %v", err)
+	}
+	return nil
+}
+
+// ResetPassword sets a new password and logs the user out everywhere.
+// Receiving the link also proves the user owns the address, so the email becomes verified.
+func (u *UserUseCase) ResetPassword(token, newPassword string) error {
+	now := time.Now()
+	userToken, err := u.TokenRepo.ConsumeToken(hashToken(token), entity.UserTokenResetPassword, now)
+	if err != nil {
+		return err
+	}
+
+	user, err := u.Repo.GetByID(userToken.UserID)
+	if err != nil || !strings.EqualFold(user.Email, userToken.Email) {
+		return errors.New("invalid or expired token")
+	}
+
+	hashed, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := u.Repo.UpdatePassword(user.ID, hashed); err != nil {
+		return err
+	}
+
+	if !user.IsEmailVerified() {
+		if err := u.Repo.MarkEmailVerified(user.ID, now); err != nil {
+			return err
+		}
+	}
+
+	// Other reset links are useless now, and whoever knew the old password is signed out
+	u.TokenRepo.InvalidateTokens(user.ID, entity.UserTokenResetPassword)
+	_, err = u.LogoutAll(user.ID)
+	return err
+}
+
+// issueUserToken replaces the user's outstanding tokens for purpose with a new one
+func (u *UserUseCase) issueUserToken(user entity.User, purpose string, ttl time.Duration) (string, error) {
+	if err := u.TokenRepo.InvalidateTokens(user.ID, purpose); err != nil {
+		return "", err
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	err = u.TokenRepo.CreateToken(entity.UserToken{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		Email:     user.Email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (u *UserUseCase) appLink(path, token string) string {
+	return strings.TrimRight(u.AppBaseURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+func displayName(user entity.User) string {
+	if user.FullName != "" {
+		return user.FullName
+	}
+	if user.Name != "" {
+		return user.Name
+	}
+	return user.Email
+}
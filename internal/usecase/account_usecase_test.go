@@ -0,0 +1,188 @@
+package usecase
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+	"github.com/TomTom2k/chat-app/server/pkg/utils"
+)
+
+func newAccountUseCase(t *testing.T) (*UserUseCase, *memoryUserTokenRepo, *memoryMailer) {
+	t.Helper()
+	u, _, _ := newSessionUseCase(t)
+
+	hashed, err := utils.HashPassword("old-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unverified := false
+	u.Repo = newMemoryUserRepo(entity.User{ID: "u1", Email: "u1@example.com", Password: hashed, EmailVerified: &unverified})
+
+	tokenRepo := &memoryUserTokenRepo{}
+	mailer := &memoryMailer{}
+	u.TokenRepo = tokenRepo
+	u.Mailer = mailer
+	u.AppBaseURL = "https://chat.example.com/"
+	u.EmailVerifyTTL = 24 * time.Hour
+	u.PasswordResetTTL = time.Hour
+	return u, tokenRepo, mailer
+}
+
+var mailTokenPattern = regexp.MustCompile(`https://chat\.example\.com(/[a-z-]+)\?token=(\S+)`)
+
+// mailedToken returns the token of the link in the last mail and checks the link points at path
+func mailedToken(t *testing.T, mailer *memoryMailer, path string) string {
+	t.Helper()
+	if len(mailer.sent) == 0 {
+		t.Fatal("no mail was sent")
+	}
+	match := mailTokenPattern.FindStringSubmatch(mailer.sent[len(mailer.sent)-1].Body)
+	if match == nil || match[1] != path {
+		t.Fatalf("mail has no %s link:\n%s", path, mailer.sent[len(mailer.sent)-1].Body)
+	}
+	token, err := url.QueryUnescape(match[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestResetPassword(t *testing.T) {
+	u, _, mailer := newAccountUseCase(t)
+	session := mustStartSession(t, u, "u1")
+
+	if err := u.ForgotPassword(" U1@Example.com "); err != nil {
+		t.Fatalf("ForgotPassword: %v", err)
+	}
+	if to := mailer.sent[0].To; to != "u1@example.com" {
+		t.Errorf("mail sent to %q, want the account's address", to)
+	}
+	token := mailedToken(t, mailer, "/reset-password")
+
+	if err := u.ResetPassword(token, "new-password"); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+
+	user, _ := u.Repo.GetByID("u1")
+	if !utils.CheckPassword("new-password", user.Password) {
+		t.Error("password was not changed")
+	}
+	if !user.IsEmailVerified() {
+		t.Error("receiving the reset link did not verify the email")
+	}
+	if _, err := u.Authenticate(session.Token); err == nil {
+		t.Error("a session opened with the old password is still active")
+	}
+
+	// Reset links are single use
+	if err := u.ResetPassword(token, "another-password"); err == nil || err.Error() != "invalid or expired token" {
+		t.Errorf("reused token: err = %v, want invalid or expired token", err)
+	}
+}
+
+func TestForgotPasswordUnknownEmail(t *testing.T) {
+	u, tokenRepo, mailer := newAccountUseCase(t)
+
+	if err := u.ForgotPassword("nobody@example.com"); err != nil {
+		t.Errorf("ForgotPassword: err = %v, want nil so the address is not revealed", err)
+	}
+	if len(mailer.sent) != 0 || len(tokenRepo.tokens) != 0 {
+		t.Error("a reset link was issued for an unknown address")
+	}
+}
+
+func TestForgotPasswordHidesMailerFailure(t *testing.T) {
+	u, _, mailer := newAccountUseCase(t)
+	mailer.err = errors.New("smtp unavailable")
+
+	if err := u.ForgotPassword("u1@example.com"); err != nil {
+		t.Errorf("ForgotPassword: err = %v, want nil so the address is not revealed", err)
+	}
+}
+
+func TestForgotPasswordReplacesEarlierLink(t *testing.T) {
+	u, _, mailer := newAccountUseCase(t)
+
+	u.ForgotPassword("u1@example.com")
+	first := mailedToken(t, mailer, "/reset-password")
+	u.ForgotPassword("u1@example.com")
+	second := mailedToken(t, mailer, "/reset-password")
+
+	if err := u.ResetPassword(first, "new-password"); err == nil {
+		t.Error("an earlier reset link still works after a new one was sent")
+	}
+	if err := u.ResetPassword(second, "new-password"); err != nil {
+		t.Errorf("ResetPassword with the newest link: %v", err)
+	}
+}
+
+func TestResetPasswordRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(u *UserUseCase, tokenRepo *memoryUserTokenRepo)
+	}{
+		{"expired", func(u *UserUseCase, tokenRepo *memoryUserTokenRepo) {
+			tokenRepo.tokens[0].ExpiresAt = time.Now().Add(-time.Second)
+		}},
+		{"email changed since", func(u *UserUseCase, tokenRepo *memoryUserTokenRepo) {
+			user := u.Repo.(*memoryUserRepo).users["u1"]
+			user.Email = "new@example.com"
+			u.Repo.(*memoryUserRepo).users["u1"] = user
+		}},
+		{"verification token", func(u *UserUseCase, tokenRepo *memoryUserTokenRepo) {
+			tokenRepo.tokens[0].Purpose = entity.UserTokenVerifyEmail
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, tokenRepo, mailer := newAccountUseCase(t)
+			u.ForgotPassword("u1@example.com")
+			token := mailedToken(t, mailer, "/reset-password")
+			tt.prepare(u, tokenRepo)
+
+			if err := u.ResetPassword(token, "new-password"); err == nil || err.Error() != "invalid or expired token" {
+				t.Errorf("err = %v, want invalid or expired token", err)
+			}
+			user, _ := u.Repo.GetByID("u1")
+			if !utils.CheckPassword("old-password", user.Password) {
+				t.Error("password was changed")
+			}
+		})
+	}
+
+	u, _, _ := newAccountUseCase(t)
+	if err := u.ResetPassword("made-up-token", "new-password"); err == nil {
+		t.Error("a made up token was accepted")
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	u, _, mailer := newAccountUseCase(t)
+
+	if err := u.SendVerificationEmail("u1"); err != nil {
+		t.Fatalf("SendVerificationEmail: %v", err)
+	}
+	token := mailedToken(t, mailer, "/verify-email")
+
+	// A verification token cannot be used to reset the password
+	if err := u.ResetPassword(token, "new-password"); err == nil {
+		t.Error("a verification token reset the password")
+	}
+
+	if err := u.VerifyEmail(token); err != nil {
+		t.Fatalf("VerifyEmail: %v", err)
+	}
+	if user, _ := u.Repo.GetByID("u1"); !user.IsEmailVerified() {
+		t.Error("email is not verified")
+	}
+	if err := u.VerifyEmail(token); err == nil {
+		t.Error("a verification token was used twice")
+	}
+	if err := u.SendVerificationEmail("u1"); err == nil || err.Error() != "email already verified" {
+		t.Errorf("SendVerificationEmail after verifying: err = %v, want email already verified", err)
+	}
+}
@@ -0,0 +1,168 @@
+package usecase
+
+import (
+	"errors"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain"
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+)
+
+// UpdateGroupInfo renames the group and/or changes its description, avatar and join approval setting.
+// Name and description require the rename permission, avatar requires change_avatar, join approval requires invite.
+func (uc *ConversationUseCase) UpdateGroupInfo(conversationID, actorID string, update domain.GroupInfoUpdate) (map[string]interface{}, error) {
+	conv, err := uc.getGroupConversation(conversationID, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Name == nil && update.Description == nil && update.Avatar == nil && update.RequiresApproval == nil {
+		return nil, errors.New("name, description, avatar or requiresApproval required")
+	}
+
+	if update.Name != nil && *update.Name == "" {
+		return nil, errors.New("invalid name")
+	}
+
+	if (update.Name != nil || update.Description != nil) && !conv.Can(actorID, entity.PermissionRename) {
+		return nil, errors.New("forbidden: not allowed to rename the group")
+	}
+
+	if update.Avatar != nil && !conv.Can(actorID, entity.PermissionChangeAvatar) {
+		return nil, errors.New("forbidden: not allowed to change the group avatar")
+	}
+
+	// Whether joining needs approval is part of controlling who gets in
+	if update.RequiresApproval != nil && !conv.Can(actorID, entity.PermissionInvite) {
+		return nil, errors.New("forbidden: not allowed to change join approval")
+	}
+
+	if err := uc.ConversationRepo.UpdateGroupInfo(conversationID, update); err != nil {
+		return nil, err
+	}
+
+	actor, _ := uc.UserRepo.GetByID(actorID)
+	content := actor.FullName + " đã cập nhật thông tin nhóm"
+	if update.Name != nil {
+		content = actor.FullName + " đã đổi tên nhóm thành " + *update.Name
+	} else if update.Avatar != nil && update.Description == nil {
+		content = actor.FullName + " đã đổi ảnh nhóm"
+	}
+	systemMessage, err := uc.postSystemMessage(conversationID, actorID, content)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]interface{}{
+		"conversationId": conversationID,
+		"systemMessage":  systemMessage,
+	}
+	if update.Name != nil {
+		result["name"] = *update.Name
+	}
+	if update.Description != nil {
+		result["description"] = *update.Description
+	}
+	if update.Avatar != nil {
+		result["avatar"] = *update.Avatar
+	}
+	if update.RequiresApproval != nil {
+		result["requiresApproval"] = *update.RequiresApproval
+	}
+	return result, nil
+}
+
+// UpdatePermissions changes the group's permission matrix. Only the owner can do this.
+// Permissions not listed keep their current value.
+func (uc *ConversationUseCase) UpdatePermissions(conversationID, actorID string, permissions map[string]string) (map[string]interface{}, error) {
+	conv, err := uc.getGroupConversation(conversationID, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	if conv.RoleOf(actorID) != entity.ConversationRoleOwner {
+		return nil, errors.New("forbidden: only the owner can change permissions")
+	}
+
+	matrix := effectivePermissions(conv)
+	for permission, role := range permissions {
+		if _, ok := entity.DefaultGroupPermissions[permission]; !ok {
+			return nil, errors.New("invalid permission: " + permission)
+		}
+		if entity.RoleRank(role) == 0 {
+			return nil, errors.New("invalid role: " + role)
+		}
+		matrix[permission] = role
+	}
+
+	if err := uc.ConversationRepo.UpdatePermissions(conversationID, matrix); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"conversationId": conversationID,
+		"permissions":    matrix,
+	}, nil
+}
+
+// PinMessage pins a message in the conversation. Requires the pin permission.
+func (uc *ConversationUseCase) PinMessage(messageID, userID string) (map[string]interface{}, error) {
+	return uc.setPinned(messageID, userID, true)
+}
+
+// UnpinMessage unpins a message. Requires the pin permission.
+func (uc *ConversationUseCase) UnpinMessage(messageID, userID string) (map[string]interface{}, error) {
+	return uc.setPinned(messageID, userID, false)
+}
+
+func (uc *ConversationUseCase) setPinned(messageID, userID string, pinned bool) (map[string]interface{}, error) {
+	message, err := uc.MessageRepo.GetMessageByID(messageID)
+	if err != nil {
+		return nil, errors.New("message not found")
+	}
+
+	conversationID := message.GetConversationID()
+	conv, err := uc.getGroupConversation(conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !conv.Can(userID, entity.PermissionPin) {
+		return nil, errors.New("forbidden: not allowed to pin messages")
+	}
+
+	if pinned && message.IsDeleted() {
+		return nil, errors.New("forbidden: message has been deleted")
+	}
+
+	if pinned {
+		err = uc.ConversationRepo.PinMessage(conversationID, messageID)
+	} else {
+		err = uc.ConversationRepo.UnpinMessage(conversationID, messageID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"messageId":      messageID,
+		"conversationId": conversationID,
+		"pinned":         pinned,
+	}, nil
+}
+
+func defaultPermissions() map[string]string {
+	permissions := make(map[string]string, len(entity.DefaultGroupPermissions))
+	for permission, role := range entity.DefaultGroupPermissions {
+		permissions[permission] = role
+	}
+	return permissions
+}
+
+// effectivePermissions returns the full matrix of the group, defaults filled in
+func effectivePermissions(conv entity.Conversation) map[string]string {
+	permissions := defaultPermissions()
+	for permission := range permissions {
+		permissions[permission] = conv.RequiredRole(permission)
+	}
+	return permissions
+}
@@ -0,0 +1,213 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+)
+
+// CreateInvite creates a shareable invite link for a group. Requires the invite permission.
+// expiresIn <= 0 means the link never expires, maxUses <= 0 means unlimited uses.
+func (uc *ConversationUseCase) CreateInvite(conversationID, actorID string, expiresIn time.Duration, maxUses int, requiresApproval bool) (map[string]interface{}, error) {
+	conv, err := uc.getGroupConversation(conversationID, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !conv.Can(actorID, entity.PermissionInvite) {
+		return nil, errors.New("forbidden: not allowed to create invites")
+	}
+
+	if maxUses < 0 {
+		maxUses = 0
+	}
+
+	// A group that requires approval never hands out links that skip it
+	requiresApproval = requiresApproval || conv.RequiresApproval
+
+	token, err := generateInviteToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	invite := entity.ConversationInvite{
+		ConversationID:   conversationID,
+		Token:            token,
+		CreatedBy:        actorID,
+		MaxUses:          maxUses,
+		RequiresApproval: requiresApproval,
+		CreatedAt:        now,
+	}
+	if expiresIn > 0 {
+		expiresAt := now.Add(expiresIn)
+		invite.ExpiresAt = &expiresAt
+	}
+
+	if err := uc.InviteRepo.CreateInvite(invite); err != nil {
+		return nil, err
+	}
+
+	created, err := uc.InviteRepo.GetInviteByToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return inviteToMap(created), nil
+}
+
+// GetInvites lists the invite links of a group together with the redemption audit. Requires the invite permission.
+func (uc *ConversationUseCase) GetInvites(conversationID, actorID string) (map[string]interface{}, error) {
+	conv, err := uc.getGroupConversation(conversationID, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !conv.Can(actorID, entity.PermissionInvite) {
+		return nil, errors.New("forbidden: not allowed to view invites")
+	}
+
+	invites, err := uc.InviteRepo.GetInvitesByConversationID(conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]map[string]interface{}, 0, len(invites))
+	for _, invite := range invites {
+		list = append(list, inviteToMap(invite))
+	}
+
+	redemptions := conv.InviteRedemptions
+	if redemptions == nil {
+		redemptions = []entity.InviteRedemption{}
+	}
+
+	return map[string]interface{}{
+		"invites":     list,
+		"redemptions": redemptions,
+	}, nil
+}
+
+// RevokeInvite disables an invite link. Requires the invite permission.
+func (uc *ConversationUseCase) RevokeInvite(conversationID, inviteID, actorID string) error {
+	conv, err := uc.getGroupConversation(conversationID, actorID)
+	if err != nil {
+		return err
+	}
+
+	if !conv.Can(actorID, entity.PermissionInvite) {
+		return errors.New("forbidden: not allowed to revoke invites")
+	}
+
+	invite, err := uc.InviteRepo.GetInviteByID(inviteID)
+	if err != nil || invite.ConversationID != conversationID {
+		return errors.New("invite not found")
+	}
+
+	return uc.InviteRepo.RevokeInvite(inviteID)
+}
+
+// JoinByInvite redeems an invite link. Members are added right away unless the link or the group requires approval,
+// in which case a join request is queued for the admins. Every redemption is audited on the conversation.
+func (uc *ConversationUseCase) JoinByInvite(token, userID string) (map[string]interface{}, error) {
+	invite, err := uc.InviteRepo.GetInviteByToken(token)
+	if err != nil {
+		return nil, errors.New("invite not found")
+	}
+
+	now := time.Now()
+	if !invite.IsUsable(now) {
+		return nil, errors.New("invalid invite: expired, revoked or used up")
+	}
+
+	conv, err := uc.ConversationRepo.GetConversationByID(invite.ConversationID)
+	if err != nil || conv.Type != entity.ConversationTypeGroup {
+		return nil, errors.New("conversation not found")
+	}
+
+	result := map[string]interface{}{
+		"conversationId": conv.ID,
+		"name":           conv.Name,
+	}
+
+	// Already a member: nothing to redeem
+	if _, ok := conv.GetMember(userID); ok {
+		result["status"] = entity.InviteRedemptionJoined
+		return result, nil
+	}
+
+	// Links created before the group turned approval on still go through the queue
+	requiresApproval := invite.RequiresApproval || conv.RequiresApproval
+
+	// Redeeming again while a request is pending must not use up the link
+	if requiresApproval {
+		if _, err := uc.JoinRequestRepo.GetPendingJoinRequest(conv.ID, userID); err == nil {
+			result["status"] = entity.InviteRedemptionPending
+			return result, nil
+		}
+	}
+
+	if err := uc.InviteRepo.ConsumeInvite(invite.ID, now); err != nil {
+		return nil, errors.New("invalid invite: " + err.Error())
+	}
+
+	status := entity.InviteRedemptionJoined
+	if requiresApproval {
+		status = entity.InviteRedemptionPending
+		joinRequest, err := uc.createJoinRequest(conv, userID, "", invite.ID)
+		if err != nil {
+			return nil, err
+		}
+		result["joinRequest"] = joinRequest
+	} else if err := uc.ConversationRepo.AddMember(conv.ID, userID, entity.ConversationRoleMember); err != nil {
+		return nil, err
+	}
+
+	uc.ConversationRepo.RecordInviteRedemption(conv.ID, entity.InviteRedemption{
+		InviteID:   invite.ID,
+		UserID:     userID,
+		Status:     status,
+		RedeemedAt: now,
+	})
+	result["status"] = status
+
+	if status == entity.InviteRedemptionJoined {
+		user, _ := uc.UserRepo.GetByID(userID)
+		systemMessage, err := uc.postSystemMessage(conv.ID, userID, user.FullName+" đã tham gia nhóm qua link mời")
+		if err != nil {
+			return nil, err
+		}
+		result["userIds"] = []string{userID}
+		result["systemMessage"] = systemMessage
+	}
+
+	return result, nil
+}
+
+func inviteToMap(invite entity.ConversationInvite) map[string]interface{} {
+	return map[string]interface{}{
+		"id":               invite.ID,
+		"conversationId":   invite.ConversationID,
+		"token":            invite.Token,
+		"createdBy":        invite.CreatedBy,
+		"expiresAt":        invite.ExpiresAt,
+		"maxUses":          invite.MaxUses,
+		"uses":             invite.Uses,
+		"requiresApproval": invite.RequiresApproval,
+		"revoked":          invite.RevokedAt != nil,
+		"usable":           invite.IsUsable(time.Now()),
+		"createdAt":        invite.CreatedAt,
+	}
+}
+
+// generateInviteToken returns a random URL-safe token
+func generateInviteToken() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
@@ -0,0 +1,161 @@
+package usecase
+
+import (
+	"errors"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+)
+
+// RequestToJoin queues a request to join a group that requires approval
+func (uc *ConversationUseCase) RequestToJoin(conversationID, userID, note string) (map[string]interface{}, error) {
+	conv, err := uc.ConversationRepo.GetConversationByID(conversationID)
+	if err != nil || conv.Type != entity.ConversationTypeGroup {
+		return nil, errors.New("conversation not found")
+	}
+
+	if !conv.RequiresApproval {
+		return nil, errors.New("forbidden: this group does not accept join requests")
+	}
+
+	return uc.createJoinRequest(conv, userID, note, "")
+}
+
+func (uc *ConversationUseCase) createJoinRequest(conv entity.Conversation, userID, note, inviteID string) (map[string]interface{}, error) {
+	if _, ok := conv.GetMember(userID); ok {
+		return nil, errors.New("already a member")
+	}
+
+	if _, err := uc.JoinRequestRepo.GetPendingJoinRequest(conv.ID, userID); err == nil {
+		return nil, errors.New("join request already pending")
+	}
+
+	request := entity.JoinRequest{
+		ConversationID: conv.ID,
+		UserID:         userID,
+		Note:           note,
+		InviteID:       inviteID,
+		Status:         entity.JoinRequestPending,
+	}
+	if err := uc.JoinRequestRepo.CreateJoinRequest(request); err != nil {
+		return nil, err
+	}
+
+	created, err := uc.JoinRequestRepo.GetPendingJoinRequest(conv.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, _ := uc.UserRepo.GetByID(userID)
+	return joinRequestToMap(created, user), nil
+}
+
+// GetJoinRequests lists the pending join requests of a group, oldest first. Requires the invite permission.
+func (uc *ConversationUseCase) GetJoinRequests(conversationID, actorID string) ([]map[string]interface{}, error) {
+	conv, err := uc.getGroupConversation(conversationID, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !conv.Can(actorID, entity.PermissionInvite) {
+		return nil, errors.New("forbidden: not allowed to review join requests")
+	}
+
+	requests, err := uc.JoinRequestRepo.GetPendingJoinRequestsByConversationID(conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]map[string]interface{}, 0)
+	for _, request := range requests {
+		user, _ := uc.UserRepo.GetByID(request.UserID)
+		result = append(result, joinRequestToMap(request, user))
+	}
+
+	return result, nil
+}
+
+// ApproveJoinRequest adds the requester to the group. Requires the invite permission.
+func (uc *ConversationUseCase) ApproveJoinRequest(conversationID, requestID, actorID string) (map[string]interface{}, error) {
+	request, err := uc.reviewJoinRequest(conversationID, requestID, actorID, entity.JoinRequestApproved)
+	if err != nil {
+		return nil, err
+	}
+
+	// The user may have joined through another path in the meantime
+	conv, err := uc.ConversationRepo.GetConversationByID(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := conv.GetMember(request.UserID); !ok {
+		if err := uc.ConversationRepo.AddMember(conversationID, request.UserID, entity.ConversationRoleMember); err != nil {
+			return nil, err
+		}
+	}
+
+	actor, _ := uc.UserRepo.GetByID(actorID)
+	user, _ := uc.UserRepo.GetByID(request.UserID)
+	systemMessage, err := uc.postSystemMessage(conversationID, actorID, actor.FullName+" đã duyệt "+user.FullName+" vào nhóm")
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"requestId":      requestID,
+		"conversationId": conversationID,
+		"userId":         request.UserID,
+		"userIds":        []string{request.UserID},
+		"status":         entity.JoinRequestApproved,
+		"systemMessage":  systemMessage,
+	}, nil
+}
+
+// RejectJoinRequest declines a pending join request. Requires the invite permission.
+func (uc *ConversationUseCase) RejectJoinRequest(conversationID, requestID, actorID string) (map[string]interface{}, error) {
+	request, err := uc.reviewJoinRequest(conversationID, requestID, actorID, entity.JoinRequestRejected)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"requestId":      requestID,
+		"conversationId": conversationID,
+		"userId":         request.UserID,
+		"status":         entity.JoinRequestRejected,
+	}, nil
+}
+
+func (uc *ConversationUseCase) reviewJoinRequest(conversationID, requestID, actorID, status string) (entity.JoinRequest, error) {
+	conv, err := uc.getGroupConversation(conversationID, actorID)
+	if err != nil {
+		return entity.JoinRequest{}, err
+	}
+
+	if !conv.Can(actorID, entity.PermissionInvite) {
+		return entity.JoinRequest{}, errors.New("forbidden: not allowed to review join requests")
+	}
+
+	request, err := uc.JoinRequestRepo.GetJoinRequestByID(requestID)
+	if err != nil || request.ConversationID != conversationID {
+		return entity.JoinRequest{}, errors.New("join request not found")
+	}
+
+	if err := uc.JoinRequestRepo.ReviewJoinRequest(requestID, status, actorID); err != nil {
+		return entity.JoinRequest{}, err
+	}
+
+	return request, nil
+}
+
+func joinRequestToMap(request entity.JoinRequest, user entity.User) map[string]interface{} {
+	return map[string]interface{}{
+		"id":             request.ID,
+		"conversationId": request.ConversationID,
+		"userId":         request.UserID,
+		"name":           user.FullName,
+		"avatar":         user.Avatar,
+		"note":           request.Note,
+		"inviteId":       request.InviteID,
+		"status":         request.Status,
+		"createdAt":      request.CreatedAt,
+	}
+}
@@ -0,0 +1,324 @@
+package usecase
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+)
+
+// AddMembers adds users to a group conversation. Requires the invite permission.
+func (uc *ConversationUseCase) AddMembers(conversationID, actorID string, userIDs []string) (map[string]interface{}, error) {
+	conv, err := uc.getGroupConversation(conversationID, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !conv.Can(actorID, entity.PermissionInvite) {
+		return nil, errors.New("forbidden: not allowed to add members")
+	}
+
+	if len(userIDs) == 0 {
+		return nil, errors.New("userIds required")
+	}
+
+	added := make([]string, 0)
+	names := make([]string, 0)
+	for _, userID := range userIDs {
+		if _, ok := conv.GetMember(userID); ok || contains(added, userID) {
+			continue
+		}
+
+		user, err := uc.UserRepo.GetByID(userID)
+		if err != nil {
+			return nil, errors.New("user not found: " + userID)
+		}
+
+		if err := uc.ConversationRepo.AddMember(conversationID, userID, entity.ConversationRoleMember); err != nil {
+			return nil, err
+		}
+		added = append(added, userID)
+		names = append(names, user.FullName)
+	}
+
+	if len(added) == 0 {
+		return nil, errors.New("users are already members")
+	}
+
+	actor, _ := uc.UserRepo.GetByID(actorID)
+	systemMessage, err := uc.postSystemMessage(conversationID, actorID, actor.FullName+" đã thêm "+strings.Join(names, ", ")+" vào nhóm")
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"conversationId": conversationID,
+		"userIds":        added,
+		"systemMessage":  systemMessage,
+	}, nil
+}
+
+// RemoveMember removes a lower-ranked member from a group conversation. Requires the remove_members permission.
+func (uc *ConversationUseCase) RemoveMember(conversationID, actorID, userID string) (map[string]interface{}, error) {
+	if actorID == userID {
+		return nil, errors.New("use leave to remove yourself")
+	}
+
+	conv, err := uc.getGroupConversation(conversationID, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !conv.Can(actorID, entity.PermissionRemoveMembers) {
+		return nil, errors.New("forbidden: not allowed to remove members")
+	}
+
+	if _, ok := conv.GetMember(userID); !ok {
+		return nil, errors.New("member not found")
+	}
+
+	if !conv.Outranks(actorID, userID) {
+		return nil, errors.New("forbidden: cannot remove a member with an equal or higher role")
+	}
+
+	if err := uc.ConversationRepo.RemoveMember(conversationID, userID); err != nil {
+		return nil, err
+	}
+
+	actor, _ := uc.UserRepo.GetByID(actorID)
+	user, _ := uc.UserRepo.GetByID(userID)
+	systemMessage, err := uc.postSystemMessage(conversationID, actorID, actor.FullName+" đã xóa "+user.FullName+" khỏi nhóm")
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"conversationId": conversationID,
+		"userId":         userID,
+		"systemMessage":  systemMessage,
+	}, nil
+}
+
+// LeaveConversation removes the user from a group conversation.
+// When the owner leaves, ownership goes to successorID, or to the highest-ranked, longest-standing member if empty.
+func (uc *ConversationUseCase) LeaveConversation(conversationID, userID, successorID string) (map[string]interface{}, error) {
+	conv, err := uc.getGroupConversation(conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	newOwnerID := ""
+	if conv.RoleOf(userID) == entity.ConversationRoleOwner && len(conv.Members) > 1 {
+		if successorID == "" {
+			successorID = successorOf(conv, userID)
+		}
+		if _, ok := conv.GetMember(successorID); !ok || successorID == userID {
+			return nil, errors.New("successor not found")
+		}
+		if err := uc.ConversationRepo.TransferOwnership(conversationID, userID, successorID); err != nil {
+			return nil, err
+		}
+		newOwnerID = successorID
+	}
+
+	if err := uc.ConversationRepo.RemoveMember(conversationID, userID); err != nil {
+		return nil, err
+	}
+
+	user, _ := uc.UserRepo.GetByID(userID)
+	content := user.FullName + " đã rời nhóm"
+	if newOwnerID != "" {
+		successor, _ := uc.UserRepo.GetByID(newOwnerID)
+		content += ", " + successor.FullName + " là trưởng nhóm mới"
+	}
+	systemMessage, err := uc.postSystemMessage(conversationID, userID, content)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]interface{}{
+		"conversationId": conversationID,
+		"userId":         userID,
+		"systemMessage":  systemMessage,
+	}
+	if newOwnerID != "" {
+		result["ownerId"] = newOwnerID
+	}
+	return result, nil
+}
+
+// TransferOwnership hands the group over to another member; the previous owner becomes an admin
+func (uc *ConversationUseCase) TransferOwnership(conversationID, actorID, userID string) (map[string]interface{}, error) {
+	conv, err := uc.getGroupConversation(conversationID, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	if conv.RoleOf(actorID) != entity.ConversationRoleOwner {
+		return nil, errors.New("forbidden: only the owner can transfer ownership")
+	}
+
+	if actorID == userID {
+		return nil, errors.New("already the owner")
+	}
+
+	if _, ok := conv.GetMember(userID); !ok {
+		return nil, errors.New("member not found")
+	}
+
+	if err := uc.ConversationRepo.TransferOwnership(conversationID, actorID, userID); err != nil {
+		return nil, err
+	}
+
+	actor, _ := uc.UserRepo.GetByID(actorID)
+	user, _ := uc.UserRepo.GetByID(userID)
+	systemMessage, err := uc.postSystemMessage(conversationID, actorID, actor.FullName+" đã chuyển quyền trưởng nhóm cho "+user.FullName)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"conversationId": conversationID,
+		"userId":         userID,
+		"ownerId":        userID,
+		"previousOwner":  actorID,
+		"systemMessage":  systemMessage,
+	}, nil
+}
+
+// UpdateMemberRole changes a member's role. Admins and the owner can only manage roles below their own;
+// ownership is handed over with TransferOwnership instead.
+func (uc *ConversationUseCase) UpdateMemberRole(conversationID, actorID, userID, role string) (map[string]interface{}, error) {
+	if role == entity.ConversationRoleOwner {
+		return nil, errors.New("invalid role: use ownership transfer")
+	}
+	if entity.RoleRank(role) == 0 {
+		return nil, errors.New("invalid role")
+	}
+
+	conv, err := uc.getGroupConversation(conversationID, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	actorRank := entity.RoleRank(conv.RoleOf(actorID))
+	if actorRank < entity.RoleRank(entity.ConversationRoleAdmin) {
+		return nil, errors.New("forbidden: only admins can change roles")
+	}
+
+	if _, ok := conv.GetMember(userID); !ok {
+		return nil, errors.New("member not found")
+	}
+
+	current := conv.RoleOf(userID)
+	if current == role {
+		return nil, errors.New("member already has this role")
+	}
+
+	if !conv.Outranks(actorID, userID) || entity.RoleRank(role) >= actorRank {
+		return nil, errors.New("forbidden: cannot assign a role equal to or above your own")
+	}
+
+	if err := uc.ConversationRepo.UpdateMemberRole(conversationID, userID, role); err != nil {
+		return nil, err
+	}
+
+	actor, _ := uc.UserRepo.GetByID(actorID)
+	user, _ := uc.UserRepo.GetByID(userID)
+	systemMessage, err := uc.postSystemMessage(conversationID, actorID, actor.FullName+" đã đổi vai trò của "+user.FullName+" thành "+roleLabels[role])
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"conversationId": conversationID,
+		"userId":         userID,
+		"role":           role,
+		"previousRole":   current,
+		"systemMessage":  systemMessage,
+	}, nil
+}
+
+// roleLabels are the role names shown in system messages
+var roleLabels = map[string]string{
+	entity.ConversationRoleOwner:     "trưởng nhóm",
+	entity.ConversationRoleAdmin:     "quản trị viên",
+	entity.ConversationRoleModerator: "điều hành viên",
+	entity.ConversationRoleMember:    "thành viên",
+}
+
+// successorOf picks the next owner: highest role first, then the longest-standing member
+func successorOf(conv entity.Conversation, ownerID string) string {
+	var successor *entity.ConversationMember
+	for i, member := range conv.Members {
+		if member.UserID == ownerID {
+			continue
+		}
+		if successor == nil {
+			successor = &conv.Members[i]
+			continue
+		}
+		rank, best := entity.RoleRank(conv.RoleOf(member.UserID)), entity.RoleRank(conv.RoleOf(successor.UserID))
+		if rank > best || (rank == best && member.JoinedAt.Before(successor.JoinedAt)) {
+			successor = &conv.Members[i]
+		}
+	}
+	if successor == nil {
+		return ""
+	}
+	return successor.UserID
+}
+
+// getGroupConversation loads a group conversation the user belongs to
+func (uc *ConversationUseCase) getGroupConversation(conversationID, userID string) (entity.Conversation, error) {
+	conv, err := uc.ConversationRepo.GetConversationByID(conversationID)
+	if err != nil {
+		return conv, errors.New("conversation not found")
+	}
+
+	if _, ok := conv.GetMember(userID); !ok {
+		return conv, errors.New("unauthorized")
+	}
+
+	if conv.Type != entity.ConversationTypeGroup {
+		return conv, errors.New("not a group conversation")
+	}
+
+	return conv, nil
+}
+
+// postSystemMessage records a membership change in the conversation history
+func (uc *ConversationUseCase) postSystemMessage(conversationID, actorID, content string) (map[string]interface{}, error) {
+	message := entity.Message{
+		ConversationID: conversationID,
+		SenderID:       actorID,
+		Type:           entity.MessageTypeSystem,
+		Content:        content,
+		Status:         entity.MessageStatusSent,
+	}
+
+	messageID, err := uc.MessageRepo.CreateMessage(message)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reload by ID: a chat message sent at the same time may be newer
+	created, err := uc.MessageRepo.GetMessageByID(messageID)
+	if err != nil {
+		return nil, errors.New("failed to create message")
+	}
+
+	uc.ConversationRepo.RecordNewMessage(conversationID, actorID, created.ID, content, created.CreatedAt)
+
+	sender, _ := uc.UserRepo.GetByID(actorID)
+	return uc.messageToMap(created, actorID, sender), nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
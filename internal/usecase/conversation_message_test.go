@@ -0,0 +1,316 @@
+package usecase
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+)
+
+// newMessageUseCase holds three messages in c1, one minute apart: m00 and m02 from u1, m01 from u2.
+// u3 is a moderator and the last message preview is m02.
+func newMessageUseCase() (*ConversationUseCase, *memoryConversationRepo, *memoryMessageRepo) {
+	messages := seedMessages("c1", "u1", time.Now().Add(-time.Hour), 3)
+	messages[1].SenderID = "u2"
+
+	conv := newTestConversation("c1", "u1", "u2", "u3")
+	conv.Members[2].Role = entity.ConversationRoleModerator
+	conv.LastMessage = messages[2].Content
+	conv.LastMessageTime = &messages[2].CreatedAt
+
+	conversationRepo := newMemoryConversationRepo(conv)
+	messageRepo := newMemoryMessageRepo(messages...)
+	return &ConversationUseCase{
+		ConversationRepo: conversationRepo,
+		UserRepo:         newMemoryUserRepo(entity.User{ID: "u1"}, entity.User{ID: "u2"}, entity.User{ID: "u3"}),
+		MessageRepo:      messageRepo,
+	}, conversationRepo, messageRepo
+}
+
+func TestEditMessage(t *testing.T) {
+	uc, conversationRepo, messageRepo := newMessageUseCase()
+
+	result, err := uc.EditMessage("c1-m02", "u1", "fixed typo")
+	if err != nil {
+		t.Fatalf("EditMessage: %v", err)
+	}
+	if result["content"] != "fixed typo" || result["edited"] != true {
+		t.Errorf("result content = %v, edited = %v", result["content"], result["edited"])
+	}
+
+	msg := messageRepo.find("c1-m02")
+	if len(msg.EditHistory) != 1 || msg.EditHistory[0].Content != "message 2" {
+		t.Errorf("edit history = %+v, want the previous content", msg.EditHistory)
+	}
+	if got := conversationRepo.conversations["c1"].LastMessage; got != "fixed typo" {
+		t.Errorf("last message = %q, want the edited content", got)
+	}
+
+	if _, err := uc.EditMessage("c1-m02", "u1", "fixed twice"); err != nil {
+		t.Fatalf("second EditMessage: %v", err)
+	}
+	if got := len(msg.EditHistory); got != 2 {
+		t.Errorf("%d revisions after two edits, want 2", got)
+	}
+}
+
+func TestEditMessageKeepsPreviewOfLaterMessage(t *testing.T) {
+	uc, conversationRepo, _ := newMessageUseCase()
+
+	if _, err := uc.EditMessage("c1-m00", "u1", "edited"); err != nil {
+		t.Fatalf("EditMessage: %v", err)
+	}
+	if got := conversationRepo.conversations["c1"].LastMessage; got != "message 2" {
+		t.Errorf("last message = %q, want %q", got, "message 2")
+	}
+}
+
+func TestEditMessageSameContent(t *testing.T) {
+	uc, _, messageRepo := newMessageUseCase()
+
+	result, err := uc.EditMessage("c1-m02", "u1", "message 2")
+	if err != nil {
+		t.Fatalf("EditMessage: %v", err)
+	}
+	if result["edited"] != false || len(messageRepo.find("c1-m02").EditHistory) != 0 {
+		t.Error("an edit without changes was recorded")
+	}
+}
+
+func TestEditMessageRejects(t *testing.T) {
+	tests := []struct {
+		name      string
+		messageID string
+		userID    string
+		content   string
+		prepare   func(uc *ConversationUseCase, messageRepo *memoryMessageRepo)
+		want      string
+	}{
+		{"not the sender", "c1-m01", "u1", "edited", nil, "unauthorized"},
+		{"moderator", "c1-m02", "u3", "edited", nil, "unauthorized"},
+		{"empty content", "c1-m02", "u1", "", nil, "content required"},
+		{
+			"deleted", "c1-m02", "u1", "edited",
+			func(uc *ConversationUseCase, messageRepo *memoryMessageRepo) {
+				now := time.Now()
+				messageRepo.find("c1-m02").DeletedAt = &now
+			},
+			"message has been deleted",
+		},
+		{
+			"outside the edit window", "c1-m02", "u1", "edited",
+			func(uc *ConversationUseCase, messageRepo *memoryMessageRepo) {
+				uc.MessageEditWindow = 15 * time.Minute
+			},
+			"edit window has expired",
+		},
+		{
+			"inside the edit window", "c1-m02", "u1", "edited",
+			func(uc *ConversationUseCase, messageRepo *memoryMessageRepo) {
+				uc.MessageEditWindow = 2 * time.Hour
+			},
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc, _, messageRepo := newMessageUseCase()
+			if tt.prepare != nil {
+				tt.prepare(uc, messageRepo)
+			}
+
+			_, err := uc.EditMessage(tt.messageID, tt.userID, tt.content)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("err = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// deletingMessageRepo deletes the message for everyone after the use case has loaded it, just before the edit is written
+type deletingMessageRepo struct {
+	*memoryMessageRepo
+}
+
+func (r deletingMessageRepo) EditMessage(messageID, content string, revision entity.MessageRevision) error {
+	now := time.Now()
+	r.find(messageID).DeletedAt = &now
+	return r.memoryMessageRepo.EditMessage(messageID, content, revision)
+}
+
+func TestEditMessageDeletedConcurrently(t *testing.T) {
+	uc, conversationRepo, messageRepo := newMessageUseCase()
+	uc.MessageRepo = deletingMessageRepo{messageRepo}
+
+	if _, err := uc.EditMessage("c1-m02", "u1", "edited"); err == nil || err.Error() != "message has been deleted" {
+		t.Fatalf("err = %v, want %q", err, "message has been deleted")
+	}
+	if msg := messageRepo.find("c1-m02"); msg.Content != "message 2" || len(msg.EditHistory) != 0 {
+		t.Error("a deleted message was edited")
+	}
+	if got := conversationRepo.conversations["c1"].LastMessage; got != "message 2" {
+		t.Errorf("last message = %q, want it unchanged", got)
+	}
+}
+
+// withUnreadCounts gives u2 a read cursor at m01 with m02 unread, while u3 has read nothing
+func withUnreadCounts(conversationRepo *memoryConversationRepo, messageRepo *memoryMessageRepo) {
+	conv := conversationRepo.conversations["c1"]
+	readAt := messageRepo.find("c1-m01").CreatedAt
+	conv.Members[1].LastReadMessageID = "c1-m01"
+	conv.Members[1].LastReadAt = &readAt
+	conv.Members[1].UnreadCount = 1
+	conv.Members[2].UnreadCount = 3
+}
+
+func unreadCounts(conversationRepo *memoryConversationRepo, conversationID string) map[string]int {
+	counts := make(map[string]int)
+	for _, member := range conversationRepo.conversations[conversationID].Members {
+		counts[member.UserID] = member.UnreadCount
+	}
+	return counts
+}
+
+func TestDeleteLatestMessageForEveryone(t *testing.T) {
+	uc, conversationRepo, messageRepo := newMessageUseCase()
+	withUnreadCounts(conversationRepo, messageRepo)
+
+	result, err := uc.DeleteMessage("c1-m02", "u1", true)
+	if err != nil {
+		t.Fatalf("DeleteMessage: %v", err)
+	}
+
+	msg := messageRepo.find("c1-m02")
+	if !msg.IsDeleted() || msg.Content != "" {
+		t.Errorf("message was not tombstoned: deleted = %v, content = %q", msg.IsDeleted(), msg.Content)
+	}
+	if result["lastMessage"] != "message 1" {
+		t.Errorf("result lastMessage = %v, want the previous message", result["lastMessage"])
+	}
+	if got := conversationRepo.conversations["c1"].LastMessage; got != "message 1" {
+		t.Errorf("last message = %q, want the previous message", got)
+	}
+	want := map[string]int{"u1": 0, "u2": 0, "u3": 2}
+	if got := unreadCounts(conversationRepo, "c1"); !reflect.DeepEqual(got, want) {
+		t.Errorf("unread counts = %v, want %v", got, want)
+	}
+
+	// Deleting again changes nothing, in particular the unread counts
+	if _, err := uc.DeleteMessage("c1-m02", "u1", true); err != nil {
+		t.Fatalf("second DeleteMessage: %v", err)
+	}
+	if got := unreadCounts(conversationRepo, "c1"); !reflect.DeepEqual(got, want) {
+		t.Errorf("unread counts after deleting twice = %v, want %v", got, want)
+	}
+}
+
+func TestDeleteOlderMessageForEveryone(t *testing.T) {
+	uc, conversationRepo, messageRepo := newMessageUseCase()
+	withUnreadCounts(conversationRepo, messageRepo)
+
+	result, err := uc.DeleteMessage("c1-m00", "u1", true)
+	if err != nil {
+		t.Fatalf("DeleteMessage: %v", err)
+	}
+	if _, ok := result["lastMessage"]; ok {
+		t.Error("result has a new lastMessage although the latest message is unchanged")
+	}
+	if got := conversationRepo.conversations["c1"].LastMessage; got != "message 2" {
+		t.Errorf("last message = %q, want %q", got, "message 2")
+	}
+	// u2 had already read m00
+	want := map[string]int{"u1": 0, "u2": 1, "u3": 2}
+	if got := unreadCounts(conversationRepo, "c1"); !reflect.DeepEqual(got, want) {
+		t.Errorf("unread counts = %v, want %v", got, want)
+	}
+}
+
+func TestDeleteEveryMessageForEveryone(t *testing.T) {
+	uc, conversationRepo, messageRepo := newMessageUseCase()
+
+	for _, id := range []string{"c1-m02", "c1-m00"} {
+		if _, err := uc.DeleteMessage(id, "u1", true); err != nil {
+			t.Fatalf("DeleteMessage(%s): %v", id, err)
+		}
+	}
+	if _, err := uc.DeleteMessage("c1-m01", "u2", true); err != nil {
+		t.Fatalf("DeleteMessage(c1-m01): %v", err)
+	}
+	conv := conversationRepo.conversations["c1"]
+	if conv.LastMessage != deletedMessagePlaceholder {
+		t.Errorf("last message = %q, want the placeholder", conv.LastMessage)
+	}
+	if newest := messageRepo.find("c1-m02").CreatedAt; !conv.LastMessageTime.Equal(newest) {
+		t.Errorf("last message time = %v, want the time of the newest message %v", conv.LastMessageTime, newest)
+	}
+}
+
+func TestDeleteMessageForMe(t *testing.T) {
+	uc, conversationRepo, messageRepo := newMessageUseCase()
+
+	result, err := uc.DeleteMessage("c1-m02", "u2", false)
+	if err != nil {
+		t.Fatalf("DeleteMessage: %v", err)
+	}
+	if result["lastMessage"] != "message 1" {
+		t.Errorf("result lastMessage = %v, want the latest message u2 still sees", result["lastMessage"])
+	}
+	if msg := messageRepo.find("c1-m02"); msg.IsDeleted() || msg.Content != "message 2" {
+		t.Error("deleting for one member removed the message for everyone")
+	}
+	if got := conversationRepo.conversations["c1"].LastMessage; got != "message 2" {
+		t.Errorf("shared last message = %q, want it unchanged", got)
+	}
+
+	for userID, want := range map[string]int{"u2": 2, "u3": 3} {
+		page, err := uc.GetMessages("c1", userID, "", "", 10)
+		if err != nil {
+			t.Fatalf("GetMessages(%s): %v", userID, err)
+		}
+		if got := len(page.Messages); got != want {
+			t.Errorf("%s sees %d messages, want %d", userID, got, want)
+		}
+	}
+	if _, err := uc.GetMessage("c1-m02", "u2"); err == nil {
+		t.Error("GetMessage returned a message the user deleted for themselves")
+	}
+}
+
+func TestDeleteMessageForEveryonePermissions(t *testing.T) {
+	tests := []struct {
+		name      string
+		messageID string
+		userID    string
+		allowed   bool
+	}{
+		{"sender", "c1-m01", "u2", true},
+		{"member deleting another's message", "c1-m00", "u2", false},
+		{"moderator deleting a member's message", "c1-m01", "u3", true},
+		{"moderator deleting the owner's message", "c1-m00", "u3", false},
+		{"owner deleting a member's message", "c1-m01", "u1", true},
+		{"non-member", "c1-m01", "u4", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc, _, messageRepo := newMessageUseCase()
+
+			_, err := uc.DeleteMessage(tt.messageID, tt.userID, true)
+			if tt.allowed && err != nil {
+				t.Errorf("err = %v, want nil", err)
+			}
+			if !tt.allowed && (err == nil || err.Error() != "unauthorized") {
+				t.Errorf("err = %v, want unauthorized", err)
+			}
+			if deleted := messageRepo.find(tt.messageID).IsDeleted(); deleted != tt.allowed {
+				t.Errorf("deleted = %v, want %v", deleted, tt.allowed)
+			}
+		})
+	}
+}
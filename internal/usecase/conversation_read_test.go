@@ -0,0 +1,144 @@
+package usecase
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+)
+
+func newUnreadUseCase() (*ConversationUseCase, *memoryConversationRepo, *memoryMessageRepo) {
+	conversationRepo := newMemoryConversationRepo(newTestConversation("c1", "u1", "u2", "u3"))
+	messageRepo := newMemoryMessageRepo()
+	return &ConversationUseCase{
+		ConversationRepo: conversationRepo,
+		UserRepo:         newMemoryUserRepo(entity.User{ID: "u1"}, entity.User{ID: "u2"}, entity.User{ID: "u3"}),
+		MessageRepo:      messageRepo,
+	}, conversationRepo, messageRepo
+}
+
+func sendTestMessages(t *testing.T, uc *ConversationUseCase, senderID string, count int) []string {
+	t.Helper()
+	ids := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		msg, err := uc.SendMessage("c1", senderID, "hello", "", "", nil)
+		if err != nil {
+			t.Fatalf("SendMessage: %v", err)
+		}
+		ids = append(ids, msg["id"].(string))
+	}
+	return ids
+}
+
+func TestSendMessageCountsUnreadPerMember(t *testing.T) {
+	uc, conversationRepo, _ := newUnreadUseCase()
+
+	sendTestMessages(t, uc, "u1", 3)
+	if got, want := unreadCounts(conversationRepo, "c1"), map[string]int{"u1": 0, "u2": 3, "u3": 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unread counts = %v, want %v", got, want)
+	}
+
+	// Sending moves the sender's cursor to their own message
+	reply := sendTestMessages(t, uc, "u2", 1)
+	if got, want := unreadCounts(conversationRepo, "c1"), map[string]int{"u1": 1, "u2": 0, "u3": 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unread counts = %v, want %v", got, want)
+	}
+	if member, _ := conversationRepo.conversations["c1"].GetMember("u2"); member.LastReadMessageID != reply[0] {
+		t.Errorf("sender's read cursor = %q, want %q", member.LastReadMessageID, reply[0])
+	}
+}
+
+func TestMarkConversationRead(t *testing.T) {
+	uc, conversationRepo, messageRepo := newUnreadUseCase()
+	sent := sendTestMessages(t, uc, "u1", 3)
+	sent = append(sent, sendTestMessages(t, uc, "u2", 1)...)
+
+	result, err := uc.MarkConversationRead("c1", "u3", sent[1])
+	if err != nil {
+		t.Fatalf("MarkConversationRead: %v", err)
+	}
+	if result["unread"] != 2 || result["lastReadMessageId"] != sent[1] {
+		t.Errorf("result = %v, want 2 unread after %s", result, sent[1])
+	}
+	for i, id := range sent {
+		read := len(messageRepo.find(id).ReadReceipts) > 0
+		if want := i <= 1; read != want {
+			t.Errorf("message %s has a read receipt = %v, want %v", id, read, want)
+		}
+	}
+
+	// The cursor never moves backwards
+	result, err = uc.MarkConversationRead("c1", "u3", sent[0])
+	if err != nil {
+		t.Fatalf("MarkConversationRead: %v", err)
+	}
+	if result["unread"] != 2 || result["lastReadMessageId"] != sent[1] {
+		t.Errorf("result after reading an older message = %v, want the cursor to stay at %s", result, sent[1])
+	}
+
+	// Without a message the whole conversation is read
+	if _, err := uc.MarkConversationRead("c1", "u3", ""); err != nil {
+		t.Fatalf("MarkConversationRead: %v", err)
+	}
+	if got, want := unreadCounts(conversationRepo, "c1"), map[string]int{"u1": 1, "u2": 0, "u3": 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unread counts = %v, want %v", got, want)
+	}
+}
+
+func TestMarkMessageAsReadAdvancesCursor(t *testing.T) {
+	uc, conversationRepo, _ := newUnreadUseCase()
+	sent := sendTestMessages(t, uc, "u1", 2)
+
+	if err := uc.MarkMessageAsRead(sent[0], "u2"); err != nil {
+		t.Fatalf("MarkMessageAsRead: %v", err)
+	}
+	member, _ := conversationRepo.conversations["c1"].GetMember("u2")
+	if member.LastReadMessageID != sent[0] || member.UnreadCount != 1 {
+		t.Errorf("cursor = %q with %d unread, want %q with 1 unread", member.LastReadMessageID, member.UnreadCount, sent[0])
+	}
+
+	// Reading one's own message changes nothing
+	if err := uc.MarkMessageAsRead(sent[1], "u1"); err != nil {
+		t.Fatalf("MarkMessageAsRead: %v", err)
+	}
+	if got := unreadCounts(conversationRepo, "c1")["u1"]; got != 0 {
+		t.Errorf("sender unread = %d, want 0", got)
+	}
+}
+
+func TestMarkConversationReadRejects(t *testing.T) {
+	uc, _, messageRepo := newUnreadUseCase()
+	sendTestMessages(t, uc, "u1", 1)
+	messageRepo.messages = append(messageRepo.messages, &seedMessages("c2", "u1", testEpoch, 1)[0])
+
+	if _, err := uc.MarkConversationRead("c1", "u4", ""); err == nil || err.Error() != "unauthorized" {
+		t.Errorf("non-member: err = %v, want unauthorized", err)
+	}
+	if _, err := uc.MarkConversationRead("c1", "u2", "c2-m00"); err == nil || err.Error() != "message not found" {
+		t.Errorf("message of another conversation: err = %v, want message not found", err)
+	}
+}
+
+func TestGetConversationsReturnsOwnUnreadCount(t *testing.T) {
+	uc, _, _ := newUnreadUseCase()
+	sendTestMessages(t, uc, "u1", 2)
+	last := sendTestMessages(t, uc, "u2", 1)
+
+	for userID, want := range map[string]int{"u1": 1, "u2": 0, "u3": 3} {
+		conversations, err := uc.GetConversations(userID)
+		if err != nil {
+			t.Fatalf("GetConversations(%s): %v", userID, err)
+		}
+		if len(conversations) != 1 {
+			t.Fatalf("GetConversations(%s) returned %d conversations, want 1", userID, len(conversations))
+		}
+		if got := conversations[0]["unread"]; got != want {
+			t.Errorf("%s unread = %v, want %d", userID, got, want)
+		}
+	}
+
+	conversations, _ := uc.GetConversations("u2")
+	if got := conversations[0]["lastReadMessageId"]; got != last[0] {
+		t.Errorf("u2 lastReadMessageId = %v, want %s", got, last[0])
+	}
+}
@@ -2,19 +2,36 @@ package usecase
 
 import (
 	"errors"
+	"log"
 	"time"
 
 	"github.com/TomTom2k/chat-app/server/internal/domain"
 	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
 )
 
+const (
+	DefaultMessagePageSize = 50
+	MaxMessagePageSize     = 100
+)
+
+// MessagePage is one page of message history, in ascending order
+type MessagePage struct {
+	Messages   []map[string]interface{} `json:"messages"`
+	NextCursor string                   `json:"nextCursor,omitempty"`
+	HasMore    bool                     `json:"hasMore"`
+}
+
 type ConversationUseCase struct {
 	ConversationRepo domain.ConversationRepository
 	UserRepo         domain.UserRepository
 	MessageRepo      domain.MessageRepository
+	InviteRepo       domain.InviteRepository
+	JoinRequestRepo  domain.JoinRequestRepository
+	FriendRepo       domain.FriendRepository
 	Hub              interface {
-		IsUserOnline(userID string) bool
+		ConnectionState(userID string) string
 	}
+	MessageEditWindow time.Duration // 0 = không giới hạn
 }
 
 func (uc *ConversationUseCase) GetConversations(userID string) ([]map[string]interface{}, error) {
@@ -23,16 +40,19 @@ func (uc *ConversationUseCase) GetConversations(userID string) ([]map[string]int
 		return nil, err
 	}
 
+	friends, blocked := uc.friendSet(userID), uc.blockedSet(userID)
 	result := make([]map[string]interface{}, 0)
 	for _, conv := range conversations {
+		self, _ := conv.GetMember(userID)
 		convData := map[string]interface{}{
-			"id":          conv.ID,
-			"type":        conv.Type,
-			"name":        conv.Name,
-			"lastMessage": conv.LastMessage,
-			"time":        conv.LastMessageTime,
-			"unread":      conv.Unread,
-			"avatar":      conv.Avatar,
+			"id":                conv.ID,
+			"type":              conv.Type,
+			"name":              conv.Name,
+			"lastMessage":       conv.LastMessage,
+			"time":              conv.LastMessageTime,
+			"unread":            self.UnreadCount,
+			"lastReadMessageId": self.LastReadMessageID,
+			"avatar":            conv.Avatar,
 		}
 
 		// For direct conversations, get the other user's info
@@ -48,12 +68,11 @@ func (uc *ConversationUseCase) GetConversations(userID string) ([]map[string]int
 			if otherUserID != "" {
 				otherUser, err := uc.UserRepo.GetByID(otherUserID)
 				if err == nil {
-					isOnline := otherUser.Online
-					if uc.Hub != nil {
-						isOnline = uc.Hub.IsUserOnline(otherUserID)
-					}
+					profile, isOnline := uc.memberProfile(otherUser, userID, friends[otherUserID], blocked[otherUserID])
 					convData["name"] = otherUser.FullName
-					convData["avatar"] = otherUser.Avatar
+					if avatar, ok := profile["avatar"]; ok {
+						convData["avatar"] = avatar
+					}
 					convData["online"] = isOnline
 					convData["userId1"] = userID
 					convData["userId2"] = otherUserID
@@ -90,12 +109,15 @@ func (uc *ConversationUseCase) GetConversation(conversationID, userID string) (m
 		return nil, errors.New("unauthorized")
 	}
 
+	self, _ := conv.GetMember(userID)
 	result := map[string]interface{}{
-		"id":      conv.ID,
-		"type":    conv.Type,
-		"name":    conv.Name,
-		"avatar":  conv.Avatar,
-		"members": len(conv.Members),
+		"id":                conv.ID,
+		"type":              conv.Type,
+		"name":              conv.Name,
+		"avatar":            conv.Avatar,
+		"members":           len(conv.Members),
+		"unread":            self.UnreadCount,
+		"lastReadMessageId": self.LastReadMessageID,
 	}
 
 	// For direct conversations, get the other user's info
@@ -111,54 +133,106 @@ func (uc *ConversationUseCase) GetConversation(conversationID, userID string) (m
 		if otherUserID != "" {
 			otherUser, err := uc.UserRepo.GetByID(otherUserID)
 			if err == nil {
-				isOnline := otherUser.Online
-				if uc.Hub != nil {
-					isOnline = uc.Hub.IsUserOnline(otherUserID)
-				}
+				blocked := uc.checkNotBlocked(userID, otherUserID) != nil
+				profile, isOnline := uc.memberProfile(otherUser, userID, uc.friendSet(userID)[otherUserID], blocked)
+				result["blocked"] = blocked
 				result["name"] = otherUser.FullName
-				result["avatar"] = otherUser.Avatar
+				if avatar, ok := profile["avatar"]; ok {
+					result["avatar"] = avatar
+				}
 				result["online"] = isOnline
 				result["userId1"] = userID
 				result["userId2"] = otherUserID
-				result["users"] = []map[string]interface{}{
-					{
-						"id":     otherUser.ID,
-						"name":   otherUser.FullName,
-						"email":  otherUser.Email,
-						"avatar": otherUser.Avatar,
-						"online": isOnline,
-					},
-				}
+				result["users"] = []map[string]interface{}{profile}
 			}
 		}
 	} else {
 		// For group conversations, get all members
+		friends, blocked := uc.friendSet(userID), uc.blockedSet(userID)
 		membersList := make([]map[string]interface{}, 0)
 		for _, member := range conv.Members {
 			user, err := uc.UserRepo.GetByID(member.UserID)
 			if err == nil {
-				isOnline := user.Online
-				if uc.Hub != nil {
-					isOnline = uc.Hub.IsUserOnline(member.UserID)
-				}
-				membersList = append(membersList, map[string]interface{}{
-					"id":     user.ID,
-					"name":   user.FullName,
-					"email":  user.Email,
-					"avatar": user.Avatar,
-					"online": isOnline,
-					"role":   member.Role,
-				})
+				profile, _ := uc.memberProfile(user, userID, friends[member.UserID], blocked[member.UserID])
+				profile["role"] = conv.RoleOf(member.UserID)
+				membersList = append(membersList, profile)
 			}
 		}
 		result["users"] = membersList
 		result["online"] = false
+		result["description"] = conv.Description
+		result["ownerId"] = conv.OwnerID()
+		result["role"] = conv.RoleOf(userID)
+		result["permissions"] = effectivePermissions(conv)
+		result["pinnedMessages"] = conv.PinnedMessages
+		result["requiresApproval"] = conv.RequiresApproval
 	}
 
 	return result, nil
 }
 
+// memberProfile is what viewerID may see of a conversation member under the member's privacy settings,
+// and whether the member shows as online. Presence is not shared across a block.
+func (uc *ConversationUseCase) memberProfile(user entity.User, viewerID string, isFriend, blocked bool) (map[string]interface{}, bool) {
+	access := profileAccessFor(user, viewerID, isFriend)
+	if blocked {
+		access.onlineStatus, access.lastSeen = false, false
+	}
+
+	profile := map[string]interface{}{
+		"id":     user.ID,
+		"name":   user.FullName,
+		"online": false,
+	}
+	if access.email {
+		profile["email"] = user.Email
+	}
+	if access.avatar {
+		profile["avatar"] = user.Avatar
+	}
+	status := setPresenceFields(profile, user, connectionState(uc.Hub, user), access, time.Now())
+	return profile, status != entity.PresenceOffline
+}
+
+// friendSet returns the friends of userID, who may see more of a profile than other members.
+// If they cannot be loaded everyone is treated as a stranger.
+func (uc *ConversationUseCase) friendSet(userID string) map[string]bool {
+	friends := make(map[string]bool)
+	if uc.FriendRepo == nil {
+		return friends
+	}
+	friendIDs, err := uc.FriendRepo.GetFriendUserIDs(userID)
+	if err != nil {
+		return friends
+	}
+	for _, id := range friendIDs {
+		friends[id] = true
+	}
+	return friends
+}
+
+// blockedSet returns the users with a block with userID in either direction
+func (uc *ConversationUseCase) blockedSet(userID string) map[string]bool {
+	blocked := make(map[string]bool)
+	if uc.FriendRepo == nil {
+		return blocked
+	}
+	userIDs, err := uc.FriendRepo.GetBlockedUserIDs(userID)
+	if err != nil {
+		log.Printf("[ERROR]: unable to load blocks of %s: %v", userID, err)
+		return blocked
+	}
+	for _, id := range userIDs {
+		blocked[id] = true
+	}
+	return blocked
+}
+
 func (uc *ConversationUseCase) CreateDirectConversation(userID1, userID2 string) (map[string]interface{}, error) {
+	if err := uc.checkNotBlocked(userID1, userID2); err != nil {
+		return nil, err
+	}
+
 	// Check if conversation already exists
 	existingConv, _ := uc.ConversationRepo.GetDirectConversationByUserIDs(userID1, userID2)
 	if existingConv.ID != "" {
@@ -166,8 +240,7 @@ func (uc *ConversationUseCase) CreateDirectConversation(userID1, userID2 string)
 	}
 
 	// Verify both users exist
-	user1, err := uc.UserRepo.GetByID(userID1)
-	if err != nil {
+	if _, err := uc.UserRepo.GetByID(userID1); err != nil {
 		return nil, errors.New("user not found")
 	}
 
@@ -177,23 +250,19 @@ func (uc *ConversationUseCase) CreateDirectConversation(userID1, userID2 string)
 	}
 
 	// Check if users are friends (accepted)
-	isFriend := false
-	for _, friendID := range user1.Friends {
-		if friendID == userID2 {
-			isFriend = true
-			break
-		}
+	friendship, err := uc.FriendRepo.GetFriendByUserIDs(userID1, userID2)
+	if err != nil {
+		return nil, err
 	}
 
-	if !isFriend {
+	if friendship.Status != entity.FriendStatusAccepted {
 		return nil, errors.New("you can only chat with accepted friends")
 	}
 
 	now := time.Now()
 	conversation := entity.Conversation{
-		Type:    entity.ConversationTypeDirect,
-		Name:    user2.FullName, // Default name is other user's name
-		Unread:  0,
+		Type: entity.ConversationTypeDirect,
+		Name: user2.FullName, // Default name is other user's name
 		Members: []entity.ConversationMember{
 			{UserID: userID1, JoinedAt: now},
 			{UserID: userID2, JoinedAt: now},
@@ -225,7 +294,7 @@ func (uc *ConversationUseCase) CreateGroupConversation(name, description, create
 
 	now := time.Now()
 	members := []entity.ConversationMember{
-		{UserID: createdBy, Role: "admin", JoinedAt: now},
+		{UserID: createdBy, Role: entity.ConversationRoleOwner, JoinedAt: now},
 	}
 
 	// Add other users as members
@@ -233,7 +302,7 @@ func (uc *ConversationUseCase) CreateGroupConversation(name, description, create
 		if userID != createdBy {
 			members = append(members, entity.ConversationMember{
 				UserID:   userID,
-				Role:     "member",
+				Role:     entity.ConversationRoleMember,
 				JoinedAt: now,
 			})
 		}
@@ -244,8 +313,8 @@ func (uc *ConversationUseCase) CreateGroupConversation(name, description, create
 		Name:        name,
 		Description: description,
 		CreatedBy:   createdBy,
-		Unread:      0,
 		Members:     members,
+		Permissions: defaultPermissions(),
 	}
 
 	err := uc.ConversationRepo.CreateConversation(conversation)
@@ -265,7 +334,7 @@ func (uc *ConversationUseCase) CreateGroupConversation(name, description, create
 	}, nil
 }
 
-func (uc *ConversationUseCase) GetMessages(conversationID, userID string) ([]map[string]interface{}, error) {
+func (uc *ConversationUseCase) GetMessages(conversationID, userID string, before, after string, limit int) (*MessagePage, error) {
 	// Verify user is a member
 	conv, err := uc.ConversationRepo.GetConversationByID(conversationID)
 	if err != nil {
@@ -284,18 +353,69 @@ func (uc *ConversationUseCase) GetMessages(conversationID, userID string) ([]map
 		return nil, errors.New("unauthorized")
 	}
 
-	messages, err := uc.MessageRepo.GetMessagesByConversationID(conversationID)
+	if before != "" && after != "" {
+		return nil, errors.New("invalid cursor: before and after cannot be used together")
+	}
+
+	if limit <= 0 {
+		limit = DefaultMessagePageSize
+	}
+	if limit > MaxMessagePageSize {
+		limit = MaxMessagePageSize
+	}
+
+	query := domain.MessageQuery{Limit: limit, ViewerID: userID}
+	if before != "" {
+		query.Before, err = uc.resolveCursor(conversationID, before)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if after != "" {
+		query.After, err = uc.resolveCursor(conversationID, after)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	messages, hasMore, err := uc.MessageRepo.GetMessagesPage(conversationID, query)
 	if err != nil {
 		return nil, err
 	}
 
-	result := make([]map[string]interface{}, 0)
+	page := &MessagePage{
+		Messages: make([]map[string]interface{}, 0),
+		HasMore:  hasMore,
+	}
 	for _, msg := range messages {
 		sender, _ := uc.UserRepo.GetByID(msg.SenderID)
-		result = append(result, uc.messageToMap(msg, userID, sender))
+		page.Messages = append(page.Messages, uc.messageToMap(msg, userID, sender))
 	}
 
-	return result, nil
+	// Next cursor continues in the same direction: the newest message when paging forward, the oldest otherwise
+	if len(messages) > 0 {
+		if after != "" {
+			page.NextCursor = messages[len(messages)-1].ID
+		} else {
+			page.NextCursor = messages[0].ID
+		}
+	}
+
+	return page, nil
+}
+
+// resolveCursor accepts either a message ID or an RFC3339 timestamp
+func (uc *ConversationUseCase) resolveCursor(conversationID, cursor string) (*domain.MessageCursor, error) {
+	if t, err := time.Parse(time.RFC3339Nano, cursor); err == nil {
+		return &domain.MessageCursor{CreatedAt: t}, nil
+	}
+
+	msg, err := uc.MessageRepo.GetMessageByID(cursor)
+	if err != nil || msg.GetConversationID() != conversationID {
+		return nil, errors.New("invalid cursor")
+	}
+
+	return &domain.MessageCursor{CreatedAt: msg.CreatedAt, ID: msg.ID}, nil
 }
 
 func (uc *ConversationUseCase) SendMessage(conversationID, senderID, content string, replyToID string, messageType entity.MessageType, attachments []entity.MessageAttachment) (map[string]interface{}, error) {
@@ -317,6 +437,26 @@ func (uc *ConversationUseCase) SendMessage(conversationID, senderID, content str
 		return nil, errors.New("unauthorized")
 	}
 
+	if !conv.Can(senderID, entity.PermissionPost) {
+		return nil, errors.New("forbidden: not allowed to post in this conversation")
+	}
+
+	// No DMs across a block, in either direction
+	if conv.Type == entity.ConversationTypeDirect {
+		for _, member := range conv.Members {
+			if member.UserID == senderID {
+				continue
+			}
+			if err := uc.checkNotBlocked(senderID, member.UserID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if content == "" && len(attachments) == 0 {
+		return nil, errors.New("content or attachments required")
+	}
+
 	// Verify replyToID if provided
 	if replyToID != "" {
 		_, err := uc.MessageRepo.GetMessageByID(replyToID)
@@ -341,45 +481,207 @@ func (uc *ConversationUseCase) SendMessage(conversationID, senderID, content str
 		Status:         entity.MessageStatusSent,
 	}
 
-	err = uc.MessageRepo.CreateMessage(message)
+	messageID, err := uc.MessageRepo.CreateMessage(message)
 	if err != nil {
 		return nil, err
 	}
 
-	// Update conversation last message
-	now := time.Now()
-	lastMessageText := content
-	if len(attachments) > 0 {
-		switch attachments[0].Type {
-		case "image":
-			lastMessageText = "📷 Hình ảnh"
-		case "video":
-			lastMessageText = "🎥 Video"
-		case "file":
-			lastMessageText = "📎 " + attachments[0].FileName
-		case "audio":
-			lastMessageText = "🎤 Tin nhắn thoại"
+	// Reload by ID: the newest message of the conversation may be another member's
+	created, err := uc.MessageRepo.GetMessageByID(messageID)
+	if err != nil {
+		return nil, errors.New("failed to create message")
+	}
+
+	// Update conversation last message and unread counters of other members
+	uc.ConversationRepo.RecordNewMessage(conversationID, senderID, created.ID, lastMessagePreview(created), created.CreatedAt)
+
+	sender, _ := uc.UserRepo.GetByID(senderID)
+	return uc.messageToMap(created, senderID, sender), nil
+}
+
+// GetMessage returns a single message as seen by the user
+func (uc *ConversationUseCase) GetMessage(messageID, userID string) (map[string]interface{}, error) {
+	message, err := uc.MessageRepo.GetMessageByID(messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	conv, err := uc.ConversationRepo.GetConversationByID(message.GetConversationID())
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := conv.GetMember(userID); !ok || message.IsHiddenFor(userID) {
+		return nil, errors.New("message not found")
+	}
+
+	sender, _ := uc.UserRepo.GetByID(message.SenderID)
+	return uc.messageToMap(message, userID, sender), nil
+}
+
+func (uc *ConversationUseCase) EditMessage(messageID, userID, content string) (map[string]interface{}, error) {
+	message, err := uc.MessageRepo.GetMessageByID(messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the sender can edit their message
+	if message.SenderID != userID {
+		return nil, errors.New("unauthorized")
+	}
+
+	// Verify sender is still a member of the conversation
+	conv, err := uc.ConversationRepo.GetConversationByID(message.GetConversationID())
+	if err != nil {
+		return nil, err
+	}
+
+	isMember := false
+	for _, member := range conv.Members {
+		if member.UserID == userID {
+			isMember = true
+			break
 		}
 	}
-	conv.LastMessage = lastMessageText
-	conv.LastMessageTime = &now
-	// Increment unread for other members
+
+	if !isMember {
+		return nil, errors.New("unauthorized")
+	}
+
+	if message.IsDeleted() {
+		return nil, errors.New("message has been deleted")
+	}
+
+	if uc.MessageEditWindow > 0 && time.Since(message.CreatedAt) > uc.MessageEditWindow {
+		return nil, errors.New("edit window has expired")
+	}
+
+	if content == "" && len(message.Attachments) == 0 {
+		return nil, errors.New("content required")
+	}
+
+	if content == message.Content {
+		sender, _ := uc.UserRepo.GetByID(userID)
+		return uc.messageToMap(message, userID, sender), nil
+	}
+
+	// Keep the previous content as a revision
+	revision := entity.MessageRevision{
+		Content:  message.Content,
+		EditedAt: time.Now(),
+	}
+	if err := uc.MessageRepo.EditMessage(messageID, content, revision); err != nil {
+		return nil, err
+	}
+
+	// Reload so reactions or hides added meanwhile are part of the result
+	message, err = uc.MessageRepo.GetMessageByID(messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Refresh the conversation preview when the edited message is the latest one
+	latest, _, err := uc.MessageRepo.GetMessagesPage(message.GetConversationID(), domain.MessageQuery{Limit: 1, SkipDeleted: true})
+	if err == nil && len(latest) > 0 && latest[0].ID == messageID {
+		uc.ConversationRepo.SetLastMessage(message.GetConversationID(), lastMessagePreview(message), message.CreatedAt)
+	}
+
+	sender, _ := uc.UserRepo.GetByID(userID)
+	return uc.messageToMap(message, userID, sender), nil
+}
+
+// DeleteMessage removes a message for everyone (sender only, keeps a tombstone) or hides it for the caller only
+func (uc *ConversationUseCase) DeleteMessage(messageID, userID string, forEveryone bool) (map[string]interface{}, error) {
+	message, err := uc.MessageRepo.GetMessageByID(messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	conversationID := message.GetConversationID()
+	conv, err := uc.ConversationRepo.GetConversationByID(conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	isMember := false
 	for _, member := range conv.Members {
-		if member.UserID != senderID {
-			conv.Unread++
+		if member.UserID == userID {
+			isMember = true
+			break
 		}
 	}
-	uc.ConversationRepo.UpdateConversation(conv)
 
-	// Get created message
-	messages, _ := uc.MessageRepo.GetMessagesByConversationID(conversationID)
-	if len(messages) > 0 {
-		lastMsg := messages[len(messages)-1]
-		sender, _ := uc.UserRepo.GetByID(senderID)
-		return uc.messageToMap(lastMsg, senderID, sender), nil
+	if !isMember {
+		return nil, errors.New("unauthorized")
+	}
+
+	result := map[string]interface{}{
+		"messageId":      messageID,
+		"conversationId": conversationID,
+		"forEveryone":    forEveryone,
+	}
+
+	if !forEveryone {
+		if err := uc.MessageRepo.HideMessageForUser(messageID, userID); err != nil {
+			return nil, err
+		}
+
+		// The caller's own view of the latest message may have changed
+		latest, _, err := uc.MessageRepo.GetMessagesPage(conversationID, domain.MessageQuery{Limit: 1, ViewerID: userID})
+		if err == nil && len(latest) > 0 {
+			result["lastMessage"] = lastMessagePreview(latest[0])
+			result["lastMessageTime"] = latest[0].CreatedAt
+		}
+		return result, nil
+	}
+
+	// Moderators may delete others' messages, but not those of members ranked at or above them
+	if message.SenderID != userID && (!conv.Can(userID, entity.PermissionDeleteOthersMessages) || !conv.Outranks(userID, message.SenderID)) {
+		return nil, errors.New("unauthorized")
+	}
+
+	if message.IsDeleted() {
+		return result, nil
 	}
 
-	return nil, errors.New("failed to create message")
+	deleted, err := uc.MessageRepo.DeleteMessageForEveryone(messageID)
+	if err != nil {
+		return nil, err
+	}
+	if !deleted {
+		// A concurrent request deleted it first and did the rest
+		return result, nil
+	}
+
+	// Members who had not read the message no longer count it as unread
+	if err := uc.ConversationRepo.ForgetUnreadMessage(conversationID, message.SenderID, messageID, message.CreatedAt); err != nil {
+		log.Printf("[ERROR]: unable to update unread counts: %v", err)
+	}
+
+	// Recompute the conversation preview when the deleted message was the latest one still shown,
+	// i.e. only messages already deleted come after it
+	later, _, err := uc.MessageRepo.GetMessagesPage(conversationID, domain.MessageQuery{
+		Limit:       1,
+		SkipDeleted: true,
+		After:       &domain.MessageCursor{CreatedAt: message.CreatedAt, ID: message.ID},
+	})
+	if err == nil && len(later) == 0 {
+		live, _, err := uc.MessageRepo.GetMessagesPage(conversationID, domain.MessageQuery{Limit: 1, SkipDeleted: true})
+		if err == nil {
+			preview, at := deletedMessagePlaceholder, message.CreatedAt
+			if len(live) > 0 {
+				preview, at = lastMessagePreview(live[0]), live[0].CreatedAt
+			} else if latest, _, err := uc.MessageRepo.GetMessagesPage(conversationID, domain.MessageQuery{Limit: 1}); err == nil && len(latest) > 0 {
+				// Nothing left to show: keep the time of the newest tombstone so the conversation keeps its place
+				at = latest[0].CreatedAt
+			}
+			uc.ConversationRepo.SetLastMessage(conversationID, preview, at)
+			result["lastMessage"] = preview
+			result["lastMessageTime"] = at
+		}
+	}
+
+	return result, nil
 }
 
 func (uc *ConversationUseCase) AddReaction(messageID, userID, emoji string) error {
@@ -389,6 +691,10 @@ func (uc *ConversationUseCase) AddReaction(messageID, userID, emoji string) erro
 		return err
 	}
 
+	if message.IsDeleted() {
+		return errors.New("message has been deleted")
+	}
+
 	// Verify user is a member of the conversation
 	conv, err := uc.ConversationRepo.GetConversationByID(message.GetConversationID())
 	if err != nil {
@@ -468,7 +774,91 @@ func (uc *ConversationUseCase) MarkMessageAsRead(messageID, userID string) error
 		return nil
 	}
 
-	return uc.MessageRepo.MarkAsRead(messageID, userID)
+	if err := uc.MessageRepo.MarkAsRead(messageID, userID); err != nil {
+		return err
+	}
+
+	// Reading a message also advances the conversation read cursor
+	_, err = uc.advanceReadCursor(conv, userID, message)
+	return err
+}
+
+// MarkConversationRead advances the user's read cursor up to messageID, or to the latest message when empty
+func (uc *ConversationUseCase) MarkConversationRead(conversationID, userID, messageID string) (map[string]interface{}, error) {
+	conv, err := uc.ConversationRepo.GetConversationByID(conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := conv.GetMember(userID); !ok {
+		return nil, errors.New("unauthorized")
+	}
+
+	var target entity.Message
+	if messageID != "" {
+		target, err = uc.MessageRepo.GetMessageByID(messageID)
+		if err != nil {
+			return nil, err
+		}
+		if target.GetConversationID() != conversationID {
+			return nil, errors.New("message not found")
+		}
+	} else {
+		latest, _, err := uc.MessageRepo.GetMessagesPage(conversationID, domain.MessageQuery{Limit: 1, ViewerID: userID})
+		if err != nil {
+			return nil, err
+		}
+		if len(latest) == 0 {
+			return map[string]interface{}{
+				"conversationId": conversationID,
+				"unread":         0,
+			}, nil
+		}
+		target = latest[0]
+	}
+
+	return uc.advanceReadCursor(conv, userID, target)
+}
+
+// advanceReadCursor moves the member's read cursor forward to target; it never moves backwards
+func (uc *ConversationUseCase) advanceReadCursor(conv entity.Conversation, userID string, target entity.Message) (map[string]interface{}, error) {
+	member, _ := conv.GetMember(userID)
+
+	if member.LastReadAt != nil && target.CreatedAt.Before(*member.LastReadAt) {
+		return map[string]interface{}{
+			"conversationId":    conv.ID,
+			"lastReadMessageId": member.LastReadMessageID,
+			"lastReadAt":        member.LastReadAt,
+			"unread":            member.UnreadCount,
+		}, nil
+	}
+
+	// Messages before the previous cursor, or before the member joined, already count as read
+	previous := &domain.MessageCursor{CreatedAt: member.JoinedAt}
+	if member.LastReadAt != nil {
+		previous = &domain.MessageCursor{CreatedAt: *member.LastReadAt, ID: member.LastReadMessageID}
+	}
+
+	cursor := domain.MessageCursor{CreatedAt: target.CreatedAt, ID: target.ID}
+	if err := uc.MessageRepo.MarkMessagesReadUpTo(conv.ID, userID, previous, cursor); err != nil {
+		return nil, err
+	}
+
+	unread, err := uc.MessageRepo.CountUnread(conv.ID, userID, &cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.ConversationRepo.UpdateReadCursor(conv.ID, userID, target.ID, target.CreatedAt, unread); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"conversationId":    conv.ID,
+		"lastReadMessageId": target.ID,
+		"lastReadAt":        target.CreatedAt,
+		"unread":            unread,
+	}, nil
 }
 
 func (uc *ConversationUseCase) messageToMap(msg entity.Message, currentUserID string, sender entity.User) map[string]interface{} {
@@ -483,6 +873,7 @@ func (uc *ConversationUseCase) messageToMap(msg entity.Message, currentUserID st
 				"content":  replyMsg.Content,
 				"sender":   replySender.FullName,
 				"senderId": replyMsg.SenderID,
+				"deleted":  replyMsg.IsDeleted(),
 			}
 		}
 	}
@@ -492,26 +883,63 @@ func (uc *ConversationUseCase) messageToMap(msg entity.Message, currentUserID st
 	for _, receipt := range msg.ReadReceipts {
 		user, _ := uc.UserRepo.GetByID(receipt.UserID)
 		readReceipts = append(readReceipts, map[string]interface{}{
-			"user_id":    receipt.UserID,
-			"user_name":  user.FullName,
+			"user_id":     receipt.UserID,
+			"user_name":   user.FullName,
 			"user_avatar": user.Avatar,
-			"read_at":    receipt.ReadAt,
+			"read_at":     receipt.ReadAt,
 		})
 	}
 
 	return map[string]interface{}{
-		"id":           msg.ID,
-		"sender":       sender.FullName,
-		"senderId":     msg.SenderID,
-		"type":         msg.Type,
-		"content":      msg.Content,
-		"replyTo":      replyTo,
-		"attachments":  msg.Attachments,
-		"reactions":    msg.Reactions,
-		"readReceipts": readReceipts,
-		"status":       msg.Status,
-		"time":         msg.CreatedAt,
-		"isMe":         msg.SenderID == currentUserID,
+		"id":             msg.ID,
+		"conversationId": msg.GetConversationID(),
+		"sender":         sender.FullName,
+		"senderId":       msg.SenderID,
+		"type":           msg.Type,
+		"content":        msg.Content,
+		"replyTo":        replyTo,
+		"attachments":    msg.Attachments,
+		"reactions":      msg.Reactions,
+		"readReceipts":   readReceipts,
+		"status":         msg.Status,
+		"time":           msg.CreatedAt,
+		"isMe":           msg.SenderID == currentUserID,
+		"edited":         msg.EditedAt != nil,
+		"editedAt":       msg.EditedAt,
+		"deleted":        msg.IsDeleted(),
 	}
 }
 
+// checkNotBlocked fails if either user has blocked the other
+func (uc *ConversationUseCase) checkNotBlocked(userID1, userID2 string) error {
+	blocked, err := uc.FriendRepo.IsBlocked(userID1, userID2)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return errors.New("forbidden: user is blocked")
+	}
+	return nil
+}
+
+const deletedMessagePlaceholder = "Tin nhắn đã được thu hồi"
+
+// lastMessagePreview returns the text shown as a conversation's last message
+func lastMessagePreview(msg entity.Message) string {
+	if msg.IsDeleted() {
+		return deletedMessagePlaceholder
+	}
+	if len(msg.Attachments) > 0 {
+		switch msg.Attachments[0].Type {
+		case "image":
+			return "📷 Hình ảnh"
+		case "video":
+			return "🎥 Video"
+		case "file":
+			return "📎 " + msg.Attachments[0].FileName
+		case "audio":
+			return "🎤 Tin nhắn thoại"
+		}
+	}
+	return msg.Content
+}
@@ -0,0 +1,173 @@
+package usecase
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+)
+
+var testEpoch = time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+
+func newTestConversation(id string, userIDs ...string) entity.Conversation {
+	conv := entity.Conversation{ID: id, Type: entity.ConversationTypeGroup}
+	for i, userID := range userIDs {
+		role := entity.ConversationRoleMember
+		if i == 0 {
+			role = entity.ConversationRoleOwner
+		}
+		conv.Members = append(conv.Members, entity.ConversationMember{UserID: userID, Role: role, JoinedAt: testEpoch.Add(-time.Hour)})
+	}
+	return conv
+}
+
+// newPagingUseCase holds 7 messages in c1, two of them sharing a timestamp, and one in c2
+func newPagingUseCase() (*ConversationUseCase, *memoryMessageRepo) {
+	messages := seedMessages("c1", "u2", testEpoch, 6)
+	tie := messages[3]
+	tie.ID = "c1-m03b"
+	messages = append(messages, tie)
+	messages = append(messages, seedMessages("c2", "u2", testEpoch, 1)...)
+
+	messageRepo := newMemoryMessageRepo(messages...)
+	return &ConversationUseCase{
+		ConversationRepo: newMemoryConversationRepo(newTestConversation("c1", "u1", "u2"), newTestConversation("c2", "u2")),
+		UserRepo:         newMemoryUserRepo(entity.User{ID: "u1"}, entity.User{ID: "u2"}),
+		MessageRepo:      messageRepo,
+	}, messageRepo
+}
+
+func pageIDs(page *MessagePage) []string {
+	ids := make([]string, 0, len(page.Messages))
+	for _, msg := range page.Messages {
+		ids = append(ids, msg["id"].(string))
+	}
+	return ids
+}
+
+var pagingHistory = []string{"c1-m00", "c1-m01", "c1-m02", "c1-m03", "c1-m03b", "c1-m04", "c1-m05"}
+
+func TestGetMessagesPagesBackward(t *testing.T) {
+	uc, _ := newPagingUseCase()
+
+	var pages [][]string
+	before := ""
+	for i := 0; i < 5; i++ {
+		page, err := uc.GetMessages("c1", "u1", before, "", 3)
+		if err != nil {
+			t.Fatalf("GetMessages(before=%q): %v", before, err)
+		}
+		pages = append(pages, pageIDs(page))
+		if !page.HasMore {
+			break
+		}
+		before = page.NextCursor
+	}
+
+	want := [][]string{
+		{"c1-m03b", "c1-m04", "c1-m05"},
+		{"c1-m01", "c1-m02", "c1-m03"},
+		{"c1-m00"},
+	}
+	if !reflect.DeepEqual(pages, want) {
+		t.Errorf("pages = %v, want %v", pages, want)
+	}
+}
+
+func TestGetMessagesPagesForward(t *testing.T) {
+	uc, _ := newPagingUseCase()
+
+	var seen []string
+	after := "c1-m00"
+	for i := 0; i < 5; i++ {
+		page, err := uc.GetMessages("c1", "u1", "", after, 2)
+		if err != nil {
+			t.Fatalf("GetMessages(after=%q): %v", after, err)
+		}
+		seen = append(seen, pageIDs(page)...)
+		if !page.HasMore {
+			break
+		}
+		after = page.NextCursor
+	}
+
+	if !reflect.DeepEqual(seen, pagingHistory[1:]) {
+		t.Errorf("messages after c1-m00 = %v, want %v", seen, pagingHistory[1:])
+	}
+}
+
+func TestGetMessagesTimestampCursor(t *testing.T) {
+	uc, _ := newPagingUseCase()
+
+	// A timestamp has no ID to break ties, so both messages sent at that instant are excluded
+	page, err := uc.GetMessages("c1", "u1", testEpoch.Add(3*time.Minute).Format(time.RFC3339Nano), "", 10)
+	if err != nil {
+		t.Fatalf("GetMessages: %v", err)
+	}
+	if got, want := pageIDs(page), pagingHistory[:3]; !reflect.DeepEqual(got, want) {
+		t.Errorf("messages = %v, want %v", got, want)
+	}
+	if page.HasMore {
+		t.Error("HasMore = true on the first page of history")
+	}
+}
+
+func TestGetMessagesHidesMessagesDeletedForViewer(t *testing.T) {
+	uc, messageRepo := newPagingUseCase()
+	messageRepo.find("c1-m05").DeletedFor = []string{"u1"}
+
+	page, err := uc.GetMessages("c1", "u1", "", "", 2)
+	if err != nil {
+		t.Fatalf("GetMessages: %v", err)
+	}
+	if got, want := pageIDs(page), []string{"c1-m03b", "c1-m04"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("messages = %v, want %v", got, want)
+	}
+}
+
+func TestGetMessagesLimit(t *testing.T) {
+	uc, messageRepo := newPagingUseCase()
+
+	tests := []struct {
+		limit int
+		want  int
+	}{
+		{0, DefaultMessagePageSize},
+		{-1, DefaultMessagePageSize},
+		{20, 20},
+		{MaxMessagePageSize + 1, MaxMessagePageSize},
+	}
+	for _, tt := range tests {
+		if _, err := uc.GetMessages("c1", "u1", "", "", tt.limit); err != nil {
+			t.Fatalf("GetMessages(limit=%d): %v", tt.limit, err)
+		}
+		if messageRepo.lastQuery.Limit != tt.want {
+			t.Errorf("limit %d queried %d messages, want %d", tt.limit, messageRepo.lastQuery.Limit, tt.want)
+		}
+	}
+}
+
+func TestGetMessagesRejects(t *testing.T) {
+	uc, _ := newPagingUseCase()
+
+	tests := []struct {
+		name          string
+		userID        string
+		before, after string
+		want          string
+	}{
+		{"non-member", "u3", "", "", "unauthorized"},
+		{"both cursors", "u1", "c1-m02", "c1-m04", "invalid cursor: before and after cannot be used together"},
+		{"unknown message", "u1", "missing", "", "invalid cursor"},
+		{"message of another conversation", "u1", "", "c2-m00", "invalid cursor"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := uc.GetMessages("c1", tt.userID, tt.before, tt.after, 10)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
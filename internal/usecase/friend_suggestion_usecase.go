@@ -0,0 +1,170 @@
+package usecase
+
+import (
+	"sort"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+)
+
+const (
+	DefaultSuggestionLimit = 20
+	MaxSuggestionLimit     = 50
+	mutualFriendSampleSize = 3
+)
+
+type friendSuggestion struct {
+	userID       string
+	mutualCount  int
+	mutualSample []string
+	sharedGroups int
+}
+
+// GetFriendSuggestions ranks users who are not friends yet by mutual friends, then by shared groups.
+// Blocked users and users with a pending request in either direction are left out.
+func (uc *FriendUseCase) GetFriendSuggestions(userID string, limit int) ([]map[string]interface{}, error) {
+	if limit <= 0 {
+		limit = DefaultSuggestionLimit
+	}
+	if limit > MaxSuggestionLimit {
+		limit = MaxSuggestionLimit
+	}
+
+	friendIDs, err := uc.FriendRepo.GetFriendUserIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	excludedIDs, err := uc.suggestionExclusions(userID, friendIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	mutualCounts, err := uc.FriendRepo.GetMutualFriendCounts(friendIDs, excludedIDs, mutualFriendSampleSize)
+	if err != nil {
+		return nil, err
+	}
+
+	sharedGroups, err := uc.ConversationRepo.CountSharedGroups(userID, excludedIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := make(map[string]*friendSuggestion, len(mutualCounts)+len(sharedGroups))
+	for _, mutual := range mutualCounts {
+		suggestions[mutual.UserID] = &friendSuggestion{
+			userID:       mutual.UserID,
+			mutualCount:  mutual.Count,
+			mutualSample: mutual.Sample,
+		}
+	}
+	for candidateID, count := range sharedGroups {
+		suggestion, ok := suggestions[candidateID]
+		if !ok {
+			suggestion = &friendSuggestion{userID: candidateID}
+			suggestions[candidateID] = suggestion
+		}
+		suggestion.sharedGroups = count
+	}
+
+	ranked := make([]*friendSuggestion, 0, len(suggestions))
+	for _, suggestion := range suggestions {
+		ranked = append(ranked, suggestion)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].mutualCount != ranked[j].mutualCount {
+			return ranked[i].mutualCount > ranked[j].mutualCount
+		}
+		if ranked[i].sharedGroups != ranked[j].sharedGroups {
+			return ranked[i].sharedGroups > ranked[j].sharedGroups
+		}
+		return ranked[i].userID < ranked[j].userID
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	if len(ranked) == 0 {
+		return []map[string]interface{}{}, nil
+	}
+
+	// Candidates and the mutual friends explaining them are loaded in a single query
+	userIDs := make([]string, 0, len(ranked)*(mutualFriendSampleSize+1))
+	for _, suggestion := range ranked {
+		userIDs = append(userIDs, suggestion.userID)
+		userIDs = append(userIDs, suggestion.mutualSample...)
+	}
+	users, err := uc.UserRepo.GetUsersByIDs(userIDs)
+	if err != nil {
+		return nil, err
+	}
+	usersByID := make(map[string]entity.User, len(users))
+	for _, user := range users {
+		usersByID[user.ID] = user
+	}
+
+	result := make([]map[string]interface{}, 0, len(ranked))
+	for _, suggestion := range ranked {
+		user, ok := usersByID[suggestion.userID]
+		if !ok {
+			continue
+		}
+
+		mutualFriends := make([]map[string]interface{}, 0, len(suggestion.mutualSample))
+		for _, mutualID := range suggestion.mutualSample {
+			if mutual, ok := usersByID[mutualID]; ok {
+				mutualFriend := map[string]interface{}{
+					"id":   mutual.ID,
+					"name": mutual.FullName,
+				}
+				// Mutual friends are friends of userID, suggested users are not
+				if profileAccessFor(mutual, userID, true).avatar {
+					mutualFriend["avatar"] = mutual.Avatar
+				}
+				mutualFriends = append(mutualFriends, mutualFriend)
+			}
+		}
+
+		suggested := map[string]interface{}{
+			"id":                user.ID,
+			"name":              user.FullName,
+			"mutualFriendCount": suggestion.mutualCount,
+			"mutualFriends":     mutualFriends,
+			"sharedGroupCount":  suggestion.sharedGroups,
+		}
+		access := profileAccessFor(user, userID, false)
+		if access.email {
+			suggested["email"] = user.Email
+		}
+		if access.avatar {
+			suggested["avatar"] = user.Avatar
+		}
+		result = append(result, suggested)
+	}
+
+	return result, nil
+}
+
+// suggestionExclusions lists the user, their friends, blocked users and users with a pending request
+func (uc *FriendUseCase) suggestionExclusions(userID string, friendIDs []string) ([]string, error) {
+	excludedIDs := append([]string{userID}, friendIDs...)
+
+	blockedIDs, err := uc.FriendRepo.GetBlockedUserIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+	excludedIDs = append(excludedIDs, blockedIDs...)
+
+	pending, err := uc.FriendRepo.GetPendingRequestsByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	sent, err := uc.FriendRepo.GetSentRequestsByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, request := range append(pending, sent...) {
+		excludedIDs = append(excludedIDs, request.OtherUserID(userID))
+	}
+
+	return excludedIDs, nil
+}
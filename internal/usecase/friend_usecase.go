@@ -2,109 +2,125 @@ package usecase
 
 import (
 	"errors"
+	"time"
 
 	"github.com/TomTom2k/chat-app/server/internal/domain"
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
 )
 
 type FriendUseCase struct {
-	UserRepo domain.UserRepository
-	Hub      interface {
-		IsUserOnline(userID string) bool
+	UserRepo         domain.UserRepository
+	FriendRepo       domain.FriendRepository
+	ConversationRepo domain.ConversationRepository
+	Hub              interface {
+		ConnectionState(userID string) string
 	}
 }
 
 func (uc *FriendUseCase) GetFriends(userID string) ([]map[string]interface{}, error) {
-	user, err := uc.UserRepo.GetByID(userID)
+	friendIDs, err := uc.FriendRepo.GetFriendUserIDs(userID)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(user.Friends) == 0 {
+	if len(friendIDs) == 0 {
 		return []map[string]interface{}{}, nil
 	}
 
 	// Get all friend users
-	friendUsers, err := uc.UserRepo.GetUsersByIDs(user.Friends)
+	friendUsers, err := uc.UserRepo.GetUsersByIDs(friendIDs)
 	if err != nil {
 		return nil, err
 	}
 
+	now := time.Now()
 	result := make([]map[string]interface{}, 0)
 	for _, friendUser := range friendUsers {
-		// Check online status from Hub if available, otherwise use database status
-		isOnline := friendUser.Online
-		if uc.Hub != nil {
-			isOnline = uc.Hub.IsUserOnline(friendUser.ID)
-		}
-		
-		status := "Offline"
-		if isOnline {
-			status = "Online"
-		}
-		
-		result = append(result, map[string]interface{}{
+		// A hidden online status shows as offline
+		access := profileAccessFor(friendUser, userID, true)
+
+		friend := map[string]interface{}{
 			"id":     friendUser.ID,
 			"name":   friendUser.FullName,
-			"status": status,
-			"online": isOnline,
-			"avatar": friendUser.Avatar,
-			"email":  friendUser.Email,
-		})
+			"status": "Offline",
+			"online": false,
+		}
+		if setPresenceFields(friend, friendUser, connectionState(uc.Hub, friendUser), access, now) != entity.PresenceOffline {
+			friend["status"] = "Online"
+		}
+		if access.avatar {
+			friend["avatar"] = friendUser.Avatar
+		}
+		if access.email {
+			friend["email"] = friendUser.Email
+		}
+		result = append(result, friend)
 	}
 
 	return result, nil
 }
 
 func (uc *FriendUseCase) AddFriend(userID1, userID2 string) error {
+	if userID1 == userID2 {
+		return errors.New("cannot add yourself")
+	}
+
+	// Unverified accounts cannot reach out to other users
+	sender, err := uc.UserRepo.GetByID(userID1)
+	if err != nil {
+		return err
+	}
+	if !sender.IsEmailVerified() {
+		return errors.New("forbidden: email not verified")
+	}
+
 	// Verify user exists
-	_, err := uc.UserRepo.GetByID(userID2)
+	receiver, err := uc.UserRepo.GetByID(userID2)
 	if err != nil {
 		return errors.New("user not found")
 	}
 
-	// Get current user to check existing relationships
-	user1, err := uc.UserRepo.GetByID(userID1)
+	// Blocks in either direction prevent friend requests
+	blocked, err := uc.FriendRepo.IsBlocked(userID1, userID2)
 	if err != nil {
 		return err
 	}
+	if blocked {
+		return errors.New("forbidden: user is blocked")
+	}
 
-	// Check if already friends
-	for _, friendID := range user1.Friends {
-		if friendID == userID2 {
-			return errors.New("friendship already exists")
-		}
+	existing, err := uc.FriendRepo.GetFriendByUserIDs(userID1, userID2)
+	if err != nil {
+		return err
 	}
 
-	// Check if already sent request
-	for _, sentID := range user1.SentRequests {
-		if sentID == userID2 {
+	switch existing.Status {
+	case entity.FriendStatusAccepted:
+		return errors.New("friendship already exists")
+	case entity.FriendStatusPending:
+		if existing.UserID1 == userID1 {
 			return errors.New("friend request already sent")
 		}
+		// userID2 already asked: sending a request back accepts it
+		return uc.FriendRepo.UpdateFriendStatus(existing.ID, entity.FriendStatusPending, entity.FriendStatusAccepted)
 	}
 
-	// Check if there's a pending request from userID2
-	for _, pendingID := range user1.PendingRequests {
-		if pendingID == userID2 {
-			// Accept the request: add to friends and remove from pending/sent
-			err = uc.UserRepo.AddFriend(userID1, userID2)
-			if err != nil {
-				return err
-			}
-			// Remove from pending/sent requests
-			err = uc.UserRepo.RemovePendingRequest(userID1, userID2)
-			if err != nil {
-				return err
-			}
-			err = uc.UserRepo.RemoveSentRequest(userID2, userID1)
-			if err != nil {
-				return err
-			}
-			return nil
-		}
+	// Answering a request is always possible, a new one depends on the receiver's settings
+	if err := uc.checkFriendRequestAllowed(userID1, receiver); err != nil {
+		return err
+	}
+
+	if existing.Status == entity.FriendStatusRejected {
+		// A rejected pair can try again, the request starts over with a new timestamp
+		return uc.FriendRepo.ReopenFriendRequest(existing.ID, userID1, userID2)
 	}
 
 	// Create new friend request
-	return uc.UserRepo.AddSentRequest(userID1, userID2)
+	return uc.FriendRepo.CreateFriend(entity.Friend{
+		UserID1: userID1,
+		UserID2: userID2,
+		Status:  entity.FriendStatusPending,
+	})
 }
 
 func (uc *FriendUseCase) DeleteFriend(friendID, userID string) error {
@@ -115,172 +131,260 @@ func (uc *FriendUseCase) DeleteFriend(friendID, userID string) error {
 	}
 
 	// Verify user is friend
-	user, err := uc.UserRepo.GetByID(userID)
+	friendship, err := uc.FriendRepo.GetFriendByUserIDs(userID, friendID)
 	if err != nil {
 		return err
 	}
 
-	isFriend := false
-	for _, friendIDInList := range user.Friends {
-		if friendIDInList == friendID {
-			isFriend = true
-			break
-		}
-	}
-
-	if !isFriend {
+	if friendship.Status != entity.FriendStatusAccepted {
 		return errors.New("unauthorized")
 	}
 
-	return uc.UserRepo.RemoveFriend(userID, friendID)
+	return uc.FriendRepo.DeleteFriend(friendship.ID)
 }
 
-func (uc *FriendUseCase) SearchUsers(query string) ([]map[string]interface{}, error) {
+func (uc *FriendUseCase) SearchUsers(query, userID string) ([]map[string]interface{}, error) {
 	users, err := uc.UserRepo.SearchUsers(query)
 	if err != nil {
 		return nil, err
 	}
 
+	// Users on either side of a block never see each other in search
+	blockedIDs, err := uc.FriendRepo.GetBlockedUserIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+	hidden := make(map[string]bool, len(blockedIDs))
+	for _, id := range blockedIDs {
+		hidden[id] = true
+	}
+
+	friendIDs, err := uc.FriendRepo.GetFriendUserIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+	friends := make(map[string]bool, len(friendIDs))
+	for _, id := range friendIDs {
+		friends[id] = true
+	}
+
 	result := make([]map[string]interface{}, 0)
 	for _, user := range users {
-		result = append(result, map[string]interface{}{
+		if hidden[user.ID] {
+			continue
+		}
+
+		access := profileAccessFor(user, userID, friends[user.ID])
+		if !access.email && !matchesVisibleFields(user, query) {
+			continue
+		}
+
+		found := map[string]interface{}{
 			"id":        user.ID,
 			"name":      user.FullName,
-			"email":     user.Email,
-			"avatar":    user.Avatar,
-			"online":    user.Online,
+			"online":    access.onlineStatus && user.VisiblePresence(connectionState(uc.Hub, user)) != entity.PresenceOffline,
 			"createdAt": user.CreatedAt,
 			"updatedAt": user.UpdatedAt,
-		})
+		}
+		if access.email {
+			found["email"] = user.Email
+		}
+		if access.avatar {
+			found["avatar"] = user.Avatar
+		}
+		result = append(result, found)
 	}
 
 	return result, nil
 }
 
+// GetPendingRequests returns the requests received by userID, newest first
 func (uc *FriendUseCase) GetPendingRequests(userID string) ([]map[string]interface{}, error) {
-	user, err := uc.UserRepo.GetByID(userID)
+	requests, err := uc.FriendRepo.GetPendingRequestsByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return uc.requestsToMaps(requests, userID)
+}
+
+// GetSentRequests returns the requests sent by userID, newest first
+func (uc *FriendUseCase) GetSentRequests(userID string) ([]map[string]interface{}, error) {
+	requests, err := uc.FriendRepo.GetSentRequestsByUserID(userID)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(user.PendingRequests) == 0 {
+	return uc.requestsToMaps(requests, userID)
+}
+
+// requestsToMaps describes each request by the user on the other side
+func (uc *FriendUseCase) requestsToMaps(requests []entity.Friend, userID string) ([]map[string]interface{}, error) {
+	if len(requests) == 0 {
 		return []map[string]interface{}{}, nil
 	}
 
-	// Get all users who sent requests
-	requestUsers, err := uc.UserRepo.GetUsersByIDs(user.PendingRequests)
+	userIDs := make([]string, 0, len(requests))
+	for _, request := range requests {
+		userIDs = append(userIDs, request.OtherUserID(userID))
+	}
+
+	requestUsers, err := uc.UserRepo.GetUsersByIDs(userIDs)
 	if err != nil {
 		return nil, err
 	}
+	usersByID := make(map[string]entity.User, len(requestUsers))
+	for _, requestUser := range requestUsers {
+		usersByID[requestUser.ID] = requestUser
+	}
 
 	result := make([]map[string]interface{}, 0)
-	for _, requestUser := range requestUsers {
-		result = append(result, map[string]interface{}{
-			"id":        requestUser.ID, // Use senderUserID as ID for accept/reject
+	for _, request := range requests {
+		requestUser, ok := usersByID[request.OtherUserID(userID)]
+		if !ok {
+			continue
+		}
+		// Not friends yet: email and avatar follow what the user shares with strangers
+		access := profileAccessFor(requestUser, userID, false)
+		requestMap := map[string]interface{}{
+			"id":        request.ID,
 			"userId":    requestUser.ID,
 			"name":      requestUser.FullName,
-			"email":     requestUser.Email,
-			"avatar":    requestUser.Avatar,
-			"createdAt": user.UpdatedAt, // Use updated_at as approximate time
-		})
+			"createdAt": request.CreatedAt,
+		}
+		if access.email {
+			requestMap["email"] = requestUser.Email
+		}
+		if access.avatar {
+			requestMap["avatar"] = requestUser.Avatar
+		}
+		result = append(result, requestMap)
 	}
 
 	return result, nil
 }
 
-func (uc *FriendUseCase) GetSentRequests(userID string) ([]map[string]interface{}, error) {
-	user, err := uc.UserRepo.GetByID(userID)
+// AcceptFriendRequest accepts a request addressed to userID
+func (uc *FriendUseCase) AcceptFriendRequest(requestID, userID string) error {
+	request, err := uc.findIncomingRequest(requestID, userID)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	if len(user.SentRequests) == 0 {
-		return []map[string]interface{}{}, nil
+	return uc.FriendRepo.UpdateFriendStatus(request.ID, entity.FriendStatusPending, entity.FriendStatusAccepted)
+}
+
+// RejectFriendRequest declines a request addressed to userID. The sender may ask again later.
+func (uc *FriendUseCase) RejectFriendRequest(requestID, userID string) error {
+	request, err := uc.findIncomingRequest(requestID, userID)
+	if err != nil {
+		return err
 	}
 
-	// Get all users who received requests
-	requestUsers, err := uc.UserRepo.GetUsersByIDs(user.SentRequests)
+	return uc.FriendRepo.UpdateFriendStatus(request.ID, entity.FriendStatusPending, entity.FriendStatusRejected)
+}
+
+// findIncomingRequest resolves requestID to a pending request received by userID.
+// Older clients send the sender's user ID instead of the request ID, so both are accepted.
+func (uc *FriendUseCase) findIncomingRequest(requestID, userID string) (entity.Friend, error) {
+	request, err := uc.FriendRepo.GetFriendByID(requestID)
 	if err != nil {
-		return nil, err
+		request, err = uc.FriendRepo.GetFriendByUserIDs(requestID, userID)
+		if err != nil {
+			return entity.Friend{}, err
+		}
 	}
 
-	result := make([]map[string]interface{}, 0)
-	for _, requestUser := range requestUsers {
-		result = append(result, map[string]interface{}{
-			"id":        requestUser.ID, // Use receiverUserID as ID
-			"userId":    requestUser.ID,
-			"name":      requestUser.FullName,
-			"email":     requestUser.Email,
-			"avatar":    requestUser.Avatar,
-			"createdAt": user.UpdatedAt, // Use updated_at as approximate time
-		})
+	if request.Status != entity.FriendStatusPending || request.UserID2 != userID {
+		return entity.Friend{}, errors.New("request is not pending")
 	}
 
-	return result, nil
+	return request, nil
 }
 
-func (uc *FriendUseCase) AcceptFriendRequest(senderUserID, userID string) error {
-	user, err := uc.UserRepo.GetByID(userID)
-	if err != nil {
-		return err
+// BlockUser blocks targetUserID: any friendship or pending request between the two is removed
+func (uc *FriendUseCase) BlockUser(userID, targetUserID string) error {
+	if userID == targetUserID {
+		return errors.New("cannot block yourself")
 	}
 
-	// Verify this is a pending request
-	isPending := false
-	for _, pendingID := range user.PendingRequests {
-		if pendingID == senderUserID {
-			isPending = true
-			break
-		}
+	if _, err := uc.UserRepo.GetByID(targetUserID); err != nil {
+		return errors.New("user not found")
 	}
 
-	if !isPending {
-		return errors.New("request is not pending")
+	// The unique block index still catches two requests racing past this check
+	if _, err := uc.FriendRepo.GetBlock(userID, targetUserID); err == nil {
+		return errors.New("user already blocked")
 	}
 
-	// Accept: add to friends and remove from pending/sent
-	err = uc.UserRepo.AddFriend(userID, senderUserID)
+	err := uc.FriendRepo.CreateFriend(entity.Friend{
+		UserID1: userID,
+		UserID2: targetUserID,
+		Status:  entity.FriendStatusBlocked,
+	})
 	if err != nil {
 		return err
 	}
 
-	// Remove from pending/sent requests
-	err = uc.UserRepo.RemovePendingRequest(userID, senderUserID)
+	// Remove the friendship or pending request between the two
+	friendship, err := uc.FriendRepo.GetFriendByUserIDs(userID, targetUserID)
 	if err != nil {
 		return err
 	}
-	err = uc.UserRepo.RemoveSentRequest(senderUserID, userID)
-	if err != nil {
-		return err
+	if friendship.ID != "" {
+		return uc.FriendRepo.DeleteFriend(friendship.ID)
 	}
 
 	return nil
 }
 
-func (uc *FriendUseCase) RejectFriendRequest(senderUserID, userID string) error {
-	user, err := uc.UserRepo.GetByID(userID)
+func (uc *FriendUseCase) UnblockUser(userID, targetUserID string) error {
+	block, err := uc.FriendRepo.GetBlock(userID, targetUserID)
 	if err != nil {
 		return err
 	}
 
-	// Verify this is a pending request
-	isPending := false
-	for _, pendingID := range user.PendingRequests {
-		if pendingID == senderUserID {
-			isPending = true
-			break
-		}
+	return uc.FriendRepo.DeleteFriend(block.ID)
+}
+
+// GetBlockedUsers returns the users blocked by userID
+func (uc *FriendUseCase) GetBlockedUsers(userID string) ([]map[string]interface{}, error) {
+	blocks, err := uc.FriendRepo.GetBlocksByUserID(userID)
+	if err != nil {
+		return nil, err
 	}
 
-	if !isPending {
-		return errors.New("request is not pending")
+	if len(blocks) == 0 {
+		return []map[string]interface{}{}, nil
+	}
+
+	blockedAt := make(map[string]interface{}, len(blocks))
+	userIDs := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		userIDs = append(userIDs, block.UserID2)
+		blockedAt[block.UserID2] = block.CreatedAt
 	}
 
-	// Reject: remove from pending/sent requests
-	err = uc.UserRepo.RemovePendingRequest(userID, senderUserID)
+	blockedUsers, err := uc.UserRepo.GetUsersByIDs(userIDs)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	result := make([]map[string]interface{}, 0)
+	for _, blockedUser := range blockedUsers {
+		// Blocking ends a friendship: the avatar follows what the user shares with strangers
+		access := profileAccessFor(blockedUser, userID, false)
+		blocked := map[string]interface{}{
+			"id":        blockedUser.ID,
+			"userId":    blockedUser.ID,
+			"name":      blockedUser.FullName,
+			"blockedAt": blockedAt[blockedUser.ID],
+		}
+		if access.avatar {
+			blocked["avatar"] = blockedUser.Avatar
+		}
+		result = append(result, blocked)
 	}
-	err = uc.UserRepo.RemoveSentRequest(senderUserID, userID)
-	return err
+
+	return result, nil
 }
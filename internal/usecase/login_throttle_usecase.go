@@ -0,0 +1,283 @@
+package usecase
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+	"github.com/TomTom2k/chat-app/server/pkg/utils"
+)
+
+const (
+	loginFreeAttempts          = 2           // Số lần sai chưa bị trì hoãn
+	loginBackoffBase           = time.Second // Trì hoãn sau lần sai đầu tiên vượt loginFreeAttempts, nhân đôi mỗi lần
+	DefaultSecurityEventsLimit = 50
+	MaxSecurityEventsLimit     = 200
+)
+
+// LoginPolicy limits failed logins per account and per IP address
+type LoginPolicy struct {
+	MaxAccountFailures int           // Số lần sai liên tiếp trước khi khóa email
+	MaxIPFailures      int           // Số lần sai từ một IP (mọi email) trước khi khóa IP
+	LockoutDuration    time.Duration // Thời gian khóa
+	FailureWindow      time.Duration // Bộ đếm được reset nếu không có lần sai nào trong khoảng này
+}
+
+// LoginThrottledError is returned while an account or IP is backing off or locked out.
+// It reads the same in both cases and for unknown emails, so it reveals nothing about the account.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return "too many login attempts, try again later"
+}
+
+var (
+	dummyPasswordOnce sync.Once
+	dummyPassword     string
+)
+
+// loginAttempt is an attempt counted against the throttle of an email or an IP
+type loginAttempt struct {
+	throttle entity.LoginThrottle
+	limit    int
+	backoff  bool
+}
+
+// reserveLoginAttempt counts the attempt for the email and the IP before anything is checked, and refuses it
+// while either is delayed or locked. Each parallel attempt is decided from the count it got, so a burst of
+// requests cannot pass before the first failure is recorded. Refused attempts are not counted.
+func (u *UserUseCase) reserveLoginAttempt(email, ip string, now time.Time) ([]loginAttempt, error) {
+	// Many users can share one IP behind a NAT, so only the account counter backs off
+	counters := []struct {
+		kind, subject string
+		limit         int
+		backoff       bool
+	}{
+		{entity.LoginThrottleAccount, email, u.LoginPolicy.MaxAccountFailures, true},
+		{entity.LoginThrottleIP, ip, u.LoginPolicy.MaxIPFailures, false},
+	}
+
+	attempts := make([]loginAttempt, 0, len(counters))
+	var retryAfter time.Duration
+	for _, counter := range counters {
+		if counter.subject == "" {
+			continue
+		}
+
+		throttle, err := u.ThrottleRepo.RecordAttempt(counter.kind, counter.subject, now, u.LoginPolicy.FailureWindow, u.loginDelays(counter.limit, counter.backoff))
+		if err != nil {
+			u.releaseLoginAttempts(attempts)
+			return nil, err
+		}
+		if throttle.Allowed {
+			attempts = append(attempts, loginAttempt{throttle: throttle, limit: counter.limit, backoff: counter.backoff})
+			continue
+		}
+
+		wait := throttle.RetryAfter(now)
+		if next := throttle.LastFailureAt.Add(u.loginDelay(throttle.Failures, counter.limit, counter.backoff)).Sub(now); next > wait {
+			wait = next
+		}
+		// A refused attempt is never let through, even if the wait rounds down to nothing
+		if wait < time.Second {
+			wait = time.Second
+		}
+		if wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+
+	if retryAfter > 0 {
+		u.releaseLoginAttempts(attempts)
+		return nil, &LoginThrottledError{RetryAfter: retryAfter}
+	}
+	return attempts, nil
+}
+
+// loginDelays lists the wait before the next attempt by number of failures, up to the limit
+func (u *UserUseCase) loginDelays(limit int, backoff bool) []time.Duration {
+	delays := make([]time.Duration, 0, limit+1)
+	for failures := 0; failures <= limit; failures++ {
+		delays = append(delays, u.loginDelay(failures, limit, backoff))
+	}
+	return delays
+}
+
+// releaseLoginAttempts uncounts attempts that did not fail, e.g. a correct password awaiting its second factor
+func (u *UserUseCase) releaseLoginAttempts(attempts []loginAttempt) {
+	for _, attempt := range attempts {
+		if err := u.ThrottleRepo.ReleaseAttempt(attempt.throttle.ID); err != nil {
+			log.Printf("[ERROR]: unable to release login attempt: %v", err)
+		}
+	}
+}
+
+// recordLoginFailure keeps the counted attempts as failures, delays the next attempt on the account
+// exponentially and locks out once a limit is reached. Lockouts are audited.
+func (u *UserUseCase) recordLoginFailure(attempts []loginAttempt, email, ip, userID string, now time.Time) {
+	for _, attempt := range attempts {
+		throttle := attempt.throttle
+		delay := u.loginDelay(throttle.Failures, attempt.limit, attempt.backoff)
+		if delay == 0 {
+			continue
+		}
+		lockedUntil := now.Add(delay)
+		if err := u.ThrottleRepo.LockUntil(throttle.ID, lockedUntil); err != nil {
+			log.Printf("[ERROR]: unable to delay logins: %v", err)
+			continue
+		}
+
+		if throttle.Failures == attempt.limit {
+			event := entity.SecurityEvent{
+				Type:        entity.SecurityEventLoginLocked,
+				Failures:    throttle.Failures,
+				LockedUntil: &lockedUntil,
+				CreatedAt:   now,
+			}
+			if throttle.Kind == entity.LoginThrottleAccount {
+				event.Email = email
+				event.UserID = userID
+			}
+			event.IP = ip
+			if err := u.SecurityEventRepo.CreateEvent(event); err != nil {
+				log.Printf("[ERROR]: unable to audit login lockout: %v", err)
+			}
+		}
+	}
+}
+
+// recordLoginSuccess forgets the failures of the email. The attempt is uncounted from the IP,
+// whose earlier failures are kept so one valid account cannot be used to reset it.
+func (u *UserUseCase) recordLoginSuccess(attempts []loginAttempt, email string) {
+	ipAttempts := make([]loginAttempt, 0, len(attempts))
+	for _, attempt := range attempts {
+		if attempt.throttle.Kind == entity.LoginThrottleIP {
+			ipAttempts = append(ipAttempts, attempt)
+		}
+	}
+	u.releaseLoginAttempts(ipAttempts)
+	u.clearAccountThrottle(email)
+}
+
+// throttledCheck runs check, e.g. a second factor or password, under the login throttle of the user's
+// email and the client IP: it is refused while either is locked and each failure counts as a failed login
+func (u *UserUseCase) throttledCheck(user entity.User, ip string, check func(now time.Time) error) error {
+	now := time.Now()
+	email := normalizeEmail(user.Email)
+	attempts, err := u.reserveLoginAttempt(email, ip, now)
+	if err != nil {
+		return err
+	}
+	if err := check(now); err != nil {
+		u.recordLoginFailure(attempts, email, ip, user.ID, now)
+		return err
+	}
+	u.recordLoginSuccess(attempts, email)
+	return nil
+}
+
+// loginDelay is zero for the first few failures, then doubles with each failure until
+// the limit is reached and the full lockout applies. Without backoff only the lockout applies.
+func (u *UserUseCase) loginDelay(failures, limit int, backoff bool) time.Duration {
+	if failures >= limit {
+		return u.LoginPolicy.LockoutDuration
+	}
+	if !backoff || failures <= loginFreeAttempts {
+		return 0
+	}
+
+	delay := loginBackoffBase
+	for i := loginFreeAttempts + 1; i < failures && delay < u.LoginPolicy.LockoutDuration; i++ {
+		delay *= 2
+	}
+	if delay > u.LoginPolicy.LockoutDuration {
+		delay = u.LoginPolicy.LockoutDuration
+	}
+	return delay
+}
+
+// clearAccountThrottle forgets the failures of the email after a successful login
+func (u *UserUseCase) clearAccountThrottle(email string) {
+	if _, err := u.ThrottleRepo.ClearThrottles(entity.LoginThrottleID(entity.LoginThrottleAccount, email)); err != nil {
+		log.Printf("[ERROR]: unable to reset failed logins: %v", err)
+	}
+}
+
+// burnPasswordCheck spends the time of a bcrypt comparison when the email is unknown,
+// so response times do not tell registered emails apart
+func burnPasswordCheck(password string) {
+	dummyPasswordOnce.Do(func() {
+		dummyPassword, _ = utils.HashPassword("dummy-password-for-timing")
+	})
+	utils.CheckPassword(password, dummyPassword)
+}
+
+// IsAdmin reports whether the user holds the admin role
+func (u *UserUseCase) IsAdmin(userID string) bool {
+	user, err := u.Repo.GetByID(userID)
+	return err == nil && user.Role == entity.UserRoleAdmin
+}
+
+// UnlockLogin lifts the lockout of an email and/or an IP address and records who did it
+func (u *UserUseCase) UnlockLogin(adminID, email, ip string) (int64, error) {
+	email = normalizeEmail(email)
+	ip = strings.TrimSpace(ip)
+	if email == "" && ip == "" {
+		return 0, errors.New("email or ip is required")
+	}
+
+	cleared, err := u.ThrottleRepo.ClearThrottles(loginThrottleIDs(email, ip)...)
+	if err != nil {
+		return 0, err
+	}
+
+	event := entity.SecurityEvent{
+		Type:      entity.SecurityEventLoginUnlocked,
+		Email:     email,
+		IP:        ip,
+		ActorID:   adminID,
+		CreatedAt: time.Now(),
+	}
+	if email != "" {
+		if user, _ := u.Repo.GetByEmail(email); user.ID != "" {
+			event.UserID = user.ID
+		}
+	}
+	if err := u.SecurityEventRepo.CreateEvent(event); err != nil {
+		return 0, err
+	}
+
+	return cleared, nil
+}
+
+// GetSecurityEvents returns the latest lockout and unlock events, optionally for one email or IP
+func (u *UserUseCase) GetSecurityEvents(email, ip string, limit int) ([]entity.SecurityEvent, error) {
+	if limit <= 0 {
+		limit = DefaultSecurityEventsLimit
+	}
+	if limit > MaxSecurityEventsLimit {
+		limit = MaxSecurityEventsLimit
+	}
+	return u.SecurityEventRepo.GetEvents(normalizeEmail(email), strings.TrimSpace(ip), limit)
+}
+
+func loginThrottleIDs(email, ip string) []string {
+	ids := make([]string, 0, 2)
+	if email != "" {
+		ids = append(ids, entity.LoginThrottleID(entity.LoginThrottleAccount, email))
+	}
+	if ip != "" {
+		ids = append(ids, entity.LoginThrottleID(entity.LoginThrottleIP, ip))
+	}
+	return ids
+}
+
+// normalizeEmail makes "User@Example.com " and "user@example.com" the same account and share one counter
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
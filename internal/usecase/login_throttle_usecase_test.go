@@ -0,0 +1,254 @@
+package usecase
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+)
+
+func TestLoginDelay(t *testing.T) {
+	u := &UserUseCase{LoginPolicy: LoginPolicy{
+		MaxAccountFailures: 10,
+		MaxIPFailures:      50,
+		LockoutDuration:    15 * time.Second,
+	}}
+
+	tests := []struct {
+		name     string
+		failures int
+		limit    int
+		backoff  bool
+		want     time.Duration
+	}{
+		{"no failures", 0, 10, true, 0},
+		{"free attempts", 2, 10, true, 0},
+		{"first backoff", 3, 10, true, time.Second},
+		{"doubles", 4, 10, true, 2 * time.Second},
+		{"doubles again", 5, 10, true, 4 * time.Second},
+		{"keeps doubling", 6, 10, true, 8 * time.Second},
+		{"capped at the lockout", 7, 10, true, 15 * time.Second},
+		{"still capped below the limit", 9, 10, true, 15 * time.Second},
+		{"locked out at the limit", 10, 10, true, 15 * time.Second},
+		{"locked out above the limit", 12, 10, true, 15 * time.Second},
+		{"no backoff below the limit", 9, 10, false, 0},
+		{"lockout without backoff", 50, 50, false, 15 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := u.loginDelay(tt.failures, tt.limit, tt.backoff); got != tt.want {
+				t.Errorf("loginDelay(%d, %d, %v) = %v, want %v", tt.failures, tt.limit, tt.backoff, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginDelayLongLockout(t *testing.T) {
+	u := &UserUseCase{LoginPolicy: LoginPolicy{LockoutDuration: time.Hour}}
+	// 1s doubles 20 times past the free attempts; it must stop at the lockout instead of overflowing
+	if got := u.loginDelay(25, 100, true); got != time.Hour {
+		t.Errorf("loginDelay(25) = %v, want %v", got, time.Hour)
+	}
+	if got := u.loginDelay(8, 100, true); got != 32*time.Second {
+		t.Errorf("loginDelay(8) = %v, want %v", got, 32*time.Second)
+	}
+}
+
+func TestLoginDelays(t *testing.T) {
+	u := &UserUseCase{LoginPolicy: LoginPolicy{LockoutDuration: 15 * time.Second}}
+
+	got := u.loginDelays(5, true)
+	want := []time.Duration{0, 0, 0, time.Second, 2 * time.Second, 15 * time.Second}
+	if len(got) != len(want) {
+		t.Fatalf("loginDelays(5) has %d entries, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("loginDelays(5)[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+// memoryThrottleRepo follows the contract of LoginThrottleRepository, with a mutex standing in for the atomic update
+type memoryThrottleRepo struct {
+	mu        sync.Mutex
+	throttles map[string]*entity.LoginThrottle
+}
+
+func newMemoryThrottleRepo() *memoryThrottleRepo {
+	return &memoryThrottleRepo{throttles: make(map[string]*entity.LoginThrottle)}
+}
+
+func (r *memoryThrottleRepo) RecordAttempt(kind, subject string, at time.Time, window time.Duration, delays []time.Duration) (entity.LoginThrottle, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := entity.LoginThrottleID(kind, subject)
+	throttle, ok := r.throttles[id]
+	if !ok {
+		throttle = &entity.LoginThrottle{ID: id, Kind: kind, Subject: subject}
+		r.throttles[id] = throttle
+	}
+
+	failures := throttle.Failures
+	if !throttle.ExpiresAt.After(at) {
+		failures = 0
+	}
+	wait := delays[len(delays)-1]
+	if failures < len(delays) {
+		wait = delays[failures]
+	}
+	throttle.Allowed = throttle.RetryAfter(at) == 0 && (failures == 0 || !at.Before(throttle.LastFailureAt.Add(wait)))
+	if throttle.Allowed {
+		throttle.Failures = failures + 1
+		throttle.LastFailureAt = at
+		throttle.ExpiresAt = at.Add(window)
+		if throttle.LockedUntil != nil && throttle.LockedUntil.After(throttle.ExpiresAt) {
+			throttle.ExpiresAt = *throttle.LockedUntil
+		}
+	}
+	return *throttle, nil
+}
+
+func (r *memoryThrottleRepo) ReleaseAttempt(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if throttle, ok := r.throttles[id]; ok && throttle.Failures > 0 {
+		throttle.Failures--
+	}
+	return nil
+}
+
+func (r *memoryThrottleRepo) LockUntil(id string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if throttle, ok := r.throttles[id]; ok {
+		throttle.LockedUntil = &until
+		if until.After(throttle.ExpiresAt) {
+			throttle.ExpiresAt = until
+		}
+	}
+	return nil
+}
+
+func (r *memoryThrottleRepo) ClearThrottles(ids ...string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var cleared int64
+	for _, id := range ids {
+		if _, ok := r.throttles[id]; ok {
+			delete(r.throttles, id)
+			cleared++
+		}
+	}
+	return cleared, nil
+}
+
+func (r *memoryThrottleRepo) failures(kind, subject string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if throttle, ok := r.throttles[entity.LoginThrottleID(kind, subject)]; ok {
+		return throttle.Failures
+	}
+	return 0
+}
+
+func newThrottledUserUseCase() (*UserUseCase, *memoryThrottleRepo) {
+	repo := newMemoryThrottleRepo()
+	return &UserUseCase{
+		ThrottleRepo: repo,
+		LoginPolicy: LoginPolicy{
+			MaxAccountFailures: 10,
+			MaxIPFailures:      50,
+			LockoutDuration:    15 * time.Minute,
+			FailureWindow:      time.Hour,
+		},
+	}, repo
+}
+
+func TestThrottledCheckBurst(t *testing.T) {
+	u, _ := newThrottledUserUseCase()
+	user := entity.User{ID: "u1", Email: "User@Example.com"}
+
+	// Every check is still in flight when the next attempt arrives, so none of the failures has been recorded yet
+	var checked int32
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	results := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results <- u.throttledCheck(user, "10.0.0.1", func(time.Time) error {
+				atomic.AddInt32(&checked, 1)
+				<-release
+				return errors.New("invalid credentials")
+			})
+		}()
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&checked) < loginFreeAttempts+1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	throttled := 0
+	for err := range results {
+		var throttledErr *LoginThrottledError
+		if errors.As(err, &throttledErr) {
+			throttled++
+		}
+	}
+	if got := atomic.LoadInt32(&checked); got != loginFreeAttempts+1 {
+		t.Errorf("%d credential checks ran, want %d", got, loginFreeAttempts+1)
+	}
+	if throttled != 10-(loginFreeAttempts+1) {
+		t.Errorf("%d attempts throttled, want %d", throttled, 10-(loginFreeAttempts+1))
+	}
+}
+
+func TestThrottledCheckSuccess(t *testing.T) {
+	u, repo := newThrottledUserUseCase()
+	user := entity.User{ID: "u1", Email: "user@example.com"}
+	fail := func(time.Time) error { return errors.New("invalid code") }
+
+	for i := 0; i < 2; i++ {
+		if err := u.throttledCheck(user, "10.0.0.1", fail); err == nil {
+			t.Fatal("failing check returned nil")
+		}
+	}
+	if err := u.throttledCheck(user, "10.0.0.1", func(time.Time) error { return nil }); err != nil {
+		t.Fatalf("passing check: %v", err)
+	}
+
+	// The account starts over; the IP keeps its failures but not the successful attempt
+	if got := repo.failures(entity.LoginThrottleAccount, "user@example.com"); got != 0 {
+		t.Errorf("account failures = %d, want 0", got)
+	}
+	if got := repo.failures(entity.LoginThrottleIP, "10.0.0.1"); got != 2 {
+		t.Errorf("ip failures = %d, want 2", got)
+	}
+}
+
+func TestThrottledCheckNormalizesEmail(t *testing.T) {
+	u, repo := newThrottledUserUseCase()
+	fail := func(time.Time) error { return errors.New("invalid credentials") }
+
+	u.throttledCheck(entity.User{Email: "User@Example.com"}, "", fail)
+	u.throttledCheck(entity.User{Email: " user@example.com"}, "", fail)
+
+	if got := repo.failures(entity.LoginThrottleAccount, "user@example.com"); got != 2 {
+		t.Errorf("failures of the normalized email = %d, want 2", got)
+	}
+}
@@ -0,0 +1,511 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain"
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+)
+
+// In-memory repositories for use case tests. Each embeds its domain interface, so calling a method
+// a test does not expect panics instead of silently returning zero values.
+
+type memoryUserRepo struct {
+	domain.UserRepository
+	users map[string]entity.User
+}
+
+func newMemoryUserRepo(users ...entity.User) *memoryUserRepo {
+	r := &memoryUserRepo{users: make(map[string]entity.User)}
+	for _, user := range users {
+		r.users[user.ID] = user
+	}
+	return r
+}
+
+func (r *memoryUserRepo) GetByID(id string) (entity.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return entity.User{}, errors.New("user not found")
+	}
+	return user, nil
+}
+
+// GetByEmail ignores case like the collation of the users collection, and finds nobody without an error
+func (r *memoryUserRepo) GetByEmail(email string) (entity.User, error) {
+	for _, user := range r.users {
+		if strings.EqualFold(user.Email, email) {
+			return user, nil
+		}
+	}
+	return entity.User{}, nil
+}
+
+func (r *memoryUserRepo) UpdatePassword(userID, hashedPassword string) error {
+	user, ok := r.users[userID]
+	if !ok {
+		return errors.New("user not found")
+	}
+	user.Password = hashedPassword
+	r.users[userID] = user
+	return nil
+}
+
+func (r *memoryUserRepo) MarkEmailVerified(userID string, at time.Time) error {
+	user, ok := r.users[userID]
+	if !ok {
+		return errors.New("user not found")
+	}
+	verified := true
+	user.EmailVerified = &verified
+	user.EmailVerifiedAt = &at
+	r.users[userID] = user
+	return nil
+}
+
+type memoryUserTokenRepo struct {
+	domain.UserTokenRepository
+	tokens []*entity.UserToken
+}
+
+func (r *memoryUserTokenRepo) CreateToken(token entity.UserToken) error {
+	token.ID = fmt.Sprintf("t%d", len(r.tokens))
+	r.tokens = append(r.tokens, &token)
+	return nil
+}
+
+func (r *memoryUserTokenRepo) ConsumeToken(tokenHash, purpose string, now time.Time) (entity.UserToken, error) {
+	for _, token := range r.tokens {
+		if token.TokenHash == tokenHash && token.Purpose == purpose && token.UsedAt == nil && token.ExpiresAt.After(now) {
+			token.UsedAt = &now
+			return *token, nil
+		}
+	}
+	return entity.UserToken{}, errors.New("invalid or expired token")
+}
+
+func (r *memoryUserTokenRepo) InvalidateTokens(userID, purpose string) error {
+	now := time.Now()
+	for _, token := range r.tokens {
+		if token.UserID == userID && token.Purpose == purpose && token.UsedAt == nil {
+			token.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+// memoryMailer keeps the mails it was asked to send
+type memoryMailer struct {
+	sent []domain.Mail
+	err  error
+}
+
+func (m *memoryMailer) Send(mail domain.Mail) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, mail)
+	return nil
+}
+
+type memorySessionRepo struct {
+	domain.SessionRepository
+	sessions map[string]*entity.Session
+}
+
+func newMemorySessionRepo() *memorySessionRepo {
+	return &memorySessionRepo{sessions: make(map[string]*entity.Session)}
+}
+
+func (r *memorySessionRepo) CreateSession(session entity.Session) error {
+	r.sessions[session.ID] = &session
+	return nil
+}
+
+func (r *memorySessionRepo) GetSessionByID(sessionID string) (entity.Session, error) {
+	session, ok := r.sessions[sessionID]
+	if !ok {
+		return entity.Session{}, errors.New("session not found")
+	}
+	return *session, nil
+}
+
+func (r *memorySessionRepo) GetActiveSessionsByUserID(userID string) ([]entity.Session, error) {
+	var sessions []entity.Session
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.IsActive(time.Now()) {
+			sessions = append(sessions, *session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt) })
+	return sessions, nil
+}
+
+func (r *memorySessionRepo) TouchSession(sessionID string, at time.Time) error {
+	if session, ok := r.sessions[sessionID]; ok && session.LastUsedAt.Before(at) {
+		session.LastUsedAt = at
+	}
+	return nil
+}
+
+func (r *memorySessionRepo) RotateRefreshToken(sessionID, currentHash, newHash string, expiresAt time.Time, ip, userAgent string) error {
+	session, ok := r.sessions[sessionID]
+	if !ok || session.RefreshTokenHash != currentHash || session.RevokedAt != nil {
+		return errors.New("invalid refresh token")
+	}
+	session.PreviousTokenHash = currentHash
+	session.RefreshTokenHash = newHash
+	session.ExpiresAt = expiresAt
+	session.IP = ip
+	session.UserAgent = userAgent
+	session.LastUsedAt = time.Now()
+	return nil
+}
+
+func (r *memorySessionRepo) RevokeSession(sessionID, reason string) error {
+	if session, ok := r.sessions[sessionID]; ok && session.RevokedAt == nil {
+		now := time.Now()
+		session.RevokedAt = &now
+		session.RevokedReason = reason
+	}
+	return nil
+}
+
+func (r *memorySessionRepo) RevokeSessionsByUserID(userID, reason string) ([]string, error) {
+	return r.RevokeOtherSessions(userID, "", reason)
+}
+
+func (r *memorySessionRepo) RevokeOtherSessions(userID, keepSessionID, reason string) ([]string, error) {
+	var revoked []string
+	for id, session := range r.sessions {
+		if session.UserID == userID && id != keepSessionID && session.RevokedAt == nil {
+			r.RevokeSession(id, reason)
+			revoked = append(revoked, id)
+		}
+	}
+	sort.Strings(revoked)
+	return revoked, nil
+}
+
+// memoryHub records the sessions whose sockets were closed
+type memoryHub struct {
+	closed []string
+}
+
+func (h *memoryHub) HasLiveSession(sessionID string) bool { return false }
+
+func (h *memoryHub) CloseSessions(sessionIDs ...string) {
+	h.closed = append(h.closed, sessionIDs...)
+}
+
+func (h *memoryHub) ConnectionState(userID string) string { return "" }
+
+type memoryConversationRepo struct {
+	domain.ConversationRepository
+	conversations map[string]*entity.Conversation
+}
+
+func newMemoryConversationRepo(conversations ...entity.Conversation) *memoryConversationRepo {
+	r := &memoryConversationRepo{conversations: make(map[string]*entity.Conversation)}
+	for i := range conversations {
+		conv := conversations[i]
+		r.conversations[conv.ID] = &conv
+	}
+	return r
+}
+
+// GetConversationByID returns a copy, as a database read would
+func (r *memoryConversationRepo) GetConversationByID(conversationID string) (entity.Conversation, error) {
+	conv, ok := r.conversations[conversationID]
+	if !ok {
+		return entity.Conversation{}, errors.New("conversation not found")
+	}
+	copied := *conv
+	copied.Members = append([]entity.ConversationMember(nil), conv.Members...)
+	return copied, nil
+}
+
+func (r *memoryConversationRepo) GetConversationsByUserID(userID string) ([]entity.Conversation, error) {
+	var conversations []entity.Conversation
+	for id, conv := range r.conversations {
+		if _, ok := conv.GetMember(userID); ok {
+			copied, _ := r.GetConversationByID(id)
+			conversations = append(conversations, copied)
+		}
+	}
+	return conversations, nil
+}
+
+func (r *memoryConversationRepo) RecordNewMessage(conversationID, senderID, messageID, preview string, sentAt time.Time) error {
+	conv, ok := r.conversations[conversationID]
+	if !ok {
+		return errors.New("conversation not found")
+	}
+	conv.LastMessage = preview
+	conv.LastMessageTime = &sentAt
+	for i := range conv.Members {
+		member := &conv.Members[i]
+		if member.UserID != senderID {
+			member.UnreadCount++
+			continue
+		}
+		member.LastReadMessageID = messageID
+		member.LastReadAt = &sentAt
+		member.UnreadCount = 0
+	}
+	return nil
+}
+
+func (r *memoryConversationRepo) UpdateReadCursor(conversationID, userID, messageID string, readAt time.Time, unreadCount int) error {
+	conv, ok := r.conversations[conversationID]
+	if !ok {
+		return errors.New("conversation not found")
+	}
+	for i := range conv.Members {
+		if member := &conv.Members[i]; member.UserID == userID {
+			member.LastReadMessageID = messageID
+			member.LastReadAt = &readAt
+			member.UnreadCount = unreadCount
+		}
+	}
+	return nil
+}
+
+func (r *memoryConversationRepo) SetLastMessage(conversationID, preview string, at time.Time) error {
+	conv, ok := r.conversations[conversationID]
+	if !ok {
+		return errors.New("conversation not found")
+	}
+	conv.LastMessage = preview
+	conv.LastMessageTime = &at
+	return nil
+}
+
+// ForgetUnreadMessage uncounts the message for members other than the sender whose read cursor is before it
+func (r *memoryConversationRepo) ForgetUnreadMessage(conversationID, senderID, messageID string, sentAt time.Time) error {
+	conv, ok := r.conversations[conversationID]
+	if !ok {
+		return errors.New("conversation not found")
+	}
+	for i := range conv.Members {
+		member := &conv.Members[i]
+		if member.UserID == senderID || member.UnreadCount == 0 {
+			continue
+		}
+		unread := !member.JoinedAt.After(sentAt)
+		if member.LastReadAt != nil {
+			cursor := &domain.MessageCursor{CreatedAt: *member.LastReadAt, ID: member.LastReadMessageID}
+			unread = compareToCursor(&entity.Message{ID: messageID, CreatedAt: sentAt}, cursor) > 0
+		}
+		if unread {
+			member.UnreadCount--
+		}
+	}
+	return nil
+}
+
+type memoryMessageRepo struct {
+	domain.MessageRepository
+	messages  []*entity.Message
+	lastQuery domain.MessageQuery
+}
+
+func newMemoryMessageRepo(messages ...entity.Message) *memoryMessageRepo {
+	r := &memoryMessageRepo{}
+	for i := range messages {
+		msg := messages[i]
+		r.messages = append(r.messages, &msg)
+	}
+	return r
+}
+
+func (r *memoryMessageRepo) find(messageID string) *entity.Message {
+	for _, msg := range r.messages {
+		if msg.ID == messageID {
+			return msg
+		}
+	}
+	return nil
+}
+
+func (r *memoryMessageRepo) GetMessageByID(messageID string) (entity.Message, error) {
+	msg := r.find(messageID)
+	if msg == nil {
+		return entity.Message{}, errors.New("message not found")
+	}
+	return *msg, nil
+}
+
+// CreateMessage stamps the message one minute after the newest one, so tests do not depend on the clock
+func (r *memoryMessageRepo) CreateMessage(message entity.Message) (string, error) {
+	message.ID = fmt.Sprintf("%s-m%02d", message.GetConversationID(), len(r.messages))
+	message.CreatedAt = testEpoch
+	for _, msg := range r.messages {
+		if !msg.CreatedAt.Before(message.CreatedAt) {
+			message.CreatedAt = msg.CreatedAt.Add(time.Minute)
+		}
+	}
+	r.messages = append(r.messages, &message)
+	return message.ID, nil
+}
+
+func (r *memoryMessageRepo) EditMessage(messageID, content string, revision entity.MessageRevision) error {
+	msg := r.find(messageID)
+	if msg == nil || msg.IsDeleted() {
+		return errors.New("message has been deleted")
+	}
+	msg.Content = content
+	msg.EditedAt = &revision.EditedAt
+	msg.UpdatedAt = revision.EditedAt
+	msg.EditHistory = append(msg.EditHistory, revision)
+	return nil
+}
+
+func (r *memoryMessageRepo) DeleteMessageForEveryone(messageID string) (bool, error) {
+	msg := r.find(messageID)
+	if msg == nil || msg.IsDeleted() {
+		return false, nil
+	}
+	now := time.Now()
+	msg.Content = ""
+	msg.Attachments = []entity.MessageAttachment{}
+	msg.Reactions = []entity.MessageReaction{}
+	msg.EditHistory = nil
+	msg.DeletedAt = &now
+	return true, nil
+}
+
+func (r *memoryMessageRepo) HideMessageForUser(messageID, userID string) error {
+	msg := r.find(messageID)
+	if msg == nil {
+		return errors.New("message not found")
+	}
+	if !msg.IsHiddenFor(userID) {
+		msg.DeletedFor = append(msg.DeletedFor, userID)
+	}
+	return nil
+}
+
+func (r *memoryMessageRepo) MarkAsRead(messageID, userID string) error {
+	msg := r.find(messageID)
+	if msg == nil {
+		return errors.New("message not found")
+	}
+	r.addReadReceipt(msg, userID, time.Now())
+	return nil
+}
+
+func (r *memoryMessageRepo) MarkMessagesReadUpTo(conversationID, userID string, after *domain.MessageCursor, upTo domain.MessageCursor) error {
+	now := time.Now()
+	for _, msg := range r.messages {
+		if msg.GetConversationID() != conversationID || msg.SenderID == userID || compareToCursor(msg, &upTo) > 0 {
+			continue
+		}
+		if after != nil && compareToCursor(msg, after) <= 0 {
+			continue
+		}
+		r.addReadReceipt(msg, userID, now)
+	}
+	return nil
+}
+
+func (r *memoryMessageRepo) addReadReceipt(msg *entity.Message, userID string, at time.Time) {
+	for _, receipt := range msg.ReadReceipts {
+		if receipt.UserID == userID {
+			return
+		}
+	}
+	msg.ReadReceipts = append(msg.ReadReceipts, entity.ReadReceipt{UserID: userID, ReadAt: at})
+}
+
+func (r *memoryMessageRepo) CountUnread(conversationID, userID string, after *domain.MessageCursor) (int, error) {
+	count := 0
+	for _, msg := range r.messages {
+		if msg.GetConversationID() != conversationID || msg.SenderID == userID || msg.IsHiddenFor(userID) || msg.IsDeleted() {
+			continue
+		}
+		if after != nil && compareToCursor(msg, after) <= 0 {
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+// compareToCursor orders a message against a cursor by creation time, then by ID when the cursor has one
+func compareToCursor(msg *entity.Message, cursor *domain.MessageCursor) int {
+	switch {
+	case msg.CreatedAt.Before(cursor.CreatedAt):
+		return -1
+	case msg.CreatedAt.After(cursor.CreatedAt):
+		return 1
+	case cursor.ID == "":
+		return 0
+	case msg.ID < cursor.ID:
+		return -1
+	case msg.ID > cursor.ID:
+		return 1
+	}
+	return 0
+}
+
+func (r *memoryMessageRepo) GetMessagesPage(conversationID string, query domain.MessageQuery) ([]entity.Message, bool, error) {
+	r.lastQuery = query
+
+	var matched []entity.Message
+	for _, msg := range r.messages {
+		if msg.GetConversationID() != conversationID {
+			continue
+		}
+		if query.ViewerID != "" && msg.IsHiddenFor(query.ViewerID) {
+			continue
+		}
+		if query.SkipDeleted && msg.IsDeleted() {
+			continue
+		}
+		if query.After != nil && compareToCursor(msg, query.After) <= 0 {
+			continue
+		}
+		if query.Before != nil && compareToCursor(msg, query.Before) >= 0 {
+			continue
+		}
+		matched = append(matched, *msg)
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].CreatedAt.Equal(matched[j].CreatedAt) {
+			return matched[i].CreatedAt.Before(matched[j].CreatedAt)
+		}
+		return matched[i].ID < matched[j].ID
+	})
+
+	hasMore := len(matched) > query.Limit
+	if !hasMore {
+		return matched, false, nil
+	}
+	if query.After != nil {
+		return matched[:query.Limit], true, nil
+	}
+	return matched[len(matched)-query.Limit:], true, nil
+}
+
+// seedMessages creates count messages in the conversation, one minute apart from start
+func seedMessages(conversationID, senderID string, start time.Time, count int) []entity.Message {
+	messages := make([]entity.Message, 0, count)
+	for i := 0; i < count; i++ {
+		messages = append(messages, entity.Message{
+			ID:             fmt.Sprintf("%s-m%02d", conversationID, i),
+			ConversationID: conversationID,
+			SenderID:       senderID,
+			Type:           entity.MessageTypeText,
+			Content:        fmt.Sprintf("message %d", i),
+			Status:         entity.MessageStatusSent,
+			CreatedAt:      start.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	return messages
+}
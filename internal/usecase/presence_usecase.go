@@ -0,0 +1,127 @@
+package usecase
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain"
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+)
+
+const MaxCustomStatusLength = 100
+
+// Statuses a user can choose; away follows client heartbeats and is never chosen
+var chosenPresenceStatuses = map[string]bool{entity.PresenceOnline: true, entity.PresenceDND: true, entity.PresenceInvisible: true}
+
+// presenceTracker knows the connection state of users across the cluster
+type presenceTracker interface {
+	ConnectionState(userID string) string
+}
+
+// connectionState asks the Hub if available, otherwise falls back to the stored status
+func connectionState(hub presenceTracker, user entity.User) string {
+	if hub != nil {
+		return hub.ConnectionState(user.ID)
+	}
+	if user.Online {
+		return entity.PresenceOnline
+	}
+	return entity.PresenceOffline
+}
+
+// setPresenceFields adds what a viewer with access sees of the user's presence to data and returns the status shown.
+// A hidden online status shows as offline, custom status included.
+func setPresenceFields(data map[string]interface{}, user entity.User, state string, access profileAccess, now time.Time) string {
+	status := entity.PresenceOffline
+	if access.onlineStatus {
+		status = user.VisiblePresence(state)
+		data["online"] = status != entity.PresenceOffline
+		data["presence"] = status
+	}
+	if status != entity.PresenceOffline {
+		if text, expiresAt := user.ActiveCustomStatus(now); text != "" {
+			data["customStatus"] = text
+			if expiresAt != nil {
+				data["customStatusExpiresAt"] = expiresAt
+			}
+		}
+	} else if access.lastSeen && user.LastSeenAt != nil {
+		data["lastSeenAt"] = user.LastSeenAt
+	}
+	return status
+}
+
+// GetPresence returns the status the user chose, what their friends currently see and their custom status
+func (u *UserUseCase) GetPresence(userID string) (map[string]interface{}, error) {
+	user, err := u.Repo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return u.presenceToMap(user), nil
+}
+
+// UpdatePresence changes the chosen status and/or custom status of the user
+func (u *UserUseCase) UpdatePresence(userID string, update domain.PresenceUpdate) (map[string]interface{}, error) {
+	if update.Status == nil && update.CustomStatus == nil {
+		return nil, errors.New("status or customStatus required")
+	}
+	if update.Status != nil && !chosenPresenceStatuses[*update.Status] {
+		return nil, errors.New("invalid status: use online, dnd or invisible")
+	}
+	if update.CustomStatusExpiresAt != nil && update.CustomStatus == nil {
+		return nil, errors.New("customStatus required with customStatusExpiresAt")
+	}
+
+	user, err := u.Repo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	settings := entity.PresenceSettings{}
+	if user.Presence != nil {
+		settings = *user.Presence
+	}
+
+	if update.Status != nil {
+		settings.Status = *update.Status
+	}
+	if update.CustomStatus != nil {
+		text := strings.TrimSpace(*update.CustomStatus)
+		if utf8.RuneCountInString(text) > MaxCustomStatusLength {
+			return nil, errors.New("invalid custom status: too long")
+		}
+		if update.CustomStatusExpiresAt != nil && !update.CustomStatusExpiresAt.After(time.Now()) {
+			return nil, errors.New("invalid customStatusExpiresAt: must be in the future")
+		}
+		settings.CustomStatus = text
+		settings.CustomStatusExpiresAt = update.CustomStatusExpiresAt
+		if text == "" {
+			settings.CustomStatusExpiresAt = nil
+		}
+	}
+
+	if err := u.Repo.UpdatePresenceSettings(userID, settings); err != nil {
+		return nil, err
+	}
+	user.Presence = &settings
+	return u.presenceToMap(user), nil
+}
+
+func (u *UserUseCase) presenceToMap(user entity.User) map[string]interface{} {
+	state := connectionState(u.Hub, user)
+	presence := map[string]interface{}{
+		"status":   user.ChosenPresence(),
+		"presence": user.VisiblePresence(state),
+	}
+	if text, expiresAt := user.ActiveCustomStatus(time.Now()); text != "" {
+		presence["customStatus"] = text
+		if expiresAt != nil {
+			presence["customStatusExpiresAt"] = expiresAt
+		}
+	}
+	if user.LastSeenAt != nil {
+		presence["lastSeenAt"] = user.LastSeenAt
+	}
+	return presence
+}
@@ -0,0 +1,203 @@
+package usecase
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+)
+
+var (
+	visibilityLevels    = map[string]bool{entity.PrivacyEveryone: true, entity.PrivacyFriends: true, entity.PrivacyNobody: true}
+	friendRequestLevels = map[string]bool{entity.PrivacyEveryone: true, entity.PrivacyFriendsOfFriends: true, entity.PrivacyNobody: true}
+)
+
+// profileAccess is what a viewer may see of another user's profile
+type profileAccess struct {
+	email        bool
+	onlineStatus bool
+	lastSeen     bool
+	avatar       bool
+}
+
+// profileAccessFor applies the privacy settings of user to a friend or a stranger.
+// Users always see their own profile in full.
+func profileAccessFor(user entity.User, viewerID string, isFriend bool) profileAccess {
+	if user.ID == viewerID {
+		return profileAccess{email: true, onlineStatus: true, lastSeen: true, avatar: true}
+	}
+	privacy := user.EffectivePrivacy()
+	return profileAccess{
+		email:        entity.PrivacyAllows(privacy.Email, isFriend),
+		onlineStatus: entity.PrivacyAllows(privacy.OnlineStatus, isFriend),
+		lastSeen:     entity.PrivacyAllows(privacy.LastSeen, isFriend),
+		avatar:       entity.PrivacyAllows(privacy.Avatar, isFriend),
+	}
+}
+
+// GetPrivacySettings returns the user's settings, defaults included
+func (u *UserUseCase) GetPrivacySettings(userID string) (entity.PrivacySettings, error) {
+	user, err := u.Repo.GetByID(userID)
+	if err != nil {
+		return entity.PrivacySettings{}, err
+	}
+	return user.EffectivePrivacy(), nil
+}
+
+// UpdatePrivacySettings changes the non-empty fields of update and returns the resulting settings
+func (u *UserUseCase) UpdatePrivacySettings(userID string, update entity.PrivacySettings) (entity.PrivacySettings, error) {
+	for _, level := range []string{update.Email, update.OnlineStatus, update.LastSeen, update.Avatar} {
+		if level != "" && !visibilityLevels[level] {
+			return entity.PrivacySettings{}, errors.New("invalid privacy level: use everyone, friends or nobody")
+		}
+	}
+	if update.FriendRequests != "" && !friendRequestLevels[update.FriendRequests] {
+		return entity.PrivacySettings{}, errors.New("invalid friend request setting: use everyone, friends_of_friends or nobody")
+	}
+
+	settings, err := u.GetPrivacySettings(userID)
+	if err != nil {
+		return entity.PrivacySettings{}, err
+	}
+	if update.Email != "" {
+		settings.Email = update.Email
+	}
+	if update.OnlineStatus != "" {
+		settings.OnlineStatus = update.OnlineStatus
+	}
+	if update.LastSeen != "" {
+		settings.LastSeen = update.LastSeen
+	}
+	if update.Avatar != "" {
+		settings.Avatar = update.Avatar
+	}
+	if update.FriendRequests != "" {
+		settings.FriendRequests = update.FriendRequests
+	}
+
+	if err := u.Repo.UpdatePrivacy(userID, settings); err != nil {
+		return entity.PrivacySettings{}, err
+	}
+	return settings, nil
+}
+
+// SharedProfile is the profile pushed to the user's friends after an update
+func (u *UserUseCase) SharedProfile(userID string) (map[string]interface{}, error) {
+	user, err := u.Repo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := profileToMap(user)
+	if !entity.PrivacyAllows(user.EffectivePrivacy().Avatar, true) {
+		delete(profile, "avatar")
+	}
+	return profile, nil
+}
+
+// GetUserProfile returns the profile of targetID as viewerID may see it, with their relationship.
+// Users on either side of a block do not exist for each other.
+func (uc *FriendUseCase) GetUserProfile(targetID, viewerID string) (map[string]interface{}, error) {
+	if targetID != viewerID {
+		blocked, err := uc.FriendRepo.IsBlocked(viewerID, targetID)
+		if err != nil {
+			return nil, err
+		}
+		if blocked {
+			return nil, errors.New("user not found")
+		}
+	}
+
+	user, err := uc.UserRepo.GetByID(targetID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	friendshipStatus := "none"
+	if targetID == viewerID {
+		friendshipStatus = "self"
+	} else {
+		friendship, err := uc.FriendRepo.GetFriendByUserIDs(viewerID, targetID)
+		if err != nil {
+			return nil, err
+		}
+		switch friendship.Status {
+		case entity.FriendStatusAccepted:
+			friendshipStatus = "friends"
+		case entity.FriendStatusPending:
+			friendshipStatus = "request_received"
+			if friendship.UserID1 == viewerID {
+				friendshipStatus = "request_sent"
+			}
+		}
+	}
+
+	name := user.Name
+	if name == "" {
+		name = user.FullName
+	}
+	profile := map[string]interface{}{
+		"id":               user.ID,
+		"name":             name,
+		"username":         user.Username,
+		"bio":              user.Bio,
+		"createdAt":        user.CreatedAt,
+		"friendshipStatus": friendshipStatus,
+	}
+
+	access := profileAccessFor(user, viewerID, friendshipStatus == "friends")
+	if access.email {
+		profile["email"] = user.Email
+	}
+	if access.avatar {
+		profile["avatar"] = user.Avatar
+	}
+	setPresenceFields(profile, user, connectionState(uc.Hub, user), access, time.Now())
+
+	if friendshipStatus == "none" {
+		profile["canSendFriendRequest"] = uc.checkFriendRequestAllowed(viewerID, user) == nil
+	}
+
+	return profile, nil
+}
+
+// checkFriendRequestAllowed applies the receiver's friend request setting to senderID
+func (uc *FriendUseCase) checkFriendRequestAllowed(senderID string, receiver entity.User) error {
+	switch receiver.EffectivePrivacy().FriendRequests {
+	case entity.PrivacyEveryone:
+		return nil
+	case entity.PrivacyFriendsOfFriends:
+		senderFriendIDs, err := uc.FriendRepo.GetFriendUserIDs(senderID)
+		if err != nil {
+			return err
+		}
+		receiverFriendIDs, err := uc.FriendRepo.GetFriendUserIDs(receiver.ID)
+		if err != nil {
+			return err
+		}
+		receiverFriends := make(map[string]bool, len(receiverFriendIDs))
+		for _, id := range receiverFriendIDs {
+			receiverFriends[id] = true
+		}
+		for _, id := range senderFriendIDs {
+			if receiverFriends[id] {
+				return nil
+			}
+		}
+		return errors.New("forbidden: user only accepts friend requests from friends of friends")
+	}
+	return errors.New("forbidden: user does not accept friend requests")
+}
+
+// matchesVisibleFields reports whether query matches the user by name or username,
+// so users who hide their email cannot be found through it
+func matchesVisibleFields(user entity.User, query string) bool {
+	if strings.Contains(query, "@") {
+		return false
+	}
+	query = strings.ToLower(query)
+	return strings.Contains(strings.ToLower(user.FullName), query) ||
+		strings.Contains(strings.ToLower(user.Name), query) ||
+		strings.Contains(strings.ToLower(user.Username), query)
+}
@@ -0,0 +1,115 @@
+package usecase
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain"
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+	"github.com/TomTom2k/chat-app/server/pkg/utils"
+)
+
+const (
+	MaxNameLength = 100
+	MaxBioLength  = 500
+)
+
+// Usernames are stored lower case so "Alice" and "alice" cannot both be claimed
+var usernamePattern = regexp.MustCompile(`^[a-z0-9_.]{3,30}$`)
+
+// UpdateProfile changes the display name, username, avatar and/or bio of the user
+func (u *UserUseCase) UpdateProfile(userID string, update domain.ProfileUpdate) (map[string]interface{}, error) {
+	if update.Name == nil && update.Username == nil && update.Avatar == nil && update.Bio == nil {
+		return nil, errors.New("name, username, avatar or bio required")
+	}
+
+	if update.Name != nil {
+		name := strings.TrimSpace(*update.Name)
+		if name == "" || utf8.RuneCountInString(name) > MaxNameLength {
+			return nil, errors.New("invalid name")
+		}
+		update.Name = &name
+	}
+
+	if update.Username != nil {
+		username := strings.ToLower(strings.TrimSpace(*update.Username))
+		if !usernamePattern.MatchString(username) {
+			return nil, errors.New("invalid username: 3-30 characters, letters, digits, '_' or '.'")
+		}
+		// The unique index still catches two users racing for the same name
+		existing, err := u.Repo.GetByUsername(username)
+		if err != nil {
+			return nil, err
+		}
+		if existing.ID != "" && existing.ID != userID {
+			return nil, errors.New("username already taken")
+		}
+		update.Username = &username
+	}
+
+	if update.Bio != nil {
+		bio := strings.TrimSpace(*update.Bio)
+		if utf8.RuneCountInString(bio) > MaxBioLength {
+			return nil, errors.New("invalid bio: too long")
+		}
+		update.Bio = &bio
+	}
+
+	if err := u.Repo.UpdateProfile(userID, update); err != nil {
+		return nil, err
+	}
+
+	user, err := u.Repo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return profileToMap(user), nil
+}
+
+// ChangePassword sets a new password after checking the current one. Every other session
+// is logged out; the one making the request stays signed in. Returns how many were revoked.
+func (u *UserUseCase) ChangePassword(userID, sessionID, currentPassword, newPassword string) (int, error) {
+	user, err := u.Repo.GetByID(userID)
+	if err != nil {
+		return 0, err
+	}
+	if !utils.CheckPassword(currentPassword, user.Password) {
+		return 0, errors.New("invalid password")
+	}
+
+	hashed, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return 0, err
+	}
+	if err := u.Repo.UpdatePassword(userID, hashed); err != nil {
+		return 0, err
+	}
+
+	sessionIDs, err := u.SessionRepo.RevokeOtherSessions(userID, sessionID, "password changed")
+	if err != nil {
+		return 0, err
+	}
+	u.closeSessions(sessionIDs...)
+
+	// Reset links requested before the change must not undo it
+	u.TokenRepo.InvalidateTokens(userID, entity.UserTokenResetPassword)
+
+	return len(sessionIDs), nil
+}
+
+// profileToMap is the profile as pushed to friends and returned after an update
+func profileToMap(user entity.User) map[string]interface{} {
+	name := user.Name
+	if name == "" {
+		name = user.FullName
+	}
+	return map[string]interface{}{
+		"id":       user.ID,
+		"name":     name,
+		"username": user.Username,
+		"avatar":   user.Avatar,
+		"bio":      user.Bio,
+	}
+}
@@ -0,0 +1,286 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+	"github.com/TomTom2k/chat-app/server/pkg/jwt"
+)
+
+// Last activity is written at most this often per session, not on every request
+const sessionTouchInterval = time.Minute
+
+// ClientInfo describes the device a session is opened or refreshed from
+type ClientInfo struct {
+	DeviceName string
+	IP         string
+	UserAgent  string
+}
+
+// TokenPair is a short-lived access token and the refresh token used to renew it.
+// The refresh token changes on every refresh; the previous one stops working.
+type TokenPair struct {
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refreshToken"`
+	ExpiresAt    time.Time `json:"expiresAt"` // Thời điểm access token hết hạn
+}
+
+// startSession opens a session for the user and issues its first token pair
+func (u *UserUseCase) startSession(user entity.User, client ClientInfo) (TokenPair, error) {
+	sessionID, err := randomToken(16)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	secret, err := randomToken(32)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	deviceName := client.DeviceName
+	if deviceName == "" {
+		deviceName = deviceNameFromUserAgent(client.UserAgent)
+	}
+
+	now := time.Now()
+	err = u.SessionRepo.CreateSession(entity.Session{
+		ID:               sessionID,
+		UserID:           user.ID,
+		RefreshTokenHash: hashToken(secret),
+		DeviceName:       deviceName,
+		IP:               client.IP,
+		UserAgent:        client.UserAgent,
+		CreatedAt:        now,
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(u.RefreshTokenTTL),
+	})
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return u.issueTokens(user, sessionID, secret)
+}
+
+// Refresh exchanges a refresh token for a new token pair. Presenting a refresh token that was
+// already rotated means it leaked, so the whole session is revoked.
+func (u *UserUseCase) Refresh(refreshToken string, client ClientInfo) (*TokenPair, error) {
+	sessionID, secret, ok := strings.Cut(refreshToken, ".")
+	if !ok || sessionID == "" || secret == "" {
+		return nil, errors.New("invalid refresh token")
+	}
+
+	session, err := u.SessionRepo.GetSessionByID(sessionID)
+	if err != nil {
+		return nil, errors.New("invalid refresh token")
+	}
+
+	hash := hashToken(secret)
+	if session.PreviousTokenHash != "" && tokensEqual(hash, session.PreviousTokenHash) {
+		u.SessionRepo.RevokeSession(session.ID, "refresh token reuse")
+		// Whoever holds the stolen token may already have a live socket
+		u.closeSessions(session.ID)
+		return nil, errors.New("invalid refresh token")
+	}
+
+	now := time.Now()
+	if !session.IsActive(now) || !tokensEqual(hash, session.RefreshTokenHash) {
+		return nil, errors.New("invalid refresh token")
+	}
+
+	user, err := u.Repo.GetByID(session.UserID)
+	if err != nil {
+		return nil, errors.New("invalid refresh token")
+	}
+
+	newSecret, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	err = u.SessionRepo.RotateRefreshToken(session.ID, hash, hashToken(newSecret), now.Add(u.RefreshTokenTTL), client.IP, client.UserAgent)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := u.issueTokens(user, session.ID, newSecret)
+	if err != nil {
+		return nil, err
+	}
+	return &tokens, nil
+}
+
+// Logout revokes the session the access token belongs to
+func (u *UserUseCase) Logout(sessionID string) error {
+	if err := u.SessionRepo.RevokeSession(sessionID, "logout"); err != nil {
+		return err
+	}
+	u.closeSessions(sessionID)
+	return nil
+}
+
+// LogoutAll revokes every session of the user and returns their IDs
+func (u *UserUseCase) LogoutAll(userID string) ([]string, error) {
+	sessionIDs, err := u.SessionRepo.RevokeSessionsByUserID(userID, "logout all")
+	if err != nil {
+		return nil, err
+	}
+	u.closeSessions(sessionIDs...)
+	return sessionIDs, nil
+}
+
+// GetSessions lists the active sessions of the user, most recently used first
+func (u *UserUseCase) GetSessions(userID, currentSessionID string) ([]map[string]interface{}, error) {
+	sessions, err := u.SessionRepo.GetActiveSessionsByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]map[string]interface{}, 0, len(sessions))
+	for _, session := range sessions {
+		online := false
+		if u.Hub != nil {
+			online = u.Hub.HasLiveSession(session.ID)
+		}
+		result = append(result, map[string]interface{}{
+			"id":           session.ID,
+			"deviceName":   session.DeviceName,
+			"ip":           session.IP,
+			"userAgent":    session.UserAgent,
+			"createdAt":    session.CreatedAt,
+			"lastActiveAt": session.LastUsedAt,
+			"current":      session.ID == currentSessionID,
+			"online":       online,
+		})
+	}
+
+	return result, nil
+}
+
+// RevokeSession logs one of the user's sessions out and closes its sockets
+func (u *UserUseCase) RevokeSession(userID, sessionID string) error {
+	session, err := u.SessionRepo.GetSessionByID(sessionID)
+	if err != nil || session.UserID != userID || !session.IsActive(time.Now()) {
+		return errors.New("session not found")
+	}
+
+	if err := u.SessionRepo.RevokeSession(sessionID, "revoked by user"); err != nil {
+		return err
+	}
+	u.closeSessions(sessionID)
+	return nil
+}
+
+func (u *UserUseCase) closeSessions(sessionIDs ...string) {
+	if u.Hub != nil {
+		u.Hub.CloseSessions(sessionIDs...)
+	}
+}
+
+// Authenticate validates an access token and checks that its session is still active
+func (u *UserUseCase) Authenticate(token string) (*jwt.Claims, error) {
+	claims, err := u.Tokens.ValidateToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tokens issued before sessions existed cannot be revoked, so they are no longer accepted
+	if claims.SessionID == "" {
+		return nil, errors.New("unauthorized: token has no session")
+	}
+
+	now := time.Now()
+	session, err := u.SessionRepo.GetSessionByID(claims.SessionID)
+	if err != nil || session.UserID != claims.UserID || !session.IsActive(now) {
+		return nil, errors.New("unauthorized: session revoked or expired")
+	}
+
+	if now.Sub(session.LastUsedAt) > sessionTouchInterval {
+		u.SessionRepo.TouchSession(session.ID, now)
+	}
+
+	return claims, nil
+}
+
+// JWKS returns the public keys access tokens can be verified with
+func (u *UserUseCase) JWKS() jwt.JWKSet {
+	return u.Tokens.JWKS()
+}
+
+func (u *UserUseCase) issueTokens(user entity.User, sessionID, secret string) (TokenPair, error) {
+	expiresAt := time.Now().Add(u.AccessTokenTTL)
+	token, err := u.Tokens.GenerateToken(user.ID, user.Email, sessionID, u.AccessTokenTTL)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{
+		Token:        token,
+		RefreshToken: sessionID + "." + secret,
+		ExpiresAt:    expiresAt,
+	}, nil
+}
+
+// deviceNameFromUserAgent builds a readable label such as "Chrome on Windows"
+func deviceNameFromUserAgent(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	browser := "Unknown browser"
+	switch {
+	case strings.Contains(userAgent, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(userAgent, "OPR/"):
+		browser = "Opera"
+	case strings.Contains(userAgent, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(userAgent, "Chrome/"):
+		browser = "Chrome"
+	case strings.Contains(userAgent, "Safari/"):
+		browser = "Safari"
+	}
+
+	platform := ""
+	switch {
+	case strings.Contains(userAgent, "iPhone"):
+		platform = "iPhone"
+	case strings.Contains(userAgent, "iPad"):
+		platform = "iPad"
+	case strings.Contains(userAgent, "Android"):
+		platform = "Android"
+	case strings.Contains(userAgent, "Windows"):
+		platform = "Windows"
+	case strings.Contains(userAgent, "Mac OS X"):
+		platform = "macOS"
+	case strings.Contains(userAgent, "Linux"):
+		platform = "Linux"
+	}
+
+	if platform == "" {
+		return browser
+	}
+	return browser + " on " + platform
+}
+
+func randomToken(size int) (string, error) {
+	bytes := make([]byte, size)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// hashToken returns the digest kept in the database instead of the refresh token itself
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func tokensEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,270 @@
+package usecase
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+	"github.com/TomTom2k/chat-app/server/pkg/jwt"
+)
+
+func newSessionUseCase(t *testing.T) (*UserUseCase, *memorySessionRepo, *memoryHub) {
+	t.Helper()
+	tokens, err := jwt.LoadKeyring(jwt.KeyringOptions{Algorithm: jwt.AlgorithmHS256, Secret: "test-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionRepo := newMemorySessionRepo()
+	hub := &memoryHub{}
+	return &UserUseCase{
+		Repo:            newMemoryUserRepo(entity.User{ID: "u1", Email: "u1@example.com"}, entity.User{ID: "u2", Email: "u2@example.com"}),
+		SessionRepo:     sessionRepo,
+		Tokens:          tokens,
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: 30 * 24 * time.Hour,
+		Hub:             hub,
+	}, sessionRepo, hub
+}
+
+func mustStartSession(t *testing.T, u *UserUseCase, userID string) TokenPair {
+	t.Helper()
+	user, _ := u.Repo.GetByID(userID)
+	tokens, err := u.startSession(user, ClientInfo{IP: "10.0.0.1", UserAgent: "Mozilla/5.0 (Windows NT 10.0) Chrome/120.0"})
+	if err != nil {
+		t.Fatalf("startSession: %v", err)
+	}
+	return tokens
+}
+
+func sessionIDOf(tokens TokenPair) string {
+	sessionID, _, _ := strings.Cut(tokens.RefreshToken, ".")
+	return sessionID
+}
+
+func TestStartSession(t *testing.T) {
+	u, sessionRepo, _ := newSessionUseCase(t)
+	tokens := mustStartSession(t, u, "u1")
+
+	session := sessionRepo.sessions[sessionIDOf(tokens)]
+	if session == nil {
+		t.Fatal("no session was stored")
+	}
+	if session.DeviceName != "Chrome on Windows" || session.UserID != "u1" {
+		t.Errorf("session = %+v", session)
+	}
+	// Only a digest of the refresh token is stored
+	if strings.Contains(tokens.RefreshToken, session.RefreshTokenHash) {
+		t.Error("the refresh token is stored in clear")
+	}
+
+	claims, err := u.Authenticate(tokens.Token)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if claims.UserID != "u1" || claims.SessionID != session.ID {
+		t.Errorf("claims = %+v, want user u1 in session %s", claims, session.ID)
+	}
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	u, sessionRepo, _ := newSessionUseCase(t)
+	first := mustStartSession(t, u, "u1")
+
+	second, err := u.Refresh(first.RefreshToken, ClientInfo{IP: "10.0.0.2"})
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken || sessionIDOf(*second) != sessionIDOf(first) {
+		t.Errorf("refresh gave %q after %q, want a new token for the same session", second.RefreshToken, first.RefreshToken)
+	}
+	if _, err := u.Authenticate(second.Token); err != nil {
+		t.Errorf("Authenticate(refreshed token): %v", err)
+	}
+	if got := sessionRepo.sessions[sessionIDOf(first)].IP; got != "10.0.0.2" {
+		t.Errorf("session IP = %q, want the IP of the refresh", got)
+	}
+
+	third, err := u.Refresh(second.RefreshToken, ClientInfo{})
+	if err != nil {
+		t.Fatalf("second Refresh: %v", err)
+	}
+	if third.RefreshToken == second.RefreshToken {
+		t.Error("refresh token did not change on the second refresh")
+	}
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	u, sessionRepo, hub := newSessionUseCase(t)
+	first := mustStartSession(t, u, "u1")
+	second, err := u.Refresh(first.RefreshToken, ClientInfo{})
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	// The rotated token is presented again: someone else holds a copy
+	if _, err := u.Refresh(first.RefreshToken, ClientInfo{}); err == nil || err.Error() != "invalid refresh token" {
+		t.Fatalf("reused token: err = %v, want invalid refresh token", err)
+	}
+
+	sessionID := sessionIDOf(first)
+	if session := sessionRepo.sessions[sessionID]; session.RevokedAt == nil || session.RevokedReason != "refresh token reuse" {
+		t.Errorf("session revoked at %v for %q, want it revoked for reuse", session.RevokedAt, session.RevokedReason)
+	}
+	if !reflect.DeepEqual(hub.closed, []string{sessionID}) {
+		t.Errorf("closed sockets of %v, want %v", hub.closed, []string{sessionID})
+	}
+
+	// Neither the legitimate client's newest tokens nor its access token work any more
+	if _, err := u.Refresh(second.RefreshToken, ClientInfo{}); err == nil {
+		t.Error("the newest refresh token still works after reuse was detected")
+	}
+	if _, err := u.Authenticate(second.Token); err == nil {
+		t.Error("the access token still works after reuse was detected")
+	}
+}
+
+func TestRefreshRejects(t *testing.T) {
+	u, sessionRepo, _ := newSessionUseCase(t)
+	tokens := mustStartSession(t, u, "u1")
+	sessionID := sessionIDOf(tokens)
+
+	expired := mustStartSession(t, u, "u1")
+	sessionRepo.sessions[sessionIDOf(expired)].ExpiresAt = time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"no secret", sessionID + "."},
+		{"no separator", sessionID},
+		{"unknown session", "unknown." + strings.SplitN(tokens.RefreshToken, ".", 2)[1]},
+		{"wrong secret", sessionID + ".wrong"},
+		{"expired session", expired.RefreshToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := u.Refresh(tt.token, ClientInfo{}); err == nil || err.Error() != "invalid refresh token" {
+				t.Errorf("err = %v, want invalid refresh token", err)
+			}
+		})
+	}
+
+	// A wrong secret is not a reuse and leaves the session alone
+	if _, err := u.Refresh(tokens.RefreshToken, ClientInfo{}); err != nil {
+		t.Errorf("Refresh after rejected attempts: %v", err)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	u, _, hub := newSessionUseCase(t)
+	tokens := mustStartSession(t, u, "u1")
+	other := mustStartSession(t, u, "u1")
+
+	if err := u.Logout(sessionIDOf(tokens)); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	if _, err := u.Authenticate(tokens.Token); err == nil {
+		t.Error("access token still works after logout")
+	}
+	if _, err := u.Refresh(tokens.RefreshToken, ClientInfo{}); err == nil {
+		t.Error("refresh token still works after logout")
+	}
+	if !reflect.DeepEqual(hub.closed, []string{sessionIDOf(tokens)}) {
+		t.Errorf("closed sockets of %v, want only the logged out session", hub.closed)
+	}
+	if _, err := u.Authenticate(other.Token); err != nil {
+		t.Errorf("another session was logged out: %v", err)
+	}
+}
+
+func TestLogoutAll(t *testing.T) {
+	u, _, hub := newSessionUseCase(t)
+	first := mustStartSession(t, u, "u1")
+	second := mustStartSession(t, u, "u1")
+	stranger := mustStartSession(t, u, "u2")
+
+	revoked, err := u.LogoutAll("u1")
+	if err != nil {
+		t.Fatalf("LogoutAll: %v", err)
+	}
+	if len(revoked) != 2 || len(hub.closed) != 2 {
+		t.Errorf("revoked %v and closed %v, want both sessions of u1", revoked, hub.closed)
+	}
+	for _, tokens := range []TokenPair{first, second} {
+		if _, err := u.Authenticate(tokens.Token); err == nil {
+			t.Error("access token still works after logging out everywhere")
+		}
+	}
+	if _, err := u.Authenticate(stranger.Token); err != nil {
+		t.Errorf("another user's session was logged out: %v", err)
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	u, _, _ := newSessionUseCase(t)
+	current := mustStartSession(t, u, "u1")
+	old := mustStartSession(t, u, "u1")
+	stranger := mustStartSession(t, u, "u2")
+
+	if err := u.RevokeSession("u1", sessionIDOf(stranger)); err == nil || err.Error() != "session not found" {
+		t.Errorf("revoking another user's session: err = %v, want session not found", err)
+	}
+	if err := u.RevokeSession("u1", sessionIDOf(old)); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+	if err := u.RevokeSession("u1", sessionIDOf(old)); err == nil {
+		t.Error("a revoked session was revoked again")
+	}
+
+	sessions, err := u.GetSessions("u1", sessionIDOf(current))
+	if err != nil {
+		t.Fatalf("GetSessions: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0]["id"] != sessionIDOf(current) || sessions[0]["current"] != true {
+		t.Errorf("sessions = %v, want only the current one", sessions)
+	}
+}
+
+func TestAuthenticateRejects(t *testing.T) {
+	u, _, _ := newSessionUseCase(t)
+	tokens := mustStartSession(t, u, "u1")
+
+	withoutSession, err := u.Tokens.GenerateToken("u1", "u1@example.com", "", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A token naming a session of someone else
+	forged, err := u.Tokens.GenerateToken("u2", "u2@example.com", sessionIDOf(tokens), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, token := range map[string]string{"no session": withoutSession, "session of another user": forged, "garbage": "not-a-token"} {
+		if _, err := u.Authenticate(token); err == nil {
+			t.Errorf("%s: token was accepted", name)
+		}
+	}
+}
+
+func TestDeviceNameFromUserAgent(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      string
+	}{
+		{"", "Unknown device"},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/120.0 Safari/537.36 Edg/120.0", "Edge on Windows"},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 Version/17.0 Mobile/15E148 Safari/604.1", "Safari on iPhone"},
+		{"Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0", "Firefox on Linux"},
+		{"Mozilla/5.0 (Linux; Android 14) AppleWebKit/537.36 Chrome/120.0 Mobile Safari/537.36", "Chrome on Android"},
+		{"curl/8.4.0", "Unknown browser"},
+	}
+	for _, tt := range tests {
+		if got := deviceNameFromUserAgent(tt.userAgent); got != tt.want {
+			t.Errorf("deviceNameFromUserAgent(%q) = %q, want %q", tt.userAgent, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/TomTom2k/chat-app/server/internal/domain/entity"
+	"github.com/TomTom2k/chat-app/server/pkg/totp"
+	"github.com/TomTom2k/chat-app/server/pkg/utils"
+)
+
+const (
+	twoFactorChallengeTTL  = 5 * time.Minute
+	maxTwoFactorAttempts   = 5 // Số lần nhập mã sai trước khi challenge token bị hủy
+	totpSkew               = 1 // Chấp nhận mã của bước liền trước và liền sau khi đồng hồ lệch
+	recoveryCodeCount      = 10
+	recoveryCodeGroupChars = 5
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// LoginResult is either a token pair, or a challenge to answer with a TOTP or recovery code
+type LoginResult struct {
+	*RegisterResult
+	TwoFactor *TwoFactorChallenge `json:"twoFactor,omitempty"`
+}
+
+// TwoFactorChallenge is returned instead of tokens when the account has 2FA enabled
+type TwoFactorChallenge struct {
+	ChallengeToken string    `json:"challengeToken"`
+	ExpiresAt      time.Time `json:"expiresAt"`
+}
+
+// TwoFactorSetup is shown once during enrollment, usually as a QR code of OTPAuthURI
+type TwoFactorSetup struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauthUri"`
+}
+
+// startTwoFactorChallenge issues the intermediate token exchanged for a session by VerifyTwoFactorLogin
+func (u *UserUseCase) startTwoFactorChallenge(user entity.User) (*TwoFactorChallenge, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(twoFactorChallengeTTL)
+	err = u.TokenRepo.CreateToken(entity.UserToken{
+		UserID:    user.ID,
+		Purpose:   entity.UserTokenLoginChallenge,
+		TokenHash: hashToken(token),
+		Email:     user.Email,
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorChallenge{ChallengeToken: token, ExpiresAt: expiresAt}, nil
+}
+
+// VerifyTwoFactorLogin completes a login started with a password: the challenge token and a
+// TOTP or recovery code open the session. Too many wrong codes invalidate the challenge.
+func (u *UserUseCase) VerifyTwoFactorLogin(challengeToken, code string, client ClientInfo) (*RegisterResult, error) {
+	now := time.Now()
+	challengeHash := hashToken(challengeToken)
+	challenge, err := u.TokenRepo.GetToken(challengeHash, entity.UserTokenLoginChallenge, now)
+	if err != nil {
+		return nil, errors.New("invalid or expired challenge")
+	}
+
+	user, err := u.Repo.GetByID(challenge.UserID)
+	if err != nil || !user.IsTwoFactorEnabled() {
+		return nil, errors.New("invalid or expired challenge")
+	}
+
+	// Wrong codes count against the account and the IP like wrong passwords, so new challenges do not give new guesses
+	err = u.throttledCheck(user, client.IP, func(now time.Time) error {
+		return u.checkSecondFactor(user, code, now)
+	})
+	if err != nil {
+		var throttled *LoginThrottledError
+		if !errors.As(err, &throttled) {
+			u.TokenRepo.RecordFailedAttempt(challenge.ID, maxTwoFactorAttempts)
+		}
+		return nil, err
+	}
+
+	// Only one request can turn the challenge into a session
+	if _, err := u.TokenRepo.ConsumeToken(challengeHash, entity.UserTokenLoginChallenge, now); err != nil {
+		return nil, errors.New("invalid or expired challenge")
+	}
+
+	tokens, err := u.startSession(user, client)
+	if err != nil {
+		return nil, err
+	}
+
+	user.Password = ""
+	if user.FullName != "" && user.Name == "" {
+		user.Name = user.FullName
+	}
+	emailVerified := user.IsEmailVerified()
+	user.EmailVerified = &emailVerified
+
+	return &RegisterResult{
+		TokenPair: tokens,
+		User:      user,
+	}, nil
+}
+
+// SetupTwoFactor generates a new secret. 2FA stays off until ConfirmTwoFactor proves the
+// authenticator app was set up with it.
+func (u *UserUseCase) SetupTwoFactor(userID string) (*TwoFactorSetup, error) {
+	user, err := u.Repo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsTwoFactorEnabled() {
+		return nil, errors.New("two-factor authentication already enabled")
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := u.Repo.SetPendingTwoFactorSecret(userID, secret); err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorSetup{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(u.TOTPIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTwoFactor enables 2FA once the user enters a code of the pending secret.
+// The recovery codes are returned in clear text this one time only.
+func (u *UserUseCase) ConfirmTwoFactor(userID, code string) ([]string, error) {
+	user, err := u.Repo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsTwoFactorEnabled() {
+		return nil, errors.New("two-factor authentication already enabled")
+	}
+	if user.TwoFactor == nil || user.TwoFactor.PendingSecret == "" {
+		return nil, errors.New("two-factor setup not started")
+	}
+
+	step, ok := totp.Validate(user.TwoFactor.PendingSecret, normalizeCode(code), time.Now(), totpSkew)
+	if !ok {
+		return nil, errors.New("invalid code")
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := u.Repo.EnableTwoFactor(userID, user.TwoFactor.PendingSecret, hashes, time.Now()); err != nil {
+		return nil, err
+	}
+
+	// The confirmation code must not be usable again to log in
+	u.Repo.UseTwoFactorStep(userID, step)
+
+	return codes, nil
+}
+
+// DisableTwoFactor turns 2FA off; it requires the password and a current code, both under the login throttle
+func (u *UserUseCase) DisableTwoFactor(userID, password, code string, client ClientInfo) error {
+	user, err := u.Repo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	if !user.IsTwoFactorEnabled() {
+		return errors.New("two-factor authentication not enabled")
+	}
+	err = u.throttledCheck(user, client.IP, func(now time.Time) error {
+		if !utils.CheckPassword(password, user.Password) {
+			return errors.New("invalid password")
+		}
+		return u.checkSecondFactor(user, code, now)
+	})
+	if err != nil {
+		return err
+	}
+
+	return u.Repo.DisableTwoFactor(userID)
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes, the previous ones stop working.
+// The code is checked under the login throttle.
+func (u *UserUseCase) RegenerateRecoveryCodes(userID, code string, client ClientInfo) ([]string, error) {
+	user, err := u.Repo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsTwoFactorEnabled() {
+		return nil, errors.New("two-factor authentication not enabled")
+	}
+	err = u.throttledCheck(user, client.IP, func(now time.Time) error {
+		return u.checkSecondFactor(user, code, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := u.Repo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// checkSecondFactor accepts a TOTP code or one of the recovery codes; each works only once
+func (u *UserUseCase) checkSecondFactor(user entity.User, code string, now time.Time) error {
+	code = normalizeCode(code)
+
+	if len(code) == totp.Digits {
+		step, ok := totp.Validate(user.TwoFactor.Secret, code, now, totpSkew)
+		if !ok {
+			return errors.New("invalid code")
+		}
+		return u.Repo.UseTwoFactorStep(user.ID, step)
+	}
+
+	if code == "" {
+		return errors.New("invalid code")
+	}
+	return u.Repo.ConsumeRecoveryCode(user.ID, hashToken(code))
+}
+
+// generateRecoveryCodes returns codes formatted as "xxxxx-xxxxx" and the hashes to store
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		bytes := make([]byte, 7)
+		if _, err := rand.Read(bytes); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(recoveryCodeEncoding.EncodeToString(bytes))[:2*recoveryCodeGroupChars]
+		codes = append(codes, raw[:recoveryCodeGroupChars]+"-"+raw[recoveryCodeGroupChars:])
+		hashes = append(hashes, hashToken(raw))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeCode lets users type codes with spaces, dashes or in upper case
+func normalizeCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer(" ", "", "-", "").Replace(code)
+}
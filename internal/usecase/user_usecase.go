@@ -2,6 +2,7 @@ package usecase
 
 import (
 	"errors"
+	"log"
 	"time"
 
 	"github.com/TomTom2k/chat-app/server/internal/domain"
@@ -11,16 +12,35 @@ import (
 )
 
 type UserUseCase struct {
-	Repo      domain.UserRepository
-	JWTSecret string
+	Repo              domain.UserRepository
+	SessionRepo       domain.SessionRepository
+	TokenRepo         domain.UserTokenRepository
+	Tokens            *jwt.Keyring
+	Mailer            domain.Mailer
+	AppBaseURL        string
+	AccessTokenTTL    time.Duration
+	RefreshTokenTTL   time.Duration
+	EmailVerifyTTL    time.Duration
+	PasswordResetTTL  time.Duration
+	TOTPIssuer        string // Tên hiển thị trong ứng dụng authenticator
+	ThrottleRepo      domain.LoginThrottleRepository
+	SecurityEventRepo domain.SecurityEventRepository
+	LoginPolicy       LoginPolicy
+	Hub               interface {
+		HasLiveSession(sessionID string) bool
+		CloseSessions(sessionIDs ...string)
+		ConnectionState(userID string) string
+	}
 }
 
 type RegisterResult struct {
-	Token string      `json:"token"`
-	User  entity.User `json:"user"`
+	TokenPair
+	User entity.User `json:"user"`
 }
 
-func (u *UserUseCase) Register(user entity.User) (*RegisterResult, error) {
+func (u *UserUseCase) Register(user entity.User, client ClientInfo) (*RegisterResult, error) {
+	user.Email = normalizeEmail(user.Email)
+
 	// Check if user with email already exists
 	existingUser, _ := u.Repo.GetByEmail(user.Email)
 	if existingUser.Email != "" {
@@ -35,13 +55,15 @@ func (u *UserUseCase) Register(user entity.User) (*RegisterResult, error) {
 
 	// Set timestamps
 	now := time.Now()
+	verified := false
 	newUser := entity.User{
-		Email:     user.Email,
-		Name:      user.Name,
-		FullName:  user.Name, // Store in both fields for compatibility
-		Password:  hashed,
-		CreatedAt: now,
-		UpdatedAt: now,
+		Email:         user.Email,
+		Name:          user.Name,
+		FullName:      user.Name, // Store in both fields for compatibility
+		Password:      hashed,
+		EmailVerified: &verified, // Restricted until the address is confirmed
+		CreatedAt:     now,
+		UpdatedAt:     now,
 	}
 
 	// Create user
@@ -56,41 +78,74 @@ func (u *UserUseCase) Register(user entity.User) (*RegisterResult, error) {
 		return nil, err
 	}
 
-	// Generate JWT token
-	token, err := jwt.GenerateToken(createdUser.ID, createdUser.Email, u.JWTSecret)
+	// Open a session and issue its tokens
+	tokens, err := u.startSession(createdUser, client)
 	if err != nil {
 		return nil, err
 	}
 
+	// The account is usable without it, the user can ask for a new link later
+	if err := u.SendVerificationEmail(createdUser.ID); err != nil {
+		log.Printf("[ERROR]: unable to send verification email: %v", err)
+	}
+
 	// Remove password from response and map fields
 	createdUser.Password = ""
 	if createdUser.FullName != "" && createdUser.Name == "" {
 		createdUser.Name = createdUser.FullName
 	}
+	emailVerified := createdUser.IsEmailVerified()
+	createdUser.EmailVerified = &emailVerified
 
 	return &RegisterResult{
-		Token: token,
-		User:  createdUser,
+		TokenPair: tokens,
+		User:      createdUser,
 	}, nil
 }
 
-func (u *UserUseCase) Login(email string, password string) (*RegisterResult, error) {
+// Login checks the password. With 2FA enabled no session is opened yet, a challenge
+// is returned that VerifyTwoFactorLogin exchanges for tokens.
+func (u *UserUseCase) Login(email string, password string, client ClientInfo) (*LoginResult, error) {
+	// Attempts are counted per email and per IP before the password is even looked at
+	now := time.Now()
+	email = normalizeEmail(email)
+	attempts, err := u.reserveLoginAttempt(email, client.IP, now)
+	if err != nil {
+		return nil, err
+	}
+
 	user, err := u.Repo.GetByEmail(email)
 	if err != nil {
+		u.releaseLoginAttempts(attempts)
 		return nil, err
 	}
 
 	if user.Email == "" {
+		burnPasswordCheck(password)
+		u.recordLoginFailure(attempts, email, client.IP, "", now)
 		return nil, errors.New("invalid credentials")
 	}
 
 	// Check password
 	if !utils.CheckPassword(password, user.Password) {
+		u.recordLoginFailure(attempts, email, client.IP, user.ID, now)
 		return nil, errors.New("invalid credentials")
 	}
 
-	// Generate JWT token
-	token, err := jwt.GenerateToken(user.ID, user.Email, u.JWTSecret)
+	// The account counter is only reset once the second factor is verified too
+	if user.IsTwoFactorEnabled() {
+		u.releaseLoginAttempts(attempts)
+		challenge, err := u.startTwoFactorChallenge(user)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{TwoFactor: challenge}, nil
+	}
+
+	u.recordLoginSuccess(attempts, email)
+
+	// Open a session and issue its tokens
+	tokens, err := u.startSession(user, client)
 	if err != nil {
 		return nil, err
 	}
@@ -100,10 +155,14 @@ func (u *UserUseCase) Login(email string, password string) (*RegisterResult, err
 	if user.FullName != "" && user.Name == "" {
 		user.Name = user.FullName
 	}
-
-	return &RegisterResult{
-		Token: token,
-		User:  user,
+	emailVerified := user.IsEmailVerified()
+	user.EmailVerified = &emailVerified
+
+	return &LoginResult{
+		RegisterResult: &RegisterResult{
+			TokenPair: tokens,
+			User:      user,
+		},
 	}, nil
 }
 
@@ -118,5 +177,7 @@ func (u *UserUseCase) GetMe(userID string) (entity.User, error) {
 	if user.FullName != "" && user.Name == "" {
 		user.Name = user.FullName
 	}
+	emailVerified := user.IsEmailVerified()
+	user.EmailVerified = &emailVerified
 	return user, nil
 }
@@ -8,28 +8,29 @@ import (
 )
 
 type Claims struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
+	UserID    string `json:"user_id"`
+	Email     string `json:"email"`
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID, email, secretKey string) (string, error) {
-	if secretKey == "" {
-		secretKey = "your-secret-key-change-in-production"
-	}
-
-	expirationTime := time.Now().Add(24 * time.Hour)
+// GenerateToken issues an access token bound to a session, valid for ttl.
+// It is signed with the keyring's signing key and carries its kid header.
+func (k *Keyring) GenerateToken(userID, email, sessionID string, ttl time.Duration) (string, error) {
+	now := time.Now()
 	claims := &Claims{
-		UserID: userID,
-		Email:  email,
+		UserID:    userID,
+		Email:     email,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(secretKey))
+	token := jwt.NewWithClaims(k.signing.method, claims)
+	token.Header["kid"] = k.signing.ID
+	tokenString, err := token.SignedString(k.signing.signKey)
 	if err != nil {
 		return "", err
 	}
@@ -37,18 +38,21 @@ func GenerateToken(userID, email, secretKey string) (string, error) {
 	return tokenString, nil
 }
 
-func ValidateToken(tokenString, secretKey string) (*Claims, error) {
-	if secretKey == "" {
-		secretKey = "your-secret-key-change-in-production"
-	}
-
+// ValidateToken verifies a token with the key named by its kid header.
+// The algorithm must be the one of that key, so a public key can never be used as an HMAC secret.
+func (k *Keyring) ValidateToken(tokenString string) (*Claims, error) {
 	claims := &Claims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := k.keys[kid]
+		if !ok {
+			return nil, errors.New("unknown signing key")
+		}
+		if token.Method.Alg() != key.Algorithm {
 			return nil, errors.New("invalid signing method")
 		}
-		return []byte(secretKey), nil
-	})
+		return key.verifyKey, nil
+	}, jwt.WithValidMethods(k.algorithms()))
 	if err != nil {
 		return nil, err
 	}
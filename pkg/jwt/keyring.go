@@ -0,0 +1,230 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// Key is one entry of the keyring. Only the signing key holds private material.
+type Key struct {
+	ID        string
+	Algorithm string
+	method    jwt.SigningMethod
+	signKey   interface{} // []byte, *rsa.PrivateKey or ed25519.PrivateKey
+	verifyKey interface{} // []byte, *rsa.PublicKey or ed25519.PublicKey
+}
+
+// Keyring signs tokens with one key and verifies them with any key it knows, so tokens
+// signed with a previous key stay valid while it is being rotated out.
+type Keyring struct {
+	signing *Key
+	keys    map[string]*Key
+}
+
+// KeyringOptions describes where the keys come from
+type KeyringOptions struct {
+	Algorithm        string            // HS256, RS256 or EdDSA
+	KeyID            string            // kid of the signing key, derived from the key when empty
+	Secret           string            // HS256 only
+	PrivateKeyFile   string            // RS256/EdDSA: PEM private key (PKCS#8, or PKCS#1 for RSA)
+	VerificationKeys map[string]string // kid -> PEM public key file of keys that are no longer used to sign
+}
+
+// LoadKeyring builds the keyring described by opts. Missing or unreadable keys are an error:
+// there is no built-in fallback secret.
+func LoadKeyring(opts KeyringOptions) (*Keyring, error) {
+	var signing *Key
+	switch opts.Algorithm {
+	case AlgorithmHS256, "":
+		if opts.Secret == "" {
+			return nil, errors.New("jwt: HS256 requires a secret")
+		}
+		signing = &Key{
+			ID:        opts.KeyID,
+			Algorithm: AlgorithmHS256,
+			method:    jwt.SigningMethodHS256,
+			signKey:   []byte(opts.Secret),
+			verifyKey: []byte(opts.Secret),
+		}
+		if signing.ID == "" {
+			signing.ID = "hs256"
+		}
+	case AlgorithmRS256, AlgorithmEdDSA:
+		if opts.PrivateKeyFile == "" {
+			return nil, fmt.Errorf("jwt: %s requires a private key file", opts.Algorithm)
+		}
+		key, err := loadPrivateKey(opts.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if key.Algorithm != opts.Algorithm {
+			return nil, fmt.Errorf("jwt: %s is a %s key, not %s", opts.PrivateKeyFile, key.Algorithm, opts.Algorithm)
+		}
+		if opts.KeyID != "" {
+			key.ID = opts.KeyID
+		}
+		signing = key
+	default:
+		return nil, fmt.Errorf("jwt: unsupported algorithm %q", opts.Algorithm)
+	}
+
+	keyring := &Keyring{
+		signing: signing,
+		keys:    map[string]*Key{signing.ID: signing},
+	}
+
+	for kid, file := range opts.VerificationKeys {
+		key, err := loadPublicKey(file)
+		if err != nil {
+			return nil, err
+		}
+		key.ID = kid
+		if _, exists := keyring.keys[kid]; exists {
+			return nil, fmt.Errorf("jwt: duplicate key id %q", kid)
+		}
+		keyring.keys[kid] = key
+	}
+
+	return keyring, nil
+}
+
+// ParseVerificationKeys reads "kid=path,kid=path" as found in the environment
+func ParseVerificationKeys(value string) (map[string]string, error) {
+	keys := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kid, file, ok := strings.Cut(entry, "=")
+		if !ok || kid == "" || file == "" {
+			return nil, fmt.Errorf("jwt: invalid verification key %q, expected kid=path", entry)
+		}
+		keys[kid] = file
+	}
+	return keys, nil
+}
+
+// SigningKeyID returns the kid new tokens are signed with
+func (k *Keyring) SigningKeyID() string {
+	return k.signing.ID
+}
+
+func (k *Keyring) algorithms() []string {
+	seen := make(map[string]bool)
+	algorithms := make([]string, 0, 2)
+	for _, key := range k.keys {
+		if !seen[key.Algorithm] {
+			seen[key.Algorithm] = true
+			algorithms = append(algorithms, key.Algorithm)
+		}
+	}
+	return algorithms
+}
+
+func loadPrivateKey(file string) (*Key, error) {
+	block, err := readPEM(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed interface{}
+	if parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+		if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("jwt: %s is not a PKCS#8 or PKCS#1 private key", file)
+		}
+	}
+
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		return newKey(AlgorithmRS256, private, &private.PublicKey)
+	case ed25519.PrivateKey:
+		return newKey(AlgorithmEdDSA, private, private.Public().(ed25519.PublicKey))
+	default:
+		return nil, fmt.Errorf("jwt: %s holds an unsupported key type", file)
+	}
+}
+
+// loadPublicKey also accepts a private key file, only its public half is kept
+func loadPublicKey(file string) (*Key, error) {
+	block, err := readPEM(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.Contains(block.Type, "PRIVATE KEY") {
+		key, err := loadPrivateKey(file)
+		if err != nil {
+			return nil, err
+		}
+		key.signKey = nil
+		return key, nil
+	}
+
+	var parsed interface{}
+	if parsed, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+		if parsed, err = x509.ParsePKCS1PublicKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("jwt: %s is not a PKIX or PKCS#1 public key", file)
+		}
+	}
+
+	switch public := parsed.(type) {
+	case *rsa.PublicKey:
+		return newKey(AlgorithmRS256, nil, public)
+	case ed25519.PublicKey:
+		return newKey(AlgorithmEdDSA, nil, public)
+	default:
+		return nil, fmt.Errorf("jwt: %s holds an unsupported key type", file)
+	}
+}
+
+// newKey derives the default kid from the public key, so every replica computes the same one
+func newKey(algorithm string, private, public interface{}) (*Key, error) {
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(der)
+
+	method := jwt.SigningMethod(jwt.SigningMethodRS256)
+	if algorithm == AlgorithmEdDSA {
+		method = jwt.SigningMethodEdDSA
+	}
+
+	return &Key{
+		ID:        hex.EncodeToString(sum[:8]),
+		Algorithm: algorithm,
+		method:    method,
+		signKey:   private,
+		verifyKey: public,
+	}, nil
+}
+
+func readPEM(file string) (*pem.Block, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("jwt: unable to read key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("jwt: %s is not PEM encoded", file)
+	}
+	return block, nil
+}
@@ -0,0 +1,262 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeKeyPair writes key to a PKCS#8 private key file and a PKIX public key file
+func writeKeyPair(t *testing.T, name string, key interface{}, public interface{}) (privateFile, publicFile string) {
+	t.Helper()
+	dir := t.TempDir()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateFile = filepath.Join(dir, name+".pem")
+	if err := os.WriteFile(privateFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	der, err = x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicFile = filepath.Join(dir, name+".pub.pem")
+	if err := os.WriteFile(publicFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return privateFile, publicFile
+}
+
+func writeEd25519(t *testing.T, name string) (string, string) {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writeKeyPair(t, name, private, public)
+}
+
+func writeRSA(t *testing.T, name string) (string, string) {
+	t.Helper()
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writeKeyPair(t, name, private, &private.PublicKey)
+}
+
+func mustKeyring(t *testing.T, opts KeyringOptions) *Keyring {
+	t.Helper()
+	keyring, err := LoadKeyring(opts)
+	if err != nil {
+		t.Fatalf("LoadKeyring: %v", err)
+	}
+	return keyring
+}
+
+func mustToken(t *testing.T, keyring *Keyring) string {
+	t.Helper()
+	token, err := keyring.GenerateToken("user-1", "user@example.com", "session-1", time.Minute)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	return token
+}
+
+func TestSignAndVerify(t *testing.T) {
+	edPrivate, _ := writeEd25519(t, "ed")
+	rsaPrivate, _ := writeRSA(t, "rsa")
+
+	tests := []struct {
+		name string
+		opts KeyringOptions
+	}{
+		{"HS256", KeyringOptions{Algorithm: AlgorithmHS256, Secret: "secret"}},
+		{"HS256 by default", KeyringOptions{Secret: "secret"}},
+		{"EdDSA", KeyringOptions{Algorithm: AlgorithmEdDSA, PrivateKeyFile: edPrivate}},
+		{"RS256", KeyringOptions{Algorithm: AlgorithmRS256, PrivateKeyFile: rsaPrivate}},
+		{"explicit kid", KeyringOptions{Algorithm: AlgorithmEdDSA, KeyID: "2024-01", PrivateKeyFile: edPrivate}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyring := mustKeyring(t, tt.opts)
+			claims, err := keyring.ValidateToken(mustToken(t, keyring))
+			if err != nil {
+				t.Fatalf("ValidateToken: %v", err)
+			}
+			if claims.UserID != "user-1" || claims.Email != "user@example.com" || claims.SessionID != "session-1" {
+				t.Errorf("unexpected claims %+v", claims)
+			}
+			if tt.opts.KeyID != "" && keyring.SigningKeyID() != tt.opts.KeyID {
+				t.Errorf("SigningKeyID() = %q, want %q", keyring.SigningKeyID(), tt.opts.KeyID)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldPrivate, oldPublic := writeEd25519(t, "old")
+	newPrivate, _ := writeRSA(t, "new")
+
+	// Before the rotation tokens are signed with the old key
+	before := mustKeyring(t, KeyringOptions{Algorithm: AlgorithmEdDSA, PrivateKeyFile: oldPrivate})
+	oldToken := mustToken(t, before)
+
+	// During the rotation the new key signs and the old one only verifies
+	during := mustKeyring(t, KeyringOptions{
+		Algorithm:        AlgorithmRS256,
+		PrivateKeyFile:   newPrivate,
+		VerificationKeys: map[string]string{before.SigningKeyID(): oldPublic},
+	})
+	newToken := mustToken(t, during)
+
+	// After the rotation the old key is retired
+	after := mustKeyring(t, KeyringOptions{Algorithm: AlgorithmRS256, PrivateKeyFile: newPrivate})
+
+	if during.SigningKeyID() == before.SigningKeyID() {
+		t.Fatal("rotated keyring kept the old signing kid")
+	}
+	if after.SigningKeyID() != during.SigningKeyID() {
+		t.Fatal("the kid of a key must not depend on the replica")
+	}
+
+	tests := []struct {
+		name    string
+		keyring *Keyring
+		token   string
+		wantErr bool
+	}{
+		{"old token before rotation", before, oldToken, false},
+		{"old token during rotation", during, oldToken, false},
+		{"new token during rotation", during, newToken, false},
+		{"new token on a replica still on the old key", before, newToken, true},
+		{"old token after the old key is retired", after, oldToken, true},
+		{"new token after rotation", after, newToken, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.keyring.ValidateToken(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerificationKeyFromPrivateKeyFile(t *testing.T) {
+	oldPrivate, _ := writeEd25519(t, "old")
+	newPrivate, _ := writeEd25519(t, "new")
+
+	before := mustKeyring(t, KeyringOptions{Algorithm: AlgorithmEdDSA, PrivateKeyFile: oldPrivate})
+	during := mustKeyring(t, KeyringOptions{
+		Algorithm:        AlgorithmEdDSA,
+		PrivateKeyFile:   newPrivate,
+		VerificationKeys: map[string]string{before.SigningKeyID(): oldPrivate},
+	})
+	if _, err := during.ValidateToken(mustToken(t, before)); err != nil {
+		t.Errorf("ValidateToken: %v", err)
+	}
+}
+
+func TestValidateTokenRejects(t *testing.T) {
+	edPrivate, _ := writeEd25519(t, "ed")
+	keyring := mustKeyring(t, KeyringOptions{Algorithm: AlgorithmEdDSA, PrivateKeyFile: edPrivate})
+	token := mustToken(t, keyring)
+
+	// An HMAC token claiming the kid of the asymmetric key must not verify
+	forged := mustKeyring(t, KeyringOptions{Algorithm: AlgorithmHS256, KeyID: keyring.SigningKeyID(), Secret: "guess"})
+
+	expired, err := keyring.GenerateToken("user-1", "user@example.com", "session-1", -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := strings.Split(token, ".")
+	tampered := parts[0] + "." + parts[1] + "x." + parts[2]
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"algorithm confusion", mustToken(t, forged)},
+		{"unknown kid", mustToken(t, mustKeyring(t, KeyringOptions{Secret: "secret"}))},
+		{"expired", expired},
+		{"tampered payload", tampered},
+		{"garbage", "not-a-token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := keyring.ValidateToken(tt.token); err == nil {
+				t.Error("expected ValidateToken to fail")
+			}
+		})
+	}
+}
+
+func TestLoadKeyringErrors(t *testing.T) {
+	edPrivate, edPublic := writeEd25519(t, "ed")
+
+	tests := []struct {
+		name string
+		opts KeyringOptions
+	}{
+		{"HS256 without secret", KeyringOptions{Algorithm: AlgorithmHS256}},
+		{"EdDSA without key file", KeyringOptions{Algorithm: AlgorithmEdDSA}},
+		{"algorithm does not match the key", KeyringOptions{Algorithm: AlgorithmRS256, PrivateKeyFile: edPrivate}},
+		{"unsupported algorithm", KeyringOptions{Algorithm: "none", Secret: "secret"}},
+		{"missing key file", KeyringOptions{Algorithm: AlgorithmEdDSA, PrivateKeyFile: filepath.Join(t.TempDir(), "missing.pem")}},
+		{"duplicate kid", KeyringOptions{Secret: "secret", VerificationKeys: map[string]string{"hs256": edPublic}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadKeyring(tt.opts); err == nil {
+				t.Error("expected LoadKeyring to fail")
+			}
+		})
+	}
+}
+
+func TestParseVerificationKeys(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"", map[string]string{}, false},
+		{"old=/keys/old.pem", map[string]string{"old": "/keys/old.pem"}, false},
+		{" a=/a.pem , b=/b.pem ,", map[string]string{"a": "/a.pem", "b": "/b.pem"}, false},
+		{"no-separator", nil, true},
+		{"=/a.pem", nil, true},
+		{"a=", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseVerificationKeys(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseVerificationKeys(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("ParseVerificationKeys(%q) = %v, want %v", tt.value, got, tt.want)
+			continue
+		}
+		for kid, file := range tt.want {
+			if got[kid] != file {
+				t.Errorf("ParseVerificationKeys(%q)[%q] = %q, want %q", tt.value, kid, got[kid], file)
+			}
+		}
+	}
+}